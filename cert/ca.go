@@ -0,0 +1,64 @@
+// Copyright 2023 jim.zoumo@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cert
+
+import (
+	"crypto"
+	"crypto/x509"
+	"errors"
+)
+
+// CA is a certificate authority which holds the CA key and certificate
+// to sign leaf certificates.
+type CA struct {
+	Key  crypto.Signer
+	Cert *x509.Certificate
+}
+
+// NewCA creates a CA with a new RSA private key and a self-signed CA
+// certificate.
+func NewCA(cfg Config) (*CA, error) {
+	key, err := NewRSAPrivateKey()
+	if err != nil {
+		return nil, err
+	}
+	cert, err := NewSelfSignedCACert(cfg, key)
+	if err != nil {
+		return nil, err
+	}
+	return &CA{Key: key, Cert: cert}, nil
+}
+
+// SignCert returns a new certificate for leafKey signed by the CA.
+func (ca *CA) SignCert(cfg Config, leafKey crypto.Signer) (*x509.Certificate, error) {
+	if ca.Key == nil || ca.Cert == nil {
+		return nil, errors.New("ca key and certificate must be set")
+	}
+	return NewSignedCert(cfg, leafKey, ca.Key, ca.Cert)
+}
+
+// NewLeaf generates a new RSA private key and a certificate for it signed
+// by the CA.
+func (ca *CA) NewLeaf(cfg Config) (crypto.Signer, *x509.Certificate, error) {
+	key, err := NewRSAPrivateKey()
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := ca.SignCert(cfg, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, cert, nil
+}
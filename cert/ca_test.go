@@ -0,0 +1,60 @@
+// Copyright 2023 jim.zoumo@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cert
+
+import (
+	"crypto/x509"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCA_NewLeaf(t *testing.T) {
+	ca, err := NewCA(Config{CommonName: "ca.example.com"})
+	assert.Nil(t, err)
+	assert.True(t, ca.Cert.IsCA)
+
+	key, leaf, err := ca.NewLeaf(Config{
+		CommonName: "test.example.com",
+		AltNames:   AltNames{DNSNames: []string{"test.example.com"}},
+		Usages:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, key.Public(), leaf.PublicKey)
+	assert.False(t, leaf.IsCA)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Cert)
+	_, err = leaf.Verify(x509.VerifyOptions{
+		DNSName: "test.example.com",
+		Roots:   roots,
+	})
+	assert.Nil(t, err)
+
+	// a certificate signed by another CA can not be verified
+	other, err := NewCA(Config{CommonName: "other.example.com"})
+	assert.Nil(t, err)
+	_, leaf, err = other.NewLeaf(Config{CommonName: "test.example.com"})
+	assert.Nil(t, err)
+	_, err = leaf.Verify(x509.VerifyOptions{Roots: roots})
+	assert.NotNil(t, err)
+}
+
+func TestCA_SignCert(t *testing.T) {
+	ca := &CA{}
+	key, _ := NewRSAPrivateKey()
+	_, err := ca.SignCert(Config{CommonName: "test.example.com"}, key)
+	assert.NotNil(t, err)
+}
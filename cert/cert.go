@@ -19,8 +19,10 @@ import (
 	"crypto/rand"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"errors"
 	"math/big"
 	"net"
+	"net/url"
 	"time"
 )
 
@@ -34,14 +36,21 @@ type Config struct {
 	Organization []string
 	AltNames     AltNames
 	Usages       []x509.ExtKeyUsage
+	// NotBefore is the start of the validity period, defaults to now.
+	NotBefore time.Time
+	// Duration is the length of the validity period from NotBefore,
+	// defaults to 100 years.
+	Duration time.Duration
 }
 
-// AltNames contains the domain names and IP addresses that will be added
-// to the API Server's x509 certificate SubAltNames field. The values will
-// be passed directly to the x509.Certificate object.
+// AltNames contains the domain names, IP addresses, URIs and email addresses
+// that will be added to the API Server's x509 certificate SubAltNames field.
+// The values will be passed directly to the x509.Certificate object.
 type AltNames struct {
-	DNSNames []string
-	IPs      []net.IP
+	DNSNames       []string
+	IPs            []net.IP
+	URIs           []*url.URL
+	EmailAddresses []string
 }
 
 // NewSignedCert returns a new certificate signed by given ca key and certificate
@@ -76,6 +85,36 @@ func NewSelfSignedCACert(cfg Config, key crypto.Signer) (*x509.Certificate, erro
 	return x509.ParseCertificate(certDERBytes)
 }
 
+// NewIntermediateCACert returns a new intermediate CA x509 certificate signed
+// by the given parent CA key and certificate.
+//
+// The path length of the intermediate CA is one less than the parent's. If
+// the parent's path length is unlimited, the intermediate CA can only sign
+// leaf certificates, i.e. its MaxPathLen is 0.
+func NewIntermediateCACert(cfg Config, key crypto.Signer, parentKey crypto.Signer, parentCert *x509.Certificate) (*x509.Certificate, error) {
+	if !parentCert.IsCA {
+		return nil, errors.New("parent certificate is not a CA")
+	}
+	maxPathLen := 0
+	if parentCert.MaxPathLen > 0 {
+		maxPathLen = parentCert.MaxPathLen - 1
+	} else if parentCert.MaxPathLen == 0 && parentCert.MaxPathLenZero {
+		return nil, errors.New("parent CA can not sign intermediate CA certificates, its MaxPathLen is 0")
+	}
+
+	template, err := generateCertTemplate(cfg, true)
+	if err != nil {
+		return nil, err
+	}
+	template.MaxPathLen = maxPathLen
+	template.MaxPathLenZero = maxPathLen == 0
+	certDerBytes, err := x509.CreateCertificate(rand.Reader, template, parentCert, key.Public(), parentKey)
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(certDerBytes)
+}
+
 // NewCSR returns a new x509 certificate request
 func NewCSR(cfg Config, key crypto.Signer) (*x509.CertificateRequest, error) {
 	template := generateCSRTemplate(cfg)
@@ -86,6 +125,20 @@ func NewCSR(cfg Config, key crypto.Signer) (*x509.CertificateRequest, error) {
 	return x509.ParseCertificateRequest(csrDerBytes)
 }
 
+// NewKeyAndCSR generates a new private key by key type and a certificate request
+// signed by it, the certificate request is returned in PEM format.
+func NewKeyAndCSR(cfg Config, keyType KeyType) (key crypto.Signer, csrPEM []byte, err error) {
+	key, err = NewPrivateKey(keyType)
+	if err != nil {
+		return nil, nil, err
+	}
+	csr, err := NewCSR(cfg, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, MarshalCSRToPEM(csr).EncodeToMemory(), nil
+}
+
 func newSelfSignedCert(cfg Config, key crypto.Signer, isCA bool) ([]byte, error) {
 	template, err := generateCertTemplate(cfg, isCA)
 	if err != nil {
@@ -103,7 +156,14 @@ func generateCertTemplate(cfg Config, isCA bool) (*x509.Certificate, error) {
 		}
 	}
 
-	now := time.Now()
+	notBefore := time.Now()
+	if !cfg.NotBefore.IsZero() {
+		notBefore = cfg.NotBefore
+	}
+	duration := oneYear * 100
+	if cfg.Duration > 0 {
+		duration = cfg.Duration
+	}
 	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
 	if err != nil {
 		return nil, err
@@ -114,10 +174,12 @@ func generateCertTemplate(cfg Config, isCA bool) (*x509.Certificate, error) {
 			CommonName:   cfg.CommonName,
 			Organization: cfg.Organization,
 		},
-		NotBefore:             now.UTC(),
-		NotAfter:              now.Add(oneYear * 100).UTC(),
+		NotBefore:             notBefore.UTC(),
+		NotAfter:              notBefore.Add(duration).UTC(),
 		IPAddresses:           cfg.AltNames.IPs,
 		DNSNames:              cfg.AltNames.DNSNames,
+		URIs:                  cfg.AltNames.URIs,
+		EmailAddresses:        cfg.AltNames.EmailAddresses,
 		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
 		ExtKeyUsage:           cfg.Usages,
 		BasicConstraintsValid: true,
@@ -142,8 +204,10 @@ func generateCSRTemplate(cfg Config) *x509.CertificateRequest {
 			CommonName:   cfg.CommonName,
 			Organization: cfg.Organization,
 		},
-		IPAddresses: cfg.AltNames.IPs,
-		DNSNames:    cfg.AltNames.DNSNames,
+		IPAddresses:    cfg.AltNames.IPs,
+		DNSNames:       cfg.AltNames.DNSNames,
+		URIs:           cfg.AltNames.URIs,
+		EmailAddresses: cfg.AltNames.EmailAddresses,
 	}
 
 	return template
@@ -0,0 +1,220 @@
+// Copyright 2023 jim.zoumo@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cert
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// oidExtensionSubjectAltName is the OID of x509 SAN extension
+var oidExtensionSubjectAltName = asn1.ObjectIdentifier{2, 5, 29, 17}
+
+func hasSANExtension(exts []asn1.ObjectIdentifier) bool {
+	for _, ext := range exts {
+		if ext.Equal(oidExtensionSubjectAltName) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestNewSignedCert_AltNames(t *testing.T) {
+	spiffeID, _ := url.Parse("spiffe://example.org/ns/default/sa/test")
+	caKey, _ := NewRSAPrivateKey()
+	caCert, _ := NewSelfSignedCACert(Config{CommonName: "ca.example.com"}, caKey)
+	key, _ := NewECPrivateKey(CurveP256)
+
+	cert, err := NewSignedCert(Config{
+		CommonName: "test",
+		AltNames: AltNames{
+			URIs:           []*url.URL{spiffeID},
+			EmailAddresses: []string{"test@example.com"},
+		},
+	}, key, caKey, caCert)
+	assert.Nil(t, err)
+	if assert.Len(t, cert.URIs, 1) {
+		assert.Equal(t, spiffeID.String(), cert.URIs[0].String())
+	}
+	assert.Equal(t, []string{"test@example.com"}, cert.EmailAddresses)
+	assert.Contains(t, CertInfo(cert), spiffeID.String())
+
+	// empty alt names should not add an empty extension
+	cert, err = NewSignedCert(Config{CommonName: "test"}, key, caKey, caCert)
+	assert.Nil(t, err)
+	exts := []asn1.ObjectIdentifier{}
+	for _, ext := range cert.Extensions {
+		exts = append(exts, ext.Id)
+	}
+	assert.False(t, hasSANExtension(exts))
+}
+
+func TestNewIntermediateCACert(t *testing.T) {
+	rootKey, _ := NewECPrivateKey(CurveP256)
+	rootCert, err := NewSelfSignedCACert(Config{CommonName: "root.example.com"}, rootKey)
+	assert.Nil(t, err)
+
+	interKey, _ := NewECPrivateKey(CurveP256)
+	interCert, err := NewIntermediateCACert(Config{CommonName: "intermediate.example.com"}, interKey, rootKey, rootCert)
+	assert.Nil(t, err)
+	assert.True(t, interCert.IsCA)
+	assert.Equal(t, 0, interCert.MaxPathLen)
+	assert.True(t, interCert.MaxPathLenZero)
+	assert.Equal(t, "root.example.com", interCert.Issuer.CommonName)
+
+	leafKey, _ := NewECPrivateKey(CurveP256)
+	leafCert, err := NewSignedCert(Config{
+		CommonName: "leaf.example.com",
+		AltNames:   AltNames{DNSNames: []string{"leaf.example.com"}},
+		Usages:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, leafKey, interKey, interCert)
+	assert.Nil(t, err)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(rootCert)
+	intermediates := x509.NewCertPool()
+	intermediates.AddCert(interCert)
+	chains, err := leafCert.Verify(x509.VerifyOptions{
+		DNSName:       "leaf.example.com",
+		Roots:         roots,
+		Intermediates: intermediates,
+	})
+	assert.Nil(t, err)
+	if assert.Len(t, chains, 1) {
+		assert.Len(t, chains[0], 3)
+	}
+
+	// the intermediate CA can not sign another CA
+	_, err = NewIntermediateCACert(Config{CommonName: "sub.example.com"}, leafKey, interKey, interCert)
+	assert.NotNil(t, err)
+	// a leaf certificate can not sign a CA
+	_, err = NewIntermediateCACert(Config{CommonName: "sub.example.com"}, interKey, leafKey, leafCert)
+	assert.NotNil(t, err)
+}
+
+func TestNewCSR_AltNames(t *testing.T) {
+	spiffeID, _ := url.Parse("spiffe://example.org/ns/default/sa/test")
+	key, _ := NewECPrivateKey(CurveP256)
+	csr, err := NewCSR(Config{
+		CommonName: "test",
+		AltNames: AltNames{
+			URIs:           []*url.URL{spiffeID},
+			EmailAddresses: []string{"test@example.com"},
+		},
+	}, key)
+	assert.Nil(t, err)
+	assert.Nil(t, csr.CheckSignature())
+	if assert.Len(t, csr.URIs, 1) {
+		assert.Equal(t, spiffeID.String(), csr.URIs[0].String())
+	}
+	assert.Equal(t, []string{"test@example.com"}, csr.EmailAddresses)
+}
+
+func TestNewSelfSignedCert_Validity(t *testing.T) {
+	key, _ := NewECPrivateKey(CurveP256)
+	notBefore := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	tests := []struct {
+		name          string
+		cfg           Config
+		wantNotBefore func(time.Time) bool
+		wantNotAfter  func(time.Time) bool
+	}{
+		{
+			name: "default",
+			cfg:  Config{CommonName: "test"},
+			wantNotAfter: func(notAfter time.Time) bool {
+				return notAfter.After(time.Now().Add(oneYear * 99))
+			},
+		},
+		{
+			name: "duration",
+			cfg:  Config{CommonName: "test", Duration: 24 * time.Hour},
+			wantNotAfter: func(notAfter time.Time) bool {
+				return notAfter.After(time.Now()) && !notAfter.After(time.Now().Add(24*time.Hour))
+			},
+		},
+		{
+			name: "not before and duration",
+			cfg:  Config{CommonName: "test", NotBefore: notBefore, Duration: 2 * time.Hour},
+			wantNotBefore: func(t time.Time) bool {
+				return t.Equal(notBefore)
+			},
+			wantNotAfter: func(t time.Time) bool {
+				return t.Equal(notBefore.Add(2 * time.Hour))
+			},
+		},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			cert, err := NewSelfSignedCert(tt.cfg, key)
+			assert.Nil(t, err)
+			if tt.wantNotBefore != nil {
+				assert.True(t, tt.wantNotBefore(cert.NotBefore), "unexpected NotBefore %v", cert.NotBefore)
+			}
+			assert.True(t, tt.wantNotAfter(cert.NotAfter), "unexpected NotAfter %v", cert.NotAfter)
+		})
+	}
+}
+
+func TestNewKeyAndCSR(t *testing.T) {
+	tests := []struct {
+		name    string
+		keyType KeyType
+		wantErr bool
+	}{
+		{name: "rsa", keyType: KeyTypeRSA},
+		{name: "ecdsa", keyType: KeyTypeECDSA},
+		{name: "unknown", keyType: "DSA", wantErr: true},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			key, csrPEM, err := NewKeyAndCSR(Config{CommonName: "test"}, tt.keyType)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+
+			blocks := DecodePEMs(csrPEM)
+			if !assert.Len(t, blocks, 1) {
+				return
+			}
+			assert.Equal(t, CertificateRequestPEMBlockType, blocks[0].Block.Type)
+			csr, err := x509.ParseCertificateRequest(blocks[0].Block.Bytes)
+			assert.Nil(t, err)
+			assert.Nil(t, csr.CheckSignature())
+			assert.Equal(t, "test", csr.Subject.CommonName)
+
+			switch pub := csr.PublicKey.(type) {
+			case *rsa.PublicKey:
+				assert.True(t, pub.Equal(key.Public()))
+			case *ecdsa.PublicKey:
+				assert.True(t, pub.Equal(key.Public()))
+			default:
+				t.Errorf("unexpected public key type %T", pub)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2023 jim.zoumo@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cert
+
+import (
+	"bytes"
+	"crypto/sha1" // nolint
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// String returns a human-readable summary of the certificate
+func (t *TLSCertificate) String() string {
+	return CertInfo(t.X509Cert)
+}
+
+// CertInfo returns a human-readable summary of the x509 certificate,
+// including subject, issuer, validity window, SANs and fingerprint.
+func CertInfo(cert *x509.Certificate) string {
+	if cert == nil {
+		return ""
+	}
+
+	b := strings.Builder{}
+	writeField := func(name, value string) {
+		fmt.Fprintf(&b, "%-22s%s\n", name+":", value)
+	}
+
+	writeField("Subject", cert.Subject.String())
+	writeField("Issuer", cert.Issuer.String())
+	writeField("Serial Number", cert.SerialNumber.String())
+	writeField("Not Before", cert.NotBefore.UTC().Format(time.RFC3339))
+	writeField("Not After", cert.NotAfter.UTC().Format(time.RFC3339))
+	if len(cert.DNSNames) > 0 {
+		writeField("DNS Names", strings.Join(cert.DNSNames, ", "))
+	}
+	if len(cert.IPAddresses) > 0 {
+		ips := make([]string, 0, len(cert.IPAddresses))
+		for _, ip := range cert.IPAddresses {
+			ips = append(ips, ip.String())
+		}
+		writeField("IP Addresses", strings.Join(ips, ", "))
+	}
+	if len(cert.URIs) > 0 {
+		uris := make([]string, 0, len(cert.URIs))
+		for _, uri := range cert.URIs {
+			uris = append(uris, uri.String())
+		}
+		writeField("URIs", strings.Join(uris, ", "))
+	}
+	if len(cert.EmailAddresses) > 0 {
+		writeField("Email Addresses", strings.Join(cert.EmailAddresses, ", "))
+	}
+	writeField("SHA-256 Fingerprint", sha256Fingerprint(cert))
+	return b.String()
+}
+
+// CertsEqual reports whether a and b are the same certificate by comparing
+// their DER encoded bytes, so it is not affected by re-parsing. It can be
+// used to detect whether a reloaded certificate is changed.
+// Two nil certificates are equal, a nil one is not equal to a non-nil one.
+func CertsEqual(a, b *x509.Certificate) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return bytes.Equal(a.Raw, b.Raw)
+}
+
+// Fingerprint returns the hex encoded SHA-256 digest of the raw certificate.
+func Fingerprint(cert *x509.Certificate) string {
+	if cert == nil {
+		return ""
+	}
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// FingerprintSHA1 returns the hex encoded SHA-1 digest of the raw certificate.
+// It is only for legacy systems, use Fingerprint instead if possible.
+func FingerprintSHA1(cert *x509.Certificate) string {
+	if cert == nil {
+		return ""
+	}
+	sum := sha1.Sum(cert.Raw) // nolint
+	return hex.EncodeToString(sum[:])
+}
+
+// sha256Fingerprint returns the SHA-256 digest of the raw certificate as
+// colon separated upper case hex
+func sha256Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	hex := make([]string, 0, len(sum))
+	for _, b := range sum {
+		hex = append(hex, fmt.Sprintf("%02X", b))
+	}
+	return strings.Join(hex, ":")
+}
@@ -0,0 +1,117 @@
+// Copyright 2023 jim.zoumo@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cert
+
+import (
+	"crypto/x509"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCertInfo(t *testing.T) {
+	caKey, _ := NewRSAPrivateKey()
+	caCert, _ := NewSelfSignedCACert(Config{
+		CommonName:   "ca.example.com",
+		Organization: []string{"ca"},
+	}, caKey)
+	key, _ := NewRSAPrivateKey()
+	cert, err := NewSignedCert(Config{
+		CommonName:   "test.example.com",
+		Organization: []string{"server"},
+		AltNames: AltNames{
+			DNSNames: []string{"test.example.com", "www.example.com"},
+			IPs:      []net.IP{net.ParseIP("127.0.0.1")},
+		},
+	}, key, caKey, caCert)
+	assert.Nil(t, err)
+
+	info := CertInfo(cert)
+	assert.Contains(t, info, "CN=test.example.com")
+	assert.Contains(t, info, "CN=ca.example.com")
+	assert.Contains(t, info, "test.example.com, www.example.com")
+	assert.Contains(t, info, "127.0.0.1")
+	assert.Regexp(t, "Not Before: +"+cert.NotBefore.UTC().Format(time.RFC3339), info)
+	assert.Contains(t, info, "SHA-256 Fingerprint: ")
+	assert.Equal(t, info, CertInfo(cert), "output should be stable")
+
+	keyPEM, _ := MarshalPrivateKeyToPEM(key)
+	tlsCert, err := X509KeyPair(MarshalCertToPEM(cert).EncodeToMemory(), keyPEM.EncodeToMemory())
+	assert.Nil(t, err)
+	assert.Equal(t, info, tlsCert.String())
+
+	for _, line := range strings.Split(strings.TrimSpace(info), "\n") {
+		if strings.HasPrefix(line, "SHA-256 Fingerprint:") {
+			fingerprint := strings.TrimSpace(strings.TrimPrefix(line, "SHA-256 Fingerprint:"))
+			assert.Len(t, strings.Split(fingerprint, ":"), 32)
+		}
+	}
+}
+
+func TestCertsEqual(t *testing.T) {
+	key, _ := NewRSAPrivateKey()
+	cert, err := NewSelfSignedCert(Config{CommonName: "test.example.com"}, key)
+	assert.Nil(t, err)
+	reparsed, err := ParseCertPEM(MarshalCertToPEM(cert).EncodeToMemory())
+	assert.Nil(t, err)
+	// same key and subject, but different serial number and validity
+	other, err := NewSelfSignedCert(Config{CommonName: "test.example.com"}, key)
+	assert.Nil(t, err)
+
+	tests := []struct {
+		name string
+		a    *x509.Certificate
+		b    *x509.Certificate
+		want bool
+	}{
+		{"identical", cert, cert, true},
+		{"re-parsed", cert, reparsed, true},
+		{"different", cert, other, false},
+		{"both nil", nil, nil, true},
+		{"one nil", cert, nil, false},
+		{"the other nil", nil, cert, false},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, CertsEqual(tt.a, tt.b))
+		})
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	// a fake certificate with known raw bytes
+	known := &x509.Certificate{Raw: []byte("hello")}
+	assert.Equal(t, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", Fingerprint(known))
+	assert.Equal(t, "aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d", FingerprintSHA1(known))
+	assert.Equal(t, "", Fingerprint(nil))
+	assert.Equal(t, "", FingerprintSHA1(nil))
+
+	key, _ := NewRSAPrivateKey()
+	cert, err := NewSelfSignedCert(Config{CommonName: "test.example.com"}, key)
+	assert.Nil(t, err)
+	reparsed, err := ParseCertPEM(MarshalCertToPEM(cert).EncodeToMemory())
+	assert.Nil(t, err)
+	assert.Equal(t, Fingerprint(cert), Fingerprint(reparsed), "fingerprint should be stable")
+	assert.Equal(t, strings.ReplaceAll(strings.ToLower(sha256Fingerprint(cert)), ":", ""), Fingerprint(cert))
+
+	keyPEM, _ := MarshalPrivateKeyToPEM(key)
+	tlsCert, err := X509KeyPair(MarshalCertToPEM(cert).EncodeToMemory(), keyPEM.EncodeToMemory())
+	assert.Nil(t, err)
+	assert.Equal(t, Fingerprint(cert), tlsCert.Fingerprint)
+}
@@ -15,7 +15,9 @@
 package cert
 
 import (
+	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
@@ -35,6 +37,12 @@ func NewRSAPrivateKey() (*rsa.PrivateKey, error) {
 	return rsa.GenerateKey(rand.Reader, privateKeySize)
 }
 
+// NewEd25519PrivateKey creates a new Ed25519 private key
+func NewEd25519PrivateKey() (ed25519.PrivateKey, error) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	return priv, err
+}
+
 type EllipticCurve string
 
 const (
@@ -66,6 +74,28 @@ func NewECPrivateKey(curve EllipticCurve) (*ecdsa.PrivateKey, error) {
 	return priv, nil
 }
 
+type KeyType string
+
+const (
+	KeyTypeRSA     KeyType = "RSA"
+	KeyTypeECDSA   KeyType = "ECDSA"
+	KeyTypeEd25519 KeyType = "Ed25519"
+)
+
+// NewPrivateKey creates a new private key by key type, ECDSA key uses P256 curve
+func NewPrivateKey(keyType KeyType) (crypto.Signer, error) {
+	switch keyType {
+	case KeyTypeRSA:
+		return NewRSAPrivateKey()
+	case KeyTypeECDSA:
+		return NewECPrivateKey(CurveP256)
+	case KeyTypeEd25519:
+		return NewEd25519PrivateKey()
+	default:
+		return nil, fmt.Errorf("unrecognized key type: %q", keyType)
+	}
+}
+
 // DecryptPrivateKeyFile takes a password encrypted key file and the password
 //
 //	used to encrypt it and returns a slice of decrypted DER encoded bytes.
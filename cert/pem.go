@@ -18,6 +18,7 @@ import (
 	"bytes"
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
@@ -100,6 +101,16 @@ func DecodePEMs(pemBytes []byte) []*PEMBlock {
 	return decodePEMs(pemBytes, false, nil)
 }
 
+// SplitPEMByType decodes input pem bytes to pem blocks and groups them by
+// their Type, the order of blocks with the same Type is kept.
+func SplitPEMByType(pemBytes []byte) map[string][]*PEMBlock {
+	ret := map[string][]*PEMBlock{}
+	for _, p := range decodePEMs(pemBytes, false, nil) {
+		ret[p.Type] = append(ret[p.Type], p)
+	}
+	return ret
+}
+
 // DecodeFirstPEM find valid pem block in bytes and decode the first block.
 func DecodeFirstPEM(pemBytes []byte) *PEMBlock {
 	pems := decodePEMs(pemBytes, true, nil)
@@ -116,8 +127,10 @@ func MarshalPrivateKeyToPEM(key crypto.Signer) (*PEMBlock, error) {
 		return MarshalRSAPrivateKeyToPEM(pkey), nil
 	case *ecdsa.PrivateKey:
 		return MarshalECPrivateKeyToPEM(pkey)
+	case ed25519.PrivateKey:
+		return MarshalEd25519PrivateKeyToPEM(pkey)
 	default:
-		return nil, errors.New("the key must be *rsa.PrivateKey or *ecdsa.PrivateKey")
+		return nil, errors.New("the key must be *rsa.PrivateKey, *ecdsa.PrivateKey or ed25519.PrivateKey")
 	}
 }
 
@@ -141,6 +154,18 @@ func MarshalECPrivateKeyToPEM(key *ecdsa.PrivateKey) (*PEMBlock, error) {
 	}), nil
 }
 
+// MarshalEd25519PrivateKeyToPEM converts an Ed25519 private key to PKCS #8, ASN.1 DER form.
+func MarshalEd25519PrivateKeyToPEM(key ed25519.PrivateKey) (*PEMBlock, error) {
+	bytes, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return NewPEMBlock(&pem.Block{
+		Type:  PrivateKeyPEMBlockType,
+		Bytes: bytes,
+	}), nil
+}
+
 // MarshalCertToPEM returns a pemBlock for x509 certificate
 func MarshalCertToPEM(crt *x509.Certificate) *PEMBlock {
 	if crt == nil {
@@ -164,11 +189,11 @@ func MarshalCSRToPEM(csr *x509.CertificateRequest) *PEMBlock {
 }
 
 // ParsePrivateKeyPEM find and decode the first valid private key pem block, then
-// convert it to crypto.PrivateKey(maybe rsa.PrivateKey or ecdsa.PrivateKey)
+// convert it to crypto.PrivateKey(maybe rsa.PrivateKey, ecdsa.PrivateKey or ed25519.PrivateKey)
 func ParsePrivateKeyPEM(pemBytes []byte) (crypto.Signer, error) {
 	pems := decodePEMs(pemBytes, true, filterPrivateKey)
 	if len(pems) == 0 {
-		return nil, errors.New("data does not contain any valid RSA or ECDSA private key")
+		return nil, errors.New("data does not contain any valid RSA, ECDSA or Ed25519 private key")
 	}
 	return parsePrivateKey(pems[0].Block)
 }
@@ -206,7 +231,7 @@ func ParseCertsPEM(pemBytes []byte) ([]*x509.Certificate, error) {
 // parsePrivateKey attempts to parse the given private key pem.Block.
 // OpenSSL 0.9.8 generates PKCS#1 private keys by default, while OpenSSL 1.0.0
 // generates PKCS#8 keys. OpenSSL ecparam generates SEC1 EC private keys for ECDSA.
-// We try all three.
+// We try all three. Ed25519 private keys are always in PKCS#8 format.
 func parsePrivateKey(block *pem.Block) (crypto.Signer, error) {
 	switch block.Type {
 	case RASPrivateKeyPEMBlockType:
@@ -216,7 +241,7 @@ func parsePrivateKey(block *pem.Block) (crypto.Signer, error) {
 		// ECDSA Private Key in ASN.1 format
 		return x509.ParseECPrivateKey(block.Bytes)
 	case PrivateKeyPEMBlockType:
-		// RSA or ECDSA Private Key in unencrypted PKCS#8 format
+		// RSA, ECDSA or Ed25519 Private Key in unencrypted PKCS#8 format
 		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
 		if err != nil {
 			return nil, err
@@ -115,6 +115,29 @@ func TestParsePrivateKeyPEM(t *testing.T) {
 	}
 }
 
+func TestSplitPEMByType(t *testing.T) {
+	pemBytes := createPEMBytes()
+	got := SplitPEMByType(pemBytes)
+	want := map[string]int{
+		RASPrivateKeyPEMBlockType: 1,
+		ECPrivateKeyPEMBlockType:  1,
+		CertificatePEMBlockType:   2,
+	}
+	if len(got) != len(want) {
+		t.Errorf("SplitPEMByType() got %v types, want %v", len(got), len(want))
+	}
+	for typ, n := range want {
+		if len(got[typ]) != n {
+			t.Errorf("SplitPEMByType() got %v blocks of %q, want %v", len(got[typ]), typ, n)
+		}
+		for _, p := range got[typ] {
+			if p.Type != typ {
+				t.Errorf("SplitPEMByType() got block type %q in group %q", p.Type, typ)
+			}
+		}
+	}
+}
+
 func TestParseCertsPEM(t *testing.T) {
 	pemBytes := createPEMBytes()
 	got, err := ParseCertsPEM(pemBytes)
@@ -153,9 +176,18 @@ func TestMarshalPrivateKeyToPEM(t *testing.T) {
 			wantType: ECPrivateKeyPEMBlockType,
 			wantErr:  false,
 		},
+		{
+			name: "ed25519",
+			key: func() crypto.Signer {
+				key, _ := NewEd25519PrivateKey()
+				return key
+			}(),
+			wantType: PrivateKeyPEMBlockType,
+			wantErr:  false,
+		},
 		{
 			name:    "error",
-			key:     ed25519.PrivateKey{},
+			key:     &ed25519.PrivateKey{},
 			wantErr: true,
 		},
 	}
@@ -176,3 +208,29 @@ func TestMarshalPrivateKeyToPEM(t *testing.T) {
 		})
 	}
 }
+
+func TestEd25519PrivateKeyRoundTrip(t *testing.T) {
+	key, err := NewEd25519PrivateKey()
+	if err != nil {
+		t.Fatalf("NewEd25519PrivateKey() error = %v", err)
+	}
+	block, err := MarshalPrivateKeyToPEM(key)
+	if err != nil {
+		t.Fatalf("MarshalPrivateKeyToPEM() error = %v", err)
+	}
+	got, err := ParsePrivateKeyPEM(block.EncodeToMemory())
+	if err != nil {
+		t.Fatalf("ParsePrivateKeyPEM() error = %v", err)
+	}
+	if !key.Equal(got) {
+		t.Errorf("ParsePrivateKeyPEM() = %v, want %v", got, key)
+	}
+
+	cert, err := NewSelfSignedCert(Config{CommonName: "test"}, key)
+	if err != nil {
+		t.Fatalf("NewSelfSignedCert() error = %v", err)
+	}
+	if !key.Public().(ed25519.PublicKey).Equal(cert.PublicKey) {
+		t.Errorf("NewSelfSignedCert() public key = %v, want %v", cert.PublicKey, key.Public())
+	}
+}
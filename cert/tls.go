@@ -39,6 +39,9 @@ type TLSCertificate struct {
 	DNSNames    []string `json:"dnsNames,omitempty"`
 	IPAddresses []net.IP `json:"ipAddresses,omitempty"`
 
+	// Fingerprint is the hex encoded SHA-256 digest of the X.509 cert
+	Fingerprint string `json:"fingerprint,omitempty"`
+
 	Cert     tls.Certificate   `json:"-"`
 	X509Cert *x509.Certificate `json:"-"`
 }
@@ -108,7 +111,7 @@ func convertTLSCertificate(cert tls.Certificate) (*TLSCertificate, error) {
 		return nil, err
 	}
 	return &TLSCertificate{
-		NotBefore: x509Cert.NotAfter,
+		NotBefore: x509Cert.NotBefore,
 		NotAfter:  x509Cert.NotAfter,
 		Issuer: PkixName{
 			CommonName:   x509Cert.Issuer.CommonName,
@@ -120,6 +123,7 @@ func convertTLSCertificate(cert tls.Certificate) (*TLSCertificate, error) {
 		},
 		DNSNames:    x509Cert.DNSNames,
 		IPAddresses: x509Cert.IPAddresses,
+		Fingerprint: Fingerprint(x509Cert),
 		Cert:        cert,
 		X509Cert:    x509Cert,
 	}, nil
@@ -20,6 +20,7 @@ import (
 	"crypto"
 	"crypto/x509"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -80,3 +81,23 @@ func TestX509KeyPair(t *testing.T) {
 	// 	})
 	// }
 }
+
+func TestX509KeyPair_Validity(t *testing.T) {
+	key, _ := NewECPrivateKey(CurveP256)
+	notBefore := time.Now().Add(-time.Hour).Truncate(time.Second).UTC()
+	notAfter := notBefore.Add(24 * time.Hour)
+	cert, err := NewSelfSignedCert(Config{
+		CommonName: "test.example.com",
+		NotBefore:  notBefore,
+		Duration:   notAfter.Sub(notBefore),
+	}, key)
+	assert.Nil(t, err)
+
+	keyPEM, _ := MarshalPrivateKeyToPEM(key)
+	certPEM := MarshalCertToPEM(cert)
+
+	tlsCert, err := X509KeyPair(certPEM.EncodeToMemory(), keyPEM.EncodeToMemory())
+	assert.Nil(t, err)
+	assert.True(t, tlsCert.NotBefore.Equal(notBefore), "NotBefore = %v, want %v", tlsCert.NotBefore, notBefore)
+	assert.True(t, tlsCert.NotAfter.Equal(notAfter), "NotAfter = %v, want %v", tlsCert.NotAfter, notAfter)
+}
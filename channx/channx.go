@@ -15,9 +15,23 @@
 package chanx
 
 import (
-	"sync"
+	"errors"
 )
 
+// ErrClosed is returned when sending to a closed channel
+var ErrClosed = errors.New("chanx: send to closed channel")
+
+const (
+	// errChanSize is the buffer size of the channel returned by Errors()
+	errChanSize = 100
+)
+
+// TransformFunc transforms an item before it is sent to output channel
+type TransformFunc func(interface{}) (interface{}, error)
+
+// FilterFunc reports whether an item should be kept
+type FilterFunc func(interface{}) bool
+
 // Options defines the functional option type for Channel
 type Options func(*config)
 
@@ -27,6 +41,9 @@ type config struct {
 	initBufferSize       int
 	maxBufferSize        int
 	dropClosedBufferData bool
+	transform            TransformFunc
+	filter               FilterFunc
+	shrinkPolicy         ShrinkPolicy
 }
 
 // InChanSize sets input channel buffer size
@@ -75,172 +92,50 @@ func DropClosedBufferData() Options {
 	}
 }
 
-func newDefuerConfig() *config {
-	return &config{
-		initBufferSize:       2,
-		dropClosedBufferData: false,
-	}
-}
-
-// ChannX is a self adaptive channel with a ring buffer.
-// The channel buffer capacity will automatically increase according
-// to excessive input and restore to original when buffer is empty.
-// It can be used as an Unbounded Channel.
-type ChannX struct {
-	in        chan interface{}
-	out       chan interface{}
-	close     chan struct{}
-	clsoeOnce sync.Once
-	cfg       *config
-	buffer    *SelfAdaptiveRingBuffer
-}
-
-func New(opts ...Options) *ChannX {
-	cfg := newDefuerConfig()
-	for _, opt := range opts {
-		opt(cfg)
-	}
-
-	ch := &ChannX{
-		cfg:   cfg,
-		close: make(chan struct{}),
-	}
-	ch.in = make(chan interface{}, cfg.inChanSize)
-	ch.out = make(chan interface{}, cfg.outChanSize)
-	ch.buffer = NewSelfAdptiveRingBuffer(cfg.initBufferSize, cfg.maxBufferSize)
-
-	go ch.process()
-	return ch
-}
-
-func (ch *ChannX) process() {
-	var v interface{}
-	var ok bool
-	for {
-		// buffer is empty
-		select {
-		case v, ok = <-ch.in:
-			if !ok {
-				panic("chanx: input channel can not be closed")
-			}
-			if !ch.processObjectFromInput(v) {
-				return
-			}
-		case <-ch.close:
-			ch.processTermination(nil)
-			return
-		}
-
-		// v has already processed
-		// we need to deal with objects in buffer
-
-		for !ch.buffer.IsEmpty() {
-			peek, _ := ch.buffer.Peek()
-			select {
-			case v, ok = <-ch.in:
-				if !ok {
-					panic("chanx: input channel can not be closed")
-				}
-				if !ch.processObjectFromInput(v) {
-					return
-				}
-			case ch.out <- peek:
-				ch.buffer.Pop() // nolint
-				if ch.buffer.NeedReset() {
-					ch.buffer.Reset()
-				}
-			case <-ch.close:
-				ch.processTermination(nil)
-				return
-			}
-		}
+// WithTransform sets a function to transform each item from input channel
+// before it is sent to output channel or ring buffer.
+// If the function returns an error, the item is dropped and the error is
+// sent to Errors().
+func WithTransform(f TransformFunc) Options {
+	return func(c *config) {
+		c.transform = f
 	}
 }
 
-// process object from input channel, it will performs transformation and filter
-func (ch *ChannX) processObjectFromInput(v interface{}) bool {
-	if ch.buffer.IsEmpty() {
-		// try to send v through channel directly
-		select {
-		case ch.out <- v:
-			return true
-		default:
-			// output channel is full, put item to buffer
-		}
-	}
-
-	// try send to buffer
-	if !ch.mustPutToBuffer(v) {
-		ch.processTermination(v)
-		return false
+// WithFilter sets a predicate to filter items from input channel, the items
+// failing the predicate are silently dropped before they are sent to output
+// channel or ring buffer. The filter runs before transformation.
+func WithFilter(f FilterFunc) Options {
+	return func(c *config) {
+		c.filter = f
 	}
-	return true
 }
 
-func (ch *ChannX) processTermination(poped interface{}) {
-	close(ch.in)
-	defer close(ch.out)
-
-	if ch.cfg.dropClosedBufferData {
-		// drop all data after closed
-		ch.buffer.Reset()
-		return
-	}
-
-	// We need to send data still in ringbuffer and
-	// input channel buffer sequentially
-
-	// send all item in buffer
-	for !ch.buffer.IsEmpty() {
-		v, _ := ch.buffer.Pop()
-		ch.out <- v
-	}
-	ch.buffer.Reset()
-
-	// poped is the latest poped item from input channel
-	// it should be processed before others in channel buffer
-	if poped != nil {
-		ch.out <- poped
-	}
-
-	// send all item in input channel
-	for v := range ch.in {
-		ch.out <- v
+// WithShrinkPolicy sets how the ring buffer shrinks after it is drained.
+// By default, the buffer is reset to its initial size as soon as it becomes
+// empty, which may cause frequent reallocation under bursty input.
+func WithShrinkPolicy(policy ShrinkPolicy) Options {
+	return func(c *config) {
+		c.shrinkPolicy = policy
 	}
 }
 
-// Try to put item into buffer.
-// If buffer is full, it wait util the peek of buffer is sent
-// to output channel.
-func (ch *ChannX) mustPutToBuffer(v interface{}) bool {
-	if ch.buffer.Put(v) {
-		return true
-	}
-
-	// buffer is full
-	peek, _ := ch.buffer.Peek()
-
-	select {
-	case ch.out <- peek:
-	case <-ch.close:
-		return false
+func newDefuerConfig() *config {
+	return &config{
+		initBufferSize:       2,
+		dropClosedBufferData: false,
 	}
-
-	ch.buffer.Pop() //nolint
-	ch.buffer.Put(v)
-	return true
-}
-
-func (ch *ChannX) In() chan<- interface{} {
-	return ch.in
 }
 
-func (ch *ChannX) Out() <-chan interface{} {
-	return ch.out
-}
+// ChannX is a self adaptive channel with a ring buffer.
+// The channel buffer capacity will automatically increase according
+// to excessive input and restore to original when buffer is empty.
+// It can be used as an Unbounded Channel.
+//
+// ChannX is the interface{} version of ChannXOf.
+type ChannX = ChannXOf[interface{}]
 
-func (ch *ChannX) Close() {
-	ch.clsoeOnce.Do(func() {
-		close(ch.close)
-	})
+// New creates a ChannX with options
+func New(opts ...Options) *ChannX {
+	return NewOf[interface{}](opts...)
 }
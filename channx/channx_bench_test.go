@@ -105,6 +105,37 @@ func BenchmarkChanx10000(b *testing.B) {
 	benchmarkChannel(b, ch.In(), ch.Out(), ch.Close)
 }
 
+func BenchmarkChanxBurstyReset(b *testing.B) {
+	ch := New(InChanSize(0), OutChanSzie(0), InitBufferSize(2))
+	benchmarkBursty(b, ch, 256)
+}
+
+func BenchmarkChanxBurstyIdleCycles(b *testing.B) {
+	ch := New(InChanSize(0), OutChanSzie(0), InitBufferSize(2), WithShrinkPolicy(ShrinkPolicy{IdleCycles: 16}))
+	benchmarkBursty(b, ch, 256)
+}
+
+func BenchmarkChanxBurstyHalve(b *testing.B) {
+	ch := New(InChanSize(0), OutChanSzie(0), InitBufferSize(2), WithShrinkPolicy(ShrinkPolicy{IdleCycles: 16, Halve: true}))
+	benchmarkBursty(b, ch, 256)
+}
+
+// benchmarkBursty sends a burst of items without receiving, so they are
+// all kept in ring buffer, and then drains the channel.
+func benchmarkBursty(b *testing.B, ch *ChannX, burst int) {
+	defer ch.Close()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < burst; j++ {
+			ch.In() <- j
+		}
+		for j := 0; j < burst; j++ {
+			<-ch.Out()
+		}
+	}
+}
+
 func benchmarkChannel(b *testing.B, in chan<- interface{}, out <-chan interface{}, closeFn func()) {
 	wg := sync.WaitGroup{}
 	wg.Add(2)
@@ -15,6 +15,9 @@
 package chanx
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"reflect"
 	"sync"
 	"testing"
@@ -84,6 +87,56 @@ func TestChanX_Close(t *testing.T) {
 	}
 }
 
+func TestChanX_Drain(t *testing.T) {
+	ch := New(InChanSize(5), OutChanSzie(5), InitBufferSize(2))
+	want := []interface{}{}
+	for i := 0; i < 50; i++ {
+		ch.In() <- i
+		want = append(want, i)
+	}
+
+	got := ch.Drain()
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("ChannX.Drain() = %v, want %v", got, want)
+	}
+	if _, ok := <-ch.Out(); ok {
+		t.Errorf("output channel should be closed")
+	}
+}
+
+func TestChanX_Send(t *testing.T) {
+	ch := New(InChanSize(0), OutChanSzie(0), InitBufferSize(1), MaxBufferSize(1))
+	defer ch.Close()
+
+	// one item is kept in buffer and one is waiting to be sent to output
+	for i := 0; i < 2; i++ {
+		if err := ch.Send(context.Background(), i); err != nil {
+			t.Fatalf("ChannX.Send() error = %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := ch.Send(ctx, 2); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("ChannX.Send() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if err := ch.Send(ctx, 2); !errors.Is(err, context.Canceled) {
+		t.Errorf("ChannX.Send() error = %v, want %v", err, context.Canceled)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("ChannX.Send() should return promptly, took %v", d)
+	}
+
+	ch.Close()
+	if err := ch.Send(context.Background(), 3); !errors.Is(err, ErrClosed) {
+		t.Errorf("ChannX.Send() error = %v, want %v", err, ErrClosed)
+	}
+}
+
 func TestChanX_Full(t *testing.T) {
 	// input ->  buffer -> output
 	//   1    +    1    +    1    =  3 + 1(poped)
@@ -312,91 +365,191 @@ func TestChanX_ProducerFirst(t *testing.T) {
 	}
 }
 
-// func TestChanX_Transform(t *testing.T) {
-// 	tests := []struct {
-// 		name   string
-// 		ch     *ChannX
-// 		input  []interface{}
-// 		output []interface{}
-// 	}{
-// 		{
-// 			name: "transform int to uint",
-// 			ch: New(
-// 				InChanSize(0),
-// 				OutChanSzie(0),
-// 				InitBufferSize(1),
-// 				MaxBufferSize(1),
-// 				WithTransform(func(i interface{}) (interface{}, error) {
-// 					intI, ok := i.(int)
-// 					if !ok {
-// 						return nil, fmt.Errorf("want int")
-// 					}
-// 					return uint(intI), nil
-// 				}),
-// 			),
-// 			input:  rangeIntSlice(0, 1000),
-// 			output: rangeUintSlice(0, 1000),
-// 		},
-// 		{
-// 			name: "transform add 1",
-// 			ch: New(
-// 				InChanSize(0),
-// 				OutChanSzie(0),
-// 				InitBufferSize(1),
-// 				MaxBufferSize(1),
-// 				WithTransform(func(i interface{}) (interface{}, error) {
-// 					intI, ok := i.(int)
-// 					if !ok {
-// 						return nil, fmt.Errorf("want int")
-// 					}
-// 					return intI + 1, nil
-// 				}),
-// 			),
-// 			input:  rangeIntSlice(0, 1000),
-// 			output: rangeIntSlice(1, 1001),
-// 		},
-// 	}
-// 	for i := range tests {
-// 		tt := tests[i]
-// 		t.Run(tt.name, func(t *testing.T) {
-// 			testSequenceScenario1CustomerFirst(t, tt.ch, tt.input, tt.output)
-// 		})
-// 	}
-// }
-
-// func TestChanX_Filter(t *testing.T) {
-// 	tests := []struct {
-// 		name   string
-// 		ch     *ChannX
-// 		input  []interface{}
-// 		output []interface{}
-// 	}{
-// 		{
-// 			name: "filter i < 500",
-// 			ch: New(
-// 				InChanSize(0),
-// 				OutChanSzie(0),
-// 				InitBufferSize(1),
-// 				MaxBufferSize(1),
-// 				WithFilter(func(i interface{}) bool {
-// 					intI, ok := i.(int)
-// 					if !ok {
-// 						return false
-// 					}
-// 					return intI < 500
-// 				}),
-// 			),
-// 			input:  rangeIntSlice(0, 1000),
-// 			output: rangeIntSlice(0, 500),
-// 		},
-// 	}
-// 	for i := range tests {
-// 		tt := tests[i]
-// 		t.Run(tt.name, func(t *testing.T) {
-// 			testSequenceScenario1CustomerFirst(t, tt.ch, tt.input, tt.output)
-// 		})
-// 	}
-// }
+func TestChanX_LenCap(t *testing.T) {
+	ch := New(
+		InChanSize(0),
+		OutChanSzie(0),
+		InitBufferSize(2),
+	)
+	if ch.Len() != 0 {
+		t.Errorf("Len() = %v, want 0", ch.Len())
+	}
+	if ch.Cap() != 2 {
+		t.Errorf("Cap() = %v, want 2", ch.Cap())
+	}
+
+	prevLen := 0
+	for i := 0; i < 100; i++ {
+		ch.In() <- i
+		// the latest item may be still processing
+		l := ch.Len()
+		if l < prevLen {
+			t.Errorf("Len() = %v should not be less than %v", l, prevLen)
+		}
+		prevLen = l
+	}
+
+	if !waitFor(func() bool { return ch.Len() == 100 }, time.Second) {
+		t.Errorf("Len() = %v, want 100", ch.Len())
+	}
+	if ch.Cap() < 100 {
+		t.Errorf("Cap() = %v, should not be less than 100", ch.Cap())
+	}
+
+	for i := 0; i < 100; i++ {
+		<-ch.Out()
+	}
+	if !waitFor(func() bool { return ch.Len() == 0 && ch.Cap() == 2 }, time.Second) {
+		t.Errorf("Len() = %v, Cap() = %v, want 0 and 2 after consumed", ch.Len(), ch.Cap())
+	}
+	ch.Close()
+}
+
+func waitFor(cond func() bool, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return true
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return cond()
+}
+
+func TestChanX_Transform(t *testing.T) {
+	tests := []struct {
+		name   string
+		ch     *ChannX
+		input  []interface{}
+		output []interface{}
+	}{
+		{
+			name: "transform int to uint",
+			ch: New(
+				InChanSize(0),
+				OutChanSzie(0),
+				InitBufferSize(1),
+				MaxBufferSize(1),
+				WithTransform(func(i interface{}) (interface{}, error) {
+					intI, ok := i.(int)
+					if !ok {
+						return nil, fmt.Errorf("want int")
+					}
+					return uint(intI), nil
+				}),
+			),
+			input:  rangeIntSlice(0, 1000),
+			output: rangeUintSlice(0, 1000),
+		},
+		{
+			name: "transform add 1",
+			ch: New(
+				InChanSize(0),
+				OutChanSzie(0),
+				InitBufferSize(1),
+				MaxBufferSize(1),
+				WithTransform(func(i interface{}) (interface{}, error) {
+					intI, ok := i.(int)
+					if !ok {
+						return nil, fmt.Errorf("want int")
+					}
+					return intI + 1, nil
+				}),
+			),
+			input:  rangeIntSlice(0, 1000),
+			output: rangeIntSlice(1, 1001),
+		},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			testSequenceScenario1CustomerFirst(t, tt.ch, tt.input, tt.output)
+		})
+	}
+}
+
+func TestChanX_TransformError(t *testing.T) {
+	ch := New(
+		WithTransform(func(i interface{}) (interface{}, error) {
+			intI, ok := i.(int)
+			if !ok {
+				return nil, fmt.Errorf("want int")
+			}
+			return intI + 1, nil
+		}),
+	)
+	ch.In() <- 1
+	ch.In() <- "a"
+	ch.In() <- 2
+	ch.Close()
+
+	got := []interface{}{}
+	for v := range ch.Out() {
+		got = append(got, v)
+	}
+	if want := []interface{}{2, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("get from output channel want = %v, got = %v", want, got)
+	}
+
+	errs := []error{}
+	for err := range ch.Errors() {
+		errs = append(errs, err)
+	}
+	if len(errs) != 1 || errs[0].Error() != "chanx: failed to transform a: want int" {
+		t.Errorf("unexpected errors %v", errs)
+	}
+}
+
+func TestChanX_Filter(t *testing.T) {
+	tests := []struct {
+		name   string
+		ch     *ChannX
+		input  []interface{}
+		output []interface{}
+	}{
+		{
+			name: "filter i < 500",
+			ch: New(
+				InChanSize(0),
+				OutChanSzie(0),
+				InitBufferSize(1),
+				MaxBufferSize(1),
+				WithFilter(func(i interface{}) bool {
+					intI, ok := i.(int)
+					if !ok {
+						return false
+					}
+					return intI < 500
+				}),
+			),
+			input:  rangeIntSlice(0, 1000),
+			output: rangeIntSlice(0, 500),
+		},
+		{
+			name: "filter and transform",
+			ch: New(
+				InChanSize(0),
+				OutChanSzie(0),
+				InitBufferSize(1),
+				MaxBufferSize(1),
+				WithFilter(func(i interface{}) bool {
+					return i.(int)%2 == 0
+				}),
+				WithTransform(func(i interface{}) (interface{}, error) {
+					return i.(int) / 2, nil
+				}),
+			),
+			input:  rangeIntSlice(0, 1000),
+			output: rangeIntSlice(0, 500),
+		},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			testSequenceScenario1CustomerFirst(t, tt.ch, tt.input, tt.output)
+		})
+	}
+}
 
 func testSequenceScenario1CustomerFirst(t *testing.T, ch *ChannX, input, output []interface{}) {
 	wg := sync.WaitGroup{}
@@ -463,6 +616,14 @@ func testSequenceScenario1ProducerFirst(t *testing.T, ch *ChannX, input, output
 	wg.Wait()
 }
 
+func rangeUintSlice(start, end int) []interface{} {
+	ret := []interface{}{}
+	for i := start; i < end; i++ {
+		ret = append(ret, uint(i))
+	}
+	return ret
+}
+
 func rangeIntSlice(start, end int) []interface{} {
 	ret := []interface{}{}
 	for i := start; i < end; i++ {
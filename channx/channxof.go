@@ -0,0 +1,332 @@
+// Copyright 2023 jim.zoumo@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chanx
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// ChannXOf is a generic self adaptive channel with a ring buffer.
+// The channel buffer capacity will automatically increase according
+// to excessive input and restore to original when buffer is empty.
+// It can be used as an Unbounded Channel.
+//
+// The options are shared with ChannX, the function set by WithTransform
+// must return a value of type T.
+type ChannXOf[T any] struct {
+	// bufferLen and bufferCap mirror the ring buffer's Len() and Cap(),
+	// they are updated in process goroutine and read atomically.
+	// Keep them at the top for 64-bit alignment.
+	bufferLen int64
+	bufferCap int64
+
+	in        chan T
+	out       chan T
+	close     chan struct{}
+	errs      chan error
+	clsoeOnce sync.Once
+	inMu      sync.RWMutex // guards send() against closing in
+	cfg       *config
+	buffer    *SelfAdaptiveRingBuffer
+}
+
+// NewOf creates a ChannXOf with options
+func NewOf[T any](opts ...Options) *ChannXOf[T] {
+	cfg := newDefuerConfig()
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	ch := &ChannXOf[T]{
+		cfg:   cfg,
+		close: make(chan struct{}),
+		errs:  make(chan error, errChanSize),
+	}
+	ch.in = make(chan T, cfg.inChanSize)
+	ch.out = make(chan T, cfg.outChanSize)
+	ch.buffer = NewSelfAdptiveRingBuffer(cfg.initBufferSize, cfg.maxBufferSize)
+	ch.buffer.SetShrinkPolicy(cfg.shrinkPolicy)
+	ch.updateBufferStats()
+
+	go ch.process()
+	return ch
+}
+
+func (ch *ChannXOf[T]) process() {
+	var v T
+	var ok bool
+	for {
+		// buffer is empty
+		select {
+		case v, ok = <-ch.in:
+			if !ok {
+				panic("chanx: input channel can not be closed")
+			}
+			if !ch.processObjectFromInput(v) {
+				return
+			}
+		case <-ch.close:
+			ch.processTermination(nil)
+			return
+		}
+
+		// v has already processed
+		// we need to deal with objects in buffer
+
+		for !ch.buffer.IsEmpty() {
+			peek := ch.peekBuffer()
+			select {
+			case v, ok = <-ch.in:
+				if !ok {
+					panic("chanx: input channel can not be closed")
+				}
+				if !ch.processObjectFromInput(v) {
+					return
+				}
+			case ch.out <- peek:
+				ch.buffer.Pop() // nolint
+				ch.buffer.Shrink()
+				ch.updateBufferStats()
+			case <-ch.close:
+				ch.processTermination(nil)
+				return
+			}
+		}
+	}
+}
+
+// process object from input channel, it will performs transformation and filter
+func (ch *ChannXOf[T]) processObjectFromInput(v T) bool {
+	v, ok := ch.transform(v)
+	if !ok {
+		// dropped
+		return true
+	}
+
+	if ch.buffer.IsEmpty() {
+		// try to send v through channel directly
+		select {
+		case ch.out <- v:
+			return true
+		default:
+			// output channel is full, put item to buffer
+		}
+	}
+
+	// try send to buffer
+	if !ch.mustPutToBuffer(v) {
+		ch.processTermination(&v)
+		return false
+	}
+	ch.updateBufferStats()
+	return true
+}
+
+func (ch *ChannXOf[T]) processTermination(poped *T) {
+	ch.inMu.Lock()
+	close(ch.in)
+	ch.inMu.Unlock()
+	defer close(ch.errs)
+	defer close(ch.out)
+
+	if ch.cfg.dropClosedBufferData {
+		// drop all data after closed
+		ch.buffer.Reset()
+		ch.updateBufferStats()
+		return
+	}
+
+	// We need to send data still in ringbuffer and
+	// input channel buffer sequentially
+
+	// send all item in buffer
+	for !ch.buffer.IsEmpty() {
+		v := ch.peekBuffer()
+		ch.buffer.Pop() //nolint
+		ch.updateBufferStats()
+		ch.out <- v
+	}
+	ch.buffer.Reset()
+	ch.updateBufferStats()
+
+	// poped is the latest poped item from input channel
+	// it should be processed before others in channel buffer
+	if poped != nil {
+		ch.out <- *poped
+	}
+
+	// send all item in input channel
+	for v := range ch.in {
+		if v, ok := ch.transform(v); ok {
+			ch.out <- v
+		}
+	}
+}
+
+// transform applies the filter and transform function to v, it returns
+// false if v should be dropped.
+func (ch *ChannXOf[T]) transform(v T) (T, bool) {
+	var zero T
+	if ch.cfg.filter != nil && !ch.cfg.filter(v) {
+		return zero, false
+	}
+	if ch.cfg.transform == nil {
+		return v, true
+	}
+	ret, err := ch.cfg.transform(v)
+	if err != nil {
+		ch.reportError(fmt.Errorf("chanx: failed to transform %v: %w", v, err))
+		return zero, false
+	}
+	if ret == nil {
+		return zero, true
+	}
+	t, ok := ret.(T)
+	if !ok {
+		ch.reportError(fmt.Errorf("chanx: transformed item %v is %T, not %T", ret, ret, zero))
+		return zero, false
+	}
+	return t, true
+}
+
+// peekBuffer returns the peek of ring buffer as T, the ring buffer must
+// not be empty.
+func (ch *ChannXOf[T]) peekBuffer() T {
+	peek, _ := ch.buffer.Peek()
+	// the zero value is returned for a nil interface{}
+	t, _ := peek.(T)
+	return t
+}
+
+// reportError sends err to the errors channel without blocking, the error
+// is discarded if the channel is full.
+func (ch *ChannXOf[T]) reportError(err error) {
+	select {
+	case ch.errs <- err:
+	default:
+	}
+}
+
+// Try to put item into buffer.
+// If buffer is full, it wait util the peek of buffer is sent
+// to output channel.
+func (ch *ChannXOf[T]) mustPutToBuffer(v T) bool {
+	if ch.buffer.Put(v) {
+		return true
+	}
+
+	// buffer is full
+	peek := ch.peekBuffer()
+
+	select {
+	case ch.out <- peek:
+	case <-ch.close:
+		return false
+	}
+
+	ch.buffer.Pop() //nolint
+	ch.buffer.Put(v)
+	return true
+}
+
+// updateBufferStats syncs the ring buffer's length and capacity so that
+// they can be read concurrently by Len() and Cap().
+func (ch *ChannXOf[T]) updateBufferStats() {
+	atomic.StoreInt64(&ch.bufferLen, int64(ch.buffer.Len()))
+	atomic.StoreInt64(&ch.bufferCap, int64(ch.buffer.Cap()))
+}
+
+// send sends v to input channel, it returns false if the channel is closed
+// before v is sent.
+func (ch *ChannXOf[T]) send(v T) bool {
+	return ch.Send(context.Background(), v) == nil
+}
+
+// Send sends v to input channel, it blocks until v is sent, ctx is done or
+// the channel is closed. It returns ctx.Err() if ctx is done and ErrClosed
+// if the channel is closed before v is sent.
+//
+// Unlike sending to In() directly, it is safe to be called concurrently
+// with Close().
+func (ch *ChannXOf[T]) Send(ctx context.Context, v T) error {
+	ch.inMu.RLock()
+	defer ch.inMu.RUnlock()
+	// the input channel is only closed after close channel is closed,
+	// check it first to avoid sending to a closed channel.
+	select {
+	case <-ch.close:
+		return ErrClosed
+	default:
+	}
+	select {
+	case ch.in <- v:
+		return nil
+	case <-ch.close:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (ch *ChannXOf[T]) In() chan<- T {
+	return ch.in
+}
+
+func (ch *ChannXOf[T]) Out() <-chan T {
+	return ch.out
+}
+
+// Len returns the number of pending items, including items in input
+// channel buffer, ring buffer and output channel buffer.
+// It is safe to be called concurrently.
+func (ch *ChannXOf[T]) Len() int {
+	return len(ch.in) + int(atomic.LoadInt64(&ch.bufferLen)) + len(ch.out)
+}
+
+// Cap returns the current capacity of the ring buffer.
+// It is safe to be called concurrently.
+func (ch *ChannXOf[T]) Cap() int {
+	return int(atomic.LoadInt64(&ch.bufferCap))
+}
+
+// Errors returns a channel which receives errors occurred when processing
+// items, e.g. the errors returned by the transform function.
+//
+// The channel is buffered, errors are discarded if it is full. It is closed
+// after the output channel is closed.
+func (ch *ChannXOf[T]) Errors() <-chan error {
+	return ch.errs
+}
+
+func (ch *ChannXOf[T]) Close() {
+	ch.clsoeOnce.Do(func() {
+		close(ch.close)
+	})
+}
+
+// Drain closes the channel and collects all remaining items in order.
+// It blocks until the output channel is closed. Items in ring buffer are
+// dropped if DropClosedBufferData is set.
+func (ch *ChannXOf[T]) Drain() []T {
+	ch.Close()
+	ret := []T{}
+	for v := range ch.out {
+		ret = append(ret, v)
+	}
+	return ret
+}
@@ -0,0 +1,162 @@
+// Copyright 2023 jim.zoumo@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chanx
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestChanXOf_CustomerFirst(t *testing.T) {
+	tests := []struct {
+		name   string
+		ch     *ChannXOf[int]
+		input  []int
+		output []int
+	}{
+		{
+			name: "no channel buffer",
+			ch: NewOf[int](
+				InChanSize(0),
+				OutChanSzie(0),
+				InitBufferSize(1),
+				MaxBufferSize(1),
+			),
+			input:  rangeInts(0, 1000),
+			output: rangeInts(0, 1000),
+		},
+		{
+			name: "no input channel buffer",
+			ch: NewOf[int](
+				InChanSize(0),
+				OutChanSzie(1),
+				InitBufferSize(1),
+				MaxBufferSize(1),
+			),
+			input:  rangeInts(0, 1000),
+			output: rangeInts(0, 1000),
+		},
+		{
+			name: "with small channel buffer",
+			ch: NewOf[int](
+				InChanSize(1),
+				OutChanSzie(1),
+				InitBufferSize(1),
+				MaxBufferSize(1),
+			),
+			input:  rangeInts(0, 1000),
+			output: rangeInts(0, 1000),
+		},
+		{
+			name: "with medium channel buffer",
+			ch: NewOf[int](
+				InChanSize(2),
+				OutChanSzie(2),
+				InitBufferSize(2),
+				MaxBufferSize(6),
+			),
+			input:  rangeInts(0, 1000),
+			output: rangeInts(0, 1000),
+		},
+		{
+			name: "with large channel buffer",
+			ch: NewOf[int](
+				InChanSize(10),
+				OutChanSzie(10),
+				InitBufferSize(10),
+				MaxBufferSize(100),
+			),
+			input:  rangeInts(0, 1000),
+			output: rangeInts(0, 1000),
+		},
+		{
+			name: "unbounded buffer",
+			ch: NewOf[int](
+				InChanSize(0),
+				OutChanSzie(0),
+			),
+			input:  rangeInts(0, 1000),
+			output: rangeInts(0, 1000),
+		},
+		{
+			name: "filter and transform",
+			ch: NewOf[int](
+				WithFilter(func(i interface{}) bool {
+					return i.(int) < 500
+				}),
+				WithTransform(func(i interface{}) (interface{}, error) {
+					return i.(int) + 1, nil
+				}),
+			),
+			input:  rangeInts(0, 1000),
+			output: rangeInts(1, 501),
+		},
+	}
+
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			wg := sync.WaitGroup{}
+			wg.Add(2)
+			got := []int{}
+			go func() {
+				defer wg.Done()
+				for v := range tt.ch.Out() {
+					got = append(got, v)
+				}
+			}()
+			go func() {
+				defer wg.Done()
+				for _, v := range tt.input {
+					tt.ch.In() <- v
+				}
+				tt.ch.Close()
+			}()
+			wg.Wait()
+
+			if !reflect.DeepEqual(got, tt.output) {
+				t.Errorf("get from output channel want = %v, got = %v", tt.output, got)
+			}
+		})
+	}
+}
+
+func TestChanXOf_TransformTypeMismatch(t *testing.T) {
+	ch := NewOf[int](
+		WithTransform(func(i interface{}) (interface{}, error) {
+			return fmt.Sprint(i), nil
+		}),
+	)
+	ch.In() <- 1
+	ch.Close()
+
+	for v := range ch.Out() {
+		t.Errorf("unexpected item %v", v)
+	}
+	err, ok := <-ch.Errors()
+	if !ok || err.Error() != "chanx: transformed item 1 is string, not int" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func rangeInts(start, end int) []int {
+	ret := []int{}
+	for i := start; i < end; i++ {
+		ret = append(ret, i)
+	}
+	return ret
+}
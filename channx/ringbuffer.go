@@ -22,6 +22,19 @@ const (
 	growThreshold = 1024
 )
 
+// ShrinkPolicy decides when and how the ring buffer shrinks after it is
+// drained. The zero value resets the buffer to its initial size as soon as
+// it becomes empty.
+type ShrinkPolicy struct {
+	// IdleCycles is the number of consecutive drains, in which the buffer
+	// does not need its current capacity, before shrinking.
+	// 0 or 1 means shrinking at the first drain.
+	IdleCycles int
+	// Halve shrinks the buffer to the half of its size instead of the
+	// initial size.
+	Halve bool
+}
+
 type SelfAdaptiveRingBuffer struct {
 	buf      []interface{}
 	maxSize  int
@@ -30,6 +43,10 @@ type SelfAdaptiveRingBuffer struct {
 	r        int // read position
 	w        int // write position
 	full     bool
+
+	shrinkPolicy ShrinkPolicy
+	idleCycles   int // consecutive drains without needing current capacity
+	peakLen      int // the max length since last drain
 }
 
 // NewSelfAdptiveRingBuffer creates a self adaptive ringbuffer with init and max size.
@@ -73,6 +90,10 @@ func (rb *SelfAdaptiveRingBuffer) Put(v interface{}) bool {
 			rb.full = true
 		}
 	}
+
+	if l := rb.Len(); l > rb.peakLen {
+		rb.peakLen = l
+	}
 	return true
 }
 
@@ -202,7 +223,49 @@ func (rb *SelfAdaptiveRingBuffer) Cap() int {
 }
 
 func (rb *SelfAdaptiveRingBuffer) Reset() {
+	rb.resize(rb.initSize)
+}
+
+// SetShrinkPolicy sets the policy used by Shrink.
+func (rb *SelfAdaptiveRingBuffer) SetShrinkPolicy(policy ShrinkPolicy) {
+	rb.shrinkPolicy = policy
+}
+
+// Shrink shrinks the buffer according to the shrink policy if it has been
+// drained, it should be called after Pop. It returns true if the buffer
+// is shrunk.
+func (rb *SelfAdaptiveRingBuffer) Shrink() bool {
+	if !rb.NeedReset() {
+		return false
+	}
+
+	target := rb.initSize
+	if rb.shrinkPolicy.Halve && rb.size/2 > target {
+		target = rb.size / 2
+	}
+
+	if rb.peakLen > target {
+		// the current capacity was needed in this cycle
+		rb.idleCycles = 0
+	} else {
+		rb.idleCycles++
+	}
+	rb.peakLen = 0
+
+	if rb.shrinkPolicy.IdleCycles > 1 && rb.idleCycles < rb.shrinkPolicy.IdleCycles {
+		return false
+	}
+
+	rb.resize(target)
+	return true
+}
+
+// resize drops all data in buffer and reallocates it with the given size.
+func (rb *SelfAdaptiveRingBuffer) resize(size int) {
 	rb.r, rb.w = 0, 0
-	rb.size = rb.initSize
-	rb.buf = make([]interface{}, rb.initSize)
+	rb.full = false
+	rb.size = size
+	rb.buf = make([]interface{}, size)
+	rb.idleCycles = 0
+	rb.peakLen = 0
 }
@@ -340,3 +340,68 @@ func TestSelfAdaptiveRingBuffer_Pop(t *testing.T) {
 		t.Errorf("ring buffer must be empty")
 	}
 }
+
+func TestSelfAdaptiveRingBuffer_Shrink(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  ShrinkPolicy
+		bursts  []int
+		wantCap []int
+	}{
+		{
+			name:    "reset immediately by default",
+			policy:  ShrinkPolicy{},
+			bursts:  []int{10, 1, 10},
+			wantCap: []int{2, 2, 2},
+		},
+		{
+			name:    "reset after idle cycles",
+			policy:  ShrinkPolicy{IdleCycles: 3},
+			bursts:  []int{10, 1, 1, 1, 1},
+			wantCap: []int{16, 16, 16, 2, 2},
+		},
+		{
+			name:    "busy cycles restart counting",
+			policy:  ShrinkPolicy{IdleCycles: 2},
+			bursts:  []int{10, 10, 1, 1},
+			wantCap: []int{16, 16, 16, 2},
+		},
+		{
+			name:    "one idle cycle resets immediately",
+			policy:  ShrinkPolicy{IdleCycles: 1},
+			bursts:  []int{10, 1, 10},
+			wantCap: []int{2, 2, 2},
+		},
+		{
+			name:    "halve",
+			policy:  ShrinkPolicy{Halve: true},
+			bursts:  []int{10, 1, 1, 1},
+			wantCap: []int{8, 4, 2, 2},
+		},
+		{
+			name:    "halve after idle cycles",
+			policy:  ShrinkPolicy{IdleCycles: 2, Halve: true},
+			bursts:  []int{10, 1, 1, 1, 1},
+			wantCap: []int{16, 16, 8, 8, 4},
+		},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			rb := NewSelfAdptiveRingBuffer(2, 0)
+			rb.SetShrinkPolicy(tt.policy)
+			for j, n := range tt.bursts {
+				for k := 0; k < n; k++ {
+					rb.Put(k)
+				}
+				for !rb.IsEmpty() {
+					rb.Pop() //nolint
+					rb.Shrink()
+				}
+				if got := rb.Cap(); got != tt.wantCap[j] {
+					t.Errorf("SelfAdaptiveRingBuffer.Cap() after burst %d = %v, want %v", j, got, tt.wantCap[j])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright 2023 jim.zoumo@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chanx
+
+import (
+	"time"
+)
+
+// ThrottleMode defines how Throttle deals with the items received within
+// the interval after an item is emitted.
+type ThrottleMode int
+
+const (
+	// ThrottleCoalesce keeps the latest item received within the interval
+	// and emits it when the interval elapses.
+	ThrottleCoalesce ThrottleMode = iota
+	// ThrottleDrop drops all items received within the interval.
+	ThrottleDrop
+)
+
+// Throttle returns a new channel fed by items from ch.Out(), it emits at most
+// one item per minInterval. The items received within the interval are
+// coalesced or dropped according to the mode.
+//
+// The returned channel is closed after ch is closed, the last pending item is
+// flushed before that.
+func (ch *ChannXOf[T]) Throttle(minInterval time.Duration, mode ThrottleMode) *ChannXOf[T] {
+	stage := NewOf[T]()
+	go func() {
+		var (
+			zero       T
+			last       time.Time
+			pending    T
+			hasPending bool
+			timer      *time.Timer
+			timerC     <-chan time.Time
+		)
+		defer func() {
+			if timer != nil {
+				timer.Stop()
+			}
+		}()
+
+		for {
+			select {
+			case v, ok := <-ch.Out():
+				if !ok {
+					if hasPending {
+						stage.send(pending)
+					}
+					stage.Close()
+					return
+				}
+				now := time.Now()
+				if timer == nil && now.Sub(last) >= minInterval {
+					if !stage.send(v) {
+						return
+					}
+					last = now
+					continue
+				}
+				if mode == ThrottleDrop {
+					continue
+				}
+				pending, hasPending = v, true
+				if timer == nil {
+					timer = time.NewTimer(minInterval - now.Sub(last))
+					timerC = timer.C
+				}
+			case <-timerC:
+				timer, timerC = nil, nil
+				if hasPending {
+					if !stage.send(pending) {
+						return
+					}
+					pending, hasPending = zero, false
+					last = time.Now()
+				}
+			}
+		}
+	}()
+	return stage
+}
+
+// Debounce returns a new channel fed by items from ch.Out(), it emits the
+// latest item only after no item is received for the wait duration.
+//
+// The returned channel is closed after ch is closed, the last pending item is
+// flushed before that.
+func (ch *ChannXOf[T]) Debounce(wait time.Duration) *ChannXOf[T] {
+	stage := NewOf[T]()
+	go func() {
+		var (
+			zero       T
+			pending    T
+			hasPending bool
+			timer      *time.Timer
+			timerC     <-chan time.Time
+		)
+		defer func() {
+			if timer != nil {
+				timer.Stop()
+			}
+		}()
+
+		for {
+			select {
+			case v, ok := <-ch.Out():
+				if !ok {
+					if hasPending {
+						stage.send(pending)
+					}
+					stage.Close()
+					return
+				}
+				pending, hasPending = v, true
+				if timer != nil {
+					timer.Stop()
+				}
+				timer = time.NewTimer(wait)
+				timerC = timer.C
+			case <-timerC:
+				timer, timerC = nil, nil
+				if !stage.send(pending) {
+					return
+				}
+				pending, hasPending = zero, false
+			}
+		}
+	}()
+	return stage
+}
+
+// dedupEntry records when an item with the key was emitted by Dedup.
+type dedupEntry struct {
+	key string
+	at  time.Time
+}
+
+// Dedup returns a new channel fed by items from ch.Out(), it drops the items
+// whose key returned by keyFn has been emitted within the window.
+//
+// Keys are evicted once their window elapses, so the memory is bounded by
+// the number of distinct keys emitted within one window.
+//
+// The returned channel is closed after ch is closed.
+func (ch *ChannXOf[T]) Dedup(keyFn func(T) string, window time.Duration) *ChannXOf[T] {
+	stage := NewOf[T]()
+	go func() {
+		// seen maps keys to the time they were last emitted, entries keeps
+		// the same records ordered by time for eviction.
+		seen := map[string]time.Time{}
+		entries := []dedupEntry{}
+
+		for v := range ch.Out() {
+			now := time.Now()
+			// evict expired keys
+			i := 0
+			for ; i < len(entries) && now.Sub(entries[i].at) >= window; i++ {
+				delete(seen, entries[i].key)
+			}
+			entries = entries[i:]
+
+			key := keyFn(v)
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			if !stage.send(v) {
+				return
+			}
+			seen[key] = now
+			entries = append(entries, dedupEntry{key: key, at: now})
+		}
+		stage.Close()
+	}()
+	return stage
+}
@@ -0,0 +1,159 @@
+// Copyright 2023 jim.zoumo@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chanx
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type timedItem struct {
+	v  interface{}
+	at time.Duration
+}
+
+// collect reads all items from ch in background until it is closed
+func collect(ch *ChannX, start time.Time) <-chan []timedItem {
+	result := make(chan []timedItem, 1)
+	go func() {
+		items := []timedItem{}
+		for v := range ch.Out() {
+			items = append(items, timedItem{v: v, at: time.Since(start)})
+		}
+		result <- items
+	}()
+	return result
+}
+
+func itemValues(items []timedItem) []interface{} {
+	ret := []interface{}{}
+	for _, item := range items {
+		ret = append(ret, item.v)
+	}
+	return ret
+}
+
+func TestChanX_Throttle(t *testing.T) {
+	interval := 100 * time.Millisecond
+	tests := []struct {
+		name string
+		mode ThrottleMode
+		want []interface{}
+	}{
+		{"coalesce", ThrottleCoalesce, []interface{}{0, 9, 10}},
+		{"drop", ThrottleDrop, []interface{}{0, 10}},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			src := New()
+			throttled := src.Throttle(interval, tt.mode)
+			start := time.Now()
+			result := collect(throttled, start)
+			// burst
+			for i := 0; i < 10; i++ {
+				src.In() <- i
+			}
+			time.Sleep(3 * interval)
+			src.In() <- 10
+			src.Close()
+
+			got := <-result
+			if !reflect.DeepEqual(itemValues(got), tt.want) {
+				t.Fatalf("Throttle() = %v, want %v", itemValues(got), tt.want)
+			}
+			if got[0].at > interval/2 {
+				t.Errorf("the first item should be emitted immediately, got %v", got[0].at)
+			}
+			for i := 1; i < len(got); i++ {
+				if d := got[i].at - got[i-1].at; d < interval*9/10 {
+					t.Errorf("items %v and %v are emitted within %v", got[i-1].v, got[i].v, d)
+				}
+			}
+		})
+	}
+}
+
+func TestChanX_ThrottleFlushOnClose(t *testing.T) {
+	src := New()
+	throttled := src.Throttle(time.Hour, ThrottleCoalesce)
+	src.In() <- 1
+	src.In() <- 2
+	src.In() <- 3
+	src.Close()
+
+	got := itemValues(<-collect(throttled, time.Now()))
+	want := []interface{}{1, 3}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Throttle() = %v, want %v", got, want)
+	}
+}
+
+func TestChanX_Debounce(t *testing.T) {
+	wait := 50 * time.Millisecond
+	src := New()
+	debounced := src.Debounce(wait)
+	start := time.Now()
+	result := collect(debounced, start)
+
+	// first burst
+	var lastInput time.Duration
+	for i := 0; i < 5; i++ {
+		src.In() <- i
+		lastInput = time.Since(start)
+		time.Sleep(wait / 5)
+	}
+	time.Sleep(3 * wait)
+	// second burst, flushed by close
+	src.In() <- 5
+	src.In() <- 6
+	src.Close()
+
+	got := <-result
+	want := []interface{}{4, 6}
+	if !reflect.DeepEqual(itemValues(got), want) {
+		t.Fatalf("Debounce() = %v, want %v", itemValues(got), want)
+	}
+	if got[0].at < lastInput+wait {
+		t.Errorf("item should be emitted after a quiet period, emitted at %v, last input at %v", got[0].at, lastInput)
+	}
+}
+
+func TestChanX_Dedup(t *testing.T) {
+	window := 50 * time.Millisecond
+	src := New()
+	deduped := src.Dedup(func(v interface{}) string {
+		return v.(string)[:1]
+	}, window)
+	result := collect(deduped, time.Now())
+
+	// duplicate keys within the window
+	for _, v := range []string{"a1", "b1", "a2", "b2", "a3"} {
+		src.In() <- v
+	}
+	time.Sleep(2 * window)
+	// keys are expired after the window
+	for _, v := range []string{"a4", "b3", "c1", "a5"} {
+		src.In() <- v
+	}
+	src.Close()
+
+	got := itemValues(<-result)
+	want := []interface{}{"a1", "b1", "a4", "b3", "c1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Dedup() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright 2023 jim.zoumo@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"github.com/spf13/pflag"
+)
+
+// CommonOptions contains the options shared by subcommands.
+// Embed it by pointer in a subcommand and call its BindFlags in the
+// subcommand's BindFlags, then Run can check IsDryRun directly.
+type CommonOptions struct {
+	// DryRun indicates that the subcommand should only print what it
+	// would do without making any change.
+	DryRun bool
+}
+
+func NewCommonOptions() *CommonOptions {
+	return &CommonOptions{}
+}
+
+// BindFlags binds the common flags to the FlagSet
+func (o *CommonOptions) BindFlags(fs *pflag.FlagSet) {
+	fs.BoolVar(&o.DryRun, "dry-run", o.DryRun, "If true, only print the actions that would be taken, without performing them")
+}
+
+// IsDryRun returns true if --dry-run is set
+func (o *CommonOptions) IsDryRun() bool {
+	return o != nil && o.DryRun
+}
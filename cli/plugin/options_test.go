@@ -0,0 +1,68 @@
+// Copyright 2023 jim.zoumo@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+type dryRunSubcommand struct {
+	*CommonOptions
+	observed bool
+}
+
+func (c *dryRunSubcommand) Name() string {
+	return "test"
+}
+
+func (c *dryRunSubcommand) BindFlags(fs *pflag.FlagSet) {
+	c.CommonOptions.BindFlags(fs)
+}
+
+func (c *dryRunSubcommand) Run(args []string) error {
+	c.observed = c.IsDryRun()
+	return nil
+}
+
+func TestCommonOptions_DryRun(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want bool
+	}{
+		{"default", []string{}, false},
+		{"dry run", []string{"--dry-run"}, true},
+		{"dry run false", []string{"--dry-run=false"}, false},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			subcmd := &dryRunSubcommand{CommonOptions: NewCommonOptions()}
+			cmd := NewCobraSubcommandOrDie(subcmd)
+			cmd.SetArgs(tt.args)
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if subcmd.DryRun != tt.want {
+				t.Errorf("CommonOptions.DryRun = %v, want %v", subcmd.DryRun, tt.want)
+			}
+			if subcmd.observed != tt.want {
+				t.Errorf("Run() observed IsDryRun() = %v, want %v", subcmd.observed, tt.want)
+			}
+		})
+	}
+}
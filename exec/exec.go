@@ -15,17 +15,23 @@
 package exec
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"os/exec"
+	"strings"
 	"time"
+
+	"github.com/go-logr/logr"
 )
 
 var (
 	ErrExitedInRunForever = errors.New("exec: command should not exit in RunForever")
+	ErrTimeout            = errors.New("exec: command timed out")
 )
 
 type argsHolder struct {
@@ -59,16 +65,27 @@ func (c *ioHolder) GetIO() (in io.Reader, out, err io.Writer) {
 // running in shell: echo "3\n2\n1" | sort
 //
 // A Cmd cannot be reused after calling its Run, Output or CombinedOutput
-// methods.
+// methods until Reset is called.
 type Cmd struct {
 	ctx        context.Context
 	argsHolder *argsHolder
 	ioHolder   *ioHolder
 
-	cmdMutator func(name string, args []string) (string, []string)
+	cmdMutator  func(name string, args []string) (string, []string)
+	argTemplate map[string]string
+	namespaces  *NamespaceConfig
+	// teeWriters receive a copy of the standard output
+	teeWriters []io.Writer
+	// stdinRedirect and stdoutRedirect replace the pipe between stages
+	stdinRedirect  io.Reader
+	stdoutRedirect io.Writer
+	// stdinCloser is closed once the command is waited
+	stdinCloser io.Closer
 
 	runtimeCmd *exec.Cmd
-	preCmd     *Cmd
+	// argsErr records the error of rendering args with argTemplate
+	argsErr error
+	preCmd  *Cmd
 
 	started  bool
 	finished bool
@@ -102,10 +119,16 @@ func (c *Cmd) SetCmdMutator(f func(name string, args []string) (string, []string
 
 func (c *Cmd) copy() *Cmd {
 	newCmd := &Cmd{
-		ctx:        c.ctx,
-		argsHolder: c.argsHolder.Copy(),
-		ioHolder:   c.ioHolder,
-		cmdMutator: c.cmdMutator,
+		ctx:         c.ctx,
+		argsHolder:  c.argsHolder.Copy(),
+		ioHolder:    c.ioHolder,
+		cmdMutator:  c.cmdMutator,
+		argTemplate: c.argTemplate,
+		namespaces:  c.namespaces,
+		teeWriters:  c.teeWriters,
+
+		stdinRedirect:  c.stdinRedirect,
+		stdoutRedirect: c.stdoutRedirect,
 	}
 	if c.preCmd != nil {
 		newCmd.preCmd = c.preCmd.copy()
@@ -113,6 +136,34 @@ func (c *Cmd) copy() *Cmd {
 	return newCmd
 }
 
+// SetArgTemplate sets values for the {{name}} placeholders in the args of
+// every command in the pipeline. The placeholders are substituted when the
+// runtime command is created, a placeholder without value makes Start fail.
+//
+// Use \{{ to write a literal {{ in args.
+func (c *Cmd) SetArgTemplate(tmpl map[string]string) *Cmd {
+	for cmd := c; cmd != nil; cmd = cmd.preCmd {
+		cmd.argTemplate = tmpl
+	}
+	return c
+}
+
+// Reset clears the runtime state of the command and its pre commands so that
+// it can be run again. The name, args, IO, context and mutator are preserved.
+//
+// The IO set by SetIO is reused as is, so the reader and writers must be
+// resettable by the caller themselves, e.g. a consumed stdin reader will
+// not be rewound.
+func (c *Cmd) Reset() *Cmd {
+	for cmd := c; cmd != nil; cmd = cmd.preCmd {
+		cmd.runtimeCmd = nil
+		cmd.argsErr = nil
+		cmd.started = false
+		cmd.finished = false
+	}
+	return c
+}
+
 // Pipe creates a new command with given args and connects this command's
 // standard output to new command's standard input
 func (c *Cmd) Pipe(name string, args ...string) *Cmd {
@@ -123,8 +174,10 @@ func (c *Cmd) Pipe(name string, args ...string) *Cmd {
 			name: name,
 			args: args,
 		},
-		ioHolder:   c.ioHolder,
-		cmdMutator: c.cmdMutator,
+		ioHolder:    c.ioHolder,
+		cmdMutator:  c.cmdMutator,
+		argTemplate: c.argTemplate,
+		namespaces:  c.namespaces,
 	}
 	return nextCmd
 }
@@ -137,6 +190,97 @@ func (c *Cmd) SetIO(in io.Reader, out, err io.Writer) {
 	c.ioHolder.SetIO(in, out, err)
 }
 
+// Tee duplicates the standard output of command c to writers, like the tee
+// command, and the output can still be read by ReadStdout. It should be
+// called on the last command of a pipeline.
+//
+// All writers receive the same bytes, a writer returning an error does not
+// receive further output and does not affect the others.
+func Tee(c *Cmd, writers ...io.Writer) *Cmd {
+	c.teeWriters = append(c.teeWriters, writers...)
+	return c
+}
+
+// RedirectStageInput makes the stage with index stageIndex in the pipeline
+// read its standard input from r, the index of the first command is 0.
+//
+// Redirecting the input of a stage other than the first one breaks the pipe
+// from its pre stage, the output of the pre stage is discarded unless it is
+// redirected by RedirectStageOutput. It panics if stageIndex is out of range.
+func (c *Cmd) RedirectStageInput(stageIndex int, r io.Reader) *Cmd {
+	c.stage(stageIndex).stdinRedirect = r
+	return c
+}
+
+// RedirectStageOutput makes the stage with index stageIndex in the pipeline
+// write its standard output to w, the index of the first command is 0.
+//
+// Redirecting the output of a stage other than the last one breaks the pipe
+// to its next stage, the next stage reads from empty input unless it is
+// redirected by RedirectStageInput. It panics if stageIndex is out of range.
+func (c *Cmd) RedirectStageOutput(stageIndex int, w io.Writer) *Cmd {
+	c.stage(stageIndex).stdoutRedirect = w
+	return c
+}
+
+// stage returns the command with index i in the pipeline.
+func (c *Cmd) stage(i int) *Cmd {
+	stages := []*Cmd{}
+	for cmd := c; cmd != nil; cmd = cmd.preCmd {
+		stages = append([]*Cmd{cmd}, stages...)
+	}
+	if i < 0 || i >= len(stages) {
+		panic(fmt.Sprintf("exec: stage index %d out of range [0, %d)", i, len(stages)))
+	}
+	return stages[i]
+}
+
+// SetStdinString sets the standard input of the first command in the
+// pipeline to read from s.
+func (c *Cmd) SetStdinString(s string) *Cmd {
+	return c.setStdin(strings.NewReader(s))
+}
+
+// SetStdinBytes sets the standard input of the first command in the
+// pipeline to read from b.
+func (c *Cmd) SetStdinBytes(b []byte) *Cmd {
+	return c.setStdin(bytes.NewReader(b))
+}
+
+// SetStdinReadCloser sets the standard input of the first command in the
+// pipeline to read from rc, e.g. an HTTP response body. The rc is closed
+// exactly once when Wait returns or Start fails, so the caller does not need
+// to close it.
+func (c *Cmd) SetStdinReadCloser(rc io.ReadCloser) *Cmd {
+	c.setStdin(rc)
+	c.stage(0).stdinCloser = rc
+	return c
+}
+
+// closeStdin closes the stdin set by SetStdinReadCloser if it is not closed.
+func (c *Cmd) closeStdin() {
+	for cmd := c; cmd != nil; cmd = cmd.preCmd {
+		if cmd.stdinCloser != nil {
+			cmd.stdinCloser.Close()
+			cmd.stdinCloser = nil
+		}
+	}
+}
+
+// setStdin sets stdin on the first command of the pipeline, which is the
+// only one reading input from it, the others read from their pre command.
+func (c *Cmd) setStdin(in io.Reader) *Cmd {
+	first := c
+	for first.preCmd != nil {
+		first = first.preCmd
+	}
+	if first.ioHolder == nil {
+		first.ioHolder = &ioHolder{}
+	}
+	first.ioHolder.stdin = in
+	return c
+}
+
 func (c *Cmd) getIO() (in io.Reader, out, err io.Writer) {
 	if c.ioHolder == nil {
 		return nil, nil, nil
@@ -144,13 +288,30 @@ func (c *Cmd) getIO() (in io.Reader, out, err io.Writer) {
 	return c.ioHolder.GetIO()
 }
 
+// resolve returns the name and args of the runtime command, the args are
+// rendered with argTemplate and then mutated by cmdMutator.
+func (c *Cmd) resolve() (string, []string, error) {
+	var err error
+	name := c.argsHolder.name
+	args := c.argsHolder.args
+	if c.argTemplate != nil {
+		var rendered []string
+		rendered, err = renderArgs(args, c.argTemplate)
+		if err == nil {
+			args = rendered
+		}
+	}
+	if c.cmdMutator != nil {
+		name, args = c.cmdMutator(name, args)
+	}
+	return name, args, err
+}
+
 func (c *Cmd) ensureCmd() {
 	if c.runtimeCmd == nil {
-		name := c.argsHolder.name
-		args := c.argsHolder.args
-		if c.cmdMutator != nil {
-			name, args = c.cmdMutator(name, args)
-		}
+		var name string
+		var args []string
+		name, args, c.argsErr = c.resolve()
 		if c.ctx != nil {
 			c.runtimeCmd = exec.CommandContext(c.ctx, name, args...)
 		} else {
@@ -163,6 +324,31 @@ func (c *Cmd) ensureCmd() {
 	}
 }
 
+// String returns the shell equivalent of the command, the commands in
+// pipeline are joined with "|", e.g. echo 123 | sort -r
+func (c *Cmd) String() string {
+	stages := []string{}
+	for cmd := c; cmd != nil; cmd = cmd.preCmd {
+		name, args, _ := cmd.resolve()
+		words := make([]string, 0, len(args)+1)
+		words = append(words, shellQuote(name))
+		for _, arg := range args {
+			words = append(words, shellQuote(arg))
+		}
+		stages = append([]string{strings.Join(words, " ")}, stages...)
+	}
+	return strings.Join(stages, " | ")
+}
+
+// shellQuote quotes s with single quotes if it is empty or contains spaces
+// or shell special characters.
+func shellQuote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\n'\"\\$`|&;<>()*?!#~{}[]") {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
 // Command returns a runnable *exec.Cmd
 func (c *Cmd) Command() *exec.Cmd {
 	c.ensureCmd()
@@ -209,6 +395,32 @@ func (c *Cmd) setDefultProbe(startup *Probe) *Probe {
 	return startup
 }
 
+// exitedError is returned by RunForever if the command exits with an error,
+// it is ErrExitedInRunForever and wraps the error returned by Wait.
+type exitedError struct {
+	err error
+}
+
+func (e *exitedError) Error() string {
+	return fmt.Sprintf("%v: %v", ErrExitedInRunForever, e.err)
+}
+
+func (e *exitedError) Is(target error) bool {
+	return target == ErrExitedInRunForever
+}
+
+func (e *exitedError) Unwrap() error {
+	return e.err
+}
+
+// RunForever starts the specified command and waits until the startup probe
+// succeeds, the command is expected to keep running after that.
+//
+// If the probe fails, a *ProbeError is returned. If the command exits before
+// the probe succeeds, the returned error satisfies
+// errors.Is(err, ErrExitedInRunForever), and if it exits with an error, e.g.
+// a non-zero exit code, the error of Wait is wrapped and can be inspected by
+// errors.As(err, &exitErr) with exitErr of type *exec.ExitError.
 func (c *Cmd) RunForever(startup *Probe) error {
 	err := c.Start()
 	if err != nil {
@@ -230,7 +442,7 @@ func (c *Cmd) RunForever(startup *Probe) error {
 	case err := <-errC:
 		close(done) // stop worder
 		if err != nil {
-			return err
+			return &exitedError{err: err}
 		}
 		return ErrExitedInRunForever
 	case err := <-worker.run():
@@ -238,6 +450,158 @@ func (c *Cmd) RunForever(startup *Probe) error {
 	}
 }
 
+// RunTimeout starts the specified command and waits for it to complete
+// within the given duration.
+//
+// If the deadline elapses before Wait returns, all processes in the pipeline
+// are killed with SIGKILL and ErrTimeout is returned.
+func (c *Cmd) RunTimeout(d time.Duration) error {
+	parent := c.ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(parent, d)
+	defer cancel()
+
+	err := c.Start()
+	if err != nil {
+		return err
+	}
+
+	errC := make(chan error, 1)
+	go func() {
+		errC <- c.Wait()
+	}()
+
+	select {
+	case err := <-errC:
+		return err
+	case <-ctx.Done():
+		c.kill()
+		// wait for the killed processes to be released
+		<-errC
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return ErrTimeout
+		}
+		return ctx.Err()
+	}
+}
+
+// kill sends SIGKILL to every started process in the pipeline
+func (c *Cmd) kill() {
+	for cmd := c; cmd != nil; cmd = cmd.preCmd {
+		if cmd.runtimeCmd != nil && cmd.runtimeCmd.Process != nil {
+			cmd.runtimeCmd.Process.Kill() //nolint
+		}
+	}
+}
+
+// RunWithLineHandler starts the specified command and waits for it to
+// complete, onStdout and onStderr are called with each line as soon as it
+// arrives. Either of them can be nil.
+//
+// For pipelines, only the last command's stdout is scanned, but stderr of
+// every command is merged. The output is not buffered, so ReadStdout and
+// ReadStderr return nothing after it.
+func (c *Cmd) RunWithLineHandler(onStdout, onStderr func(line string)) error {
+	c.ensureCmd()
+	_, stdout, stderr := c.getIO()
+
+	outWriter := newLineWriter(onStdout)
+	errWriter := newLineWriter(onStderr)
+	c.runtimeCmd.Stdout = outWriter
+	c.runtimeCmd.Stderr = errWriter
+	if stdout != nil {
+		c.runtimeCmd.Stdout = io.MultiWriter(stdout, outWriter)
+	}
+	if stderr != nil {
+		c.runtimeCmd.Stderr = io.MultiWriter(stderr, errWriter)
+	}
+
+	err := c.Run()
+	outWriter.Flush()
+	errWriter.Flush()
+	return err
+}
+
+// ScanOutput starts the specified command and calls fn with each line of its
+// stdout as soon as it arrives. If fn returns an error, every process in the
+// pipeline is killed and released, then the error is returned. Otherwise, it
+// waits for the command to complete like Run.
+//
+// The stdout is not buffered, so ReadStdout returns nothing after it, but
+// ReadStderr still works.
+func (c *Cmd) ScanOutput(fn func(line string) error) error {
+	c.ensureCmd()
+	_, stdout, _ := c.getIO()
+
+	pr, pw := io.Pipe()
+	c.runtimeCmd.Stdout = pw
+	if stdout != nil {
+		c.runtimeCmd.Stdout = io.MultiWriter(stdout, pw)
+	}
+
+	err := c.Start()
+	if err != nil {
+		return err
+	}
+
+	errC := make(chan error, 1)
+	go func() {
+		err := c.Wait()
+		pw.Close()
+		errC <- err
+	}()
+
+	reader := bufio.NewReader(pr)
+	for {
+		line, readErr := reader.ReadString('\n')
+		if len(line) > 0 {
+			line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+			if err := fn(line); err != nil {
+				c.kill()
+				// drain the pipe so that the killed processes can be released
+				io.Copy(ioutil.Discard, pr) //nolint
+				<-errC
+				return err
+			}
+		}
+		if readErr != nil {
+			break
+		}
+	}
+	return <-errC
+}
+
+// TailOutput runs the command and returns only the last n lines of its
+// combined standard output and standard error, the lines are joined by '\n'.
+// Older lines are discarded as new lines arrive, so the memory is bounded by n.
+//
+// For pipelines, stderr of every command is merged like RunWithLineHandler.
+// The tail is returned even if the command fails.
+func (c *Cmd) TailOutput(n int) ([]byte, error) {
+	if n < 0 {
+		n = 0
+	}
+	ring := newLineRing(n)
+	err := c.RunWithLineHandler(ring.Add, ring.Add)
+	return []byte(strings.Join(ring.Lines(), "\n")), err
+}
+
+// RunLogged starts the specified command and waits for it to complete, each
+// line of stdout is logged at info level and each line of stderr is logged
+// at error level as soon as it arrives.
+func (c *Cmd) RunLogged(logger logr.Logger) error {
+	return c.RunWithLineHandler(
+		func(line string) {
+			logger.Info(line)
+		},
+		func(line string) {
+			logger.Error(nil, line)
+		},
+	)
+}
+
 // Start starts the specified command but does not wait for it to complete.
 //
 // The Wait method will return the exit code and release associated resources
@@ -249,11 +613,27 @@ func (c *Cmd) Start() error {
 	defer func() {
 		c.started = true
 	}()
+	if err := c.start(); err != nil {
+		// Wait is never called on a failed command, so close the stdin here
+		c.closeStdin()
+		return err
+	}
+	return nil
+}
+
+func (c *Cmd) start() error {
+	// fail fast before any command in the pipeline is started
+	for cmd := c; cmd != nil; cmd = cmd.preCmd {
+		cmd.ensureCmd()
+		if cmd.argsErr != nil {
+			return cmd.argsErr
+		}
+	}
 	err := c.beforeStart()
 	if err != nil {
 		return err
 	}
-	err = c.runtimeCmd.Start()
+	err = startInNamespaces(c.runtimeCmd, c.namespaces)
 	if err != nil {
 		return err
 	}
@@ -271,12 +651,20 @@ func (c *Cmd) beforeStart() error {
 	stdin, stdout, stderr := c.getIO()
 
 	// setup stdin for first command, so that we can read input from it
-	if stdin != nil && c.preCmd == nil {
+	if c.stdinRedirect != nil {
+		c.runtimeCmd.Stdin = c.stdinRedirect
+	} else if stdin != nil && c.preCmd == nil {
 		c.runtimeCmd.Stdin = stdin
 	}
 	// setup stdout and stderr for last command
 	// the pre command's stdout and stderr will be set by pipe
 	if c.runtimeCmd.Stdout == nil {
+		if c.stdoutRedirect != nil {
+			stdout = c.stdoutRedirect
+		}
+		if len(c.teeWriters) > 0 {
+			stdout = newTeeWriter(append([]io.Writer{stdout}, c.teeWriters...)...)
+		}
 		c.runtimeCmd.Stdout = newWriterWithBuffer(stdout)
 	}
 	if c.runtimeCmd.Stderr == nil {
@@ -285,11 +673,20 @@ func (c *Cmd) beforeStart() error {
 
 	if c.preCmd != nil {
 		preCmd := c.preCmd.Command()
-		var err error
-		// pre's output connect to cmd's input
-		c.runtimeCmd.Stdin, err = preCmd.StdoutPipe()
-		if err != nil {
-			return err
+		switch {
+		case c.preCmd.stdoutRedirect != nil:
+			// the pipe is broken by redirection
+			preCmd.Stdout = c.preCmd.stdoutRedirect
+		case c.stdinRedirect != nil:
+			// the pipe is broken by redirection, discard pre's output
+			preCmd.Stdout = ioutil.Discard
+		default:
+			var err error
+			// pre's output connect to cmd's input
+			c.runtimeCmd.Stdin, err = preCmd.StdoutPipe()
+			if err != nil {
+				return err
+			}
 		}
 		// pre's error connect to cmd's error
 		preCmd.Stderr = c.runtimeCmd.Stderr
@@ -308,7 +705,8 @@ func (c *Cmd) beforeStart() error {
 //
 // If the command fails to run or doesn't complete successfully, the
 // error is of type *ExitError. Other error types may be
-// returned for I/O problems.
+// returned for I/O problems. For pipelines, the error of the rightmost
+// failed command is returned.
 //
 // If any of c.Stdin, c.Stdout or c.Stderr are not an *os.File, Wait also waits
 // for the respective I/O loop copying to or from the process to complete.
@@ -324,15 +722,20 @@ func (c *Cmd) Wait() error {
 
 	defer func() {
 		c.finished = true
+		c.closeStdin()
 	}()
 
+	// wait for every command in the pipeline, the error of the rightmost
+	// failed command is returned just like shell's pipefail, so that the
+	// exit code of the last command is not masked by its pre commands.
+	var preErr error
 	if c.preCmd != nil {
-		if err := c.preCmd.Wait(); err != nil {
-			return err
-		}
+		preErr = c.preCmd.Wait()
 	}
-	err := c.runtimeCmd.Wait()
-	return err
+	if err := c.runtimeCmd.Wait(); err != nil {
+		return err
+	}
+	return preErr
 }
 
 // CombinedOutput runs the command and returns its combined standard
@@ -372,6 +775,23 @@ func (c *Cmd) Output() ([]byte, error) {
 	return c.ReadStdout()
 }
 
+// OutputStrings runs the command and returns its standard output and
+// standard error as trimmed strings. For pipelines, stderr is the aggregated
+// error stream of all commands.
+//
+// Both strings are still populated if the command fails with *ExitError.
+func (c *Cmd) OutputStrings() (stdout, stderr string, err error) {
+	err = c.Run()
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); !ok {
+			return "", "", err
+		}
+	}
+	outBytes, _ := c.ReadStdout()
+	errBytes, _ := c.ReadStderr()
+	return string(outBytes), string(errBytes), err
+}
+
 // ReadStdout reads all bytes from command's standard output
 // The command must have been finished by Wait.
 func (c *Cmd) ReadStdout() ([]byte, error) {
@@ -406,6 +826,9 @@ func (c *Cmd) ReadStderr() ([]byte, error) {
 // this command latter. The closure runs the command and reads all
 // bytes from standard output
 //
+// If the command exits non-zero, the returned error is always of type
+// *exec.ExitError, so ExitCode() can be used to get its exit code.
+//
 // echo := Command("echo").OutputClosure()
 // echo("123")
 // echo("321")
@@ -425,6 +848,9 @@ func (c *Cmd) OutputClosure() func(...string) ([]byte, error) {
 // CombinedOutputClosure returns function closure allowing you to call
 // this command latter. The closure runs the command and reads all
 // bytes from combined standard output and standard error
+//
+// If the command exits non-zero, the closure returns the standard error
+// and an error of type *exec.ExitError.
 func (c *Cmd) CombinedOutputClosure() func(...string) ([]byte, error) {
 	return func(args ...string) ([]byte, error) {
 		newCmd := c.copy()
@@ -20,9 +20,15 @@ import (
 	"errors"
 	"io"
 	"io/ioutil"
+	"os"
 	"os/exec"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
 )
 
 func TestCommand(t *testing.T) {
@@ -94,6 +100,33 @@ func TestCmd_Command(t *testing.T) {
 	}
 }
 
+func TestCmd_String(t *testing.T) {
+	mutated := Command("echo", "123")
+	mutated.SetCmdMutator(func(name string, args []string) (string, []string) {
+		return "sudo", append([]string{name}, args...)
+	})
+
+	tests := []struct {
+		name string
+		cmd  *Cmd
+		want string
+	}{
+		{"", Command("echo", "123"), "echo 123"},
+		{"", Command("echo", "123").Pipe("sort", "-r").Pipe("uniq", "-c"), "echo 123 | sort -r | uniq -c"},
+		{"quote", Command("echo", "hello world", "", "it's").Pipe("grep", "$x"), `echo 'hello world' '' 'it'\''s' | grep '$x'`},
+		{"mutator", mutated.Pipe("sort"), "sudo echo 123 | sudo sort"},
+		{"template", Command("echo", "{{msg}}").Pipe("grep", "{{msg}}").SetArgTemplate(map[string]string{"msg": "a b"}), "echo 'a b' | grep 'a b'"},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cmd.String(); got != tt.want {
+				t.Errorf("Cmd.String() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestCmd_Pipe(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -400,6 +433,189 @@ func TestCmd_SetStdin(t *testing.T) {
 	}
 }
 
+func TestCmd_Reset(t *testing.T) {
+	tests := []struct {
+		name string
+		cmd  *Cmd
+		want []byte
+	}{
+		{"", Command("echo", "123"), []byte("123")},
+		{"", Command("echo", "2\n1").Pipe("sort"), []byte("1\n2")},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 2; i++ {
+				got, err := tt.cmd.Reset().Output()
+				if err != nil {
+					t.Errorf("Cmd.Reset() run %d error = %v", i, err)
+					return
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("Cmd.Reset() run %d = %v, want %v", i, string(got), string(tt.want))
+				}
+			}
+		})
+	}
+}
+
+func TestCmd_SetArgTemplate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cmd     *Cmd
+		tmpl    map[string]string
+		want    []byte
+		wantErr bool
+	}{
+		{"", Command("echo", "{{msg}}"), map[string]string{"msg": "123"}, []byte("123"), false},
+		{"", Command("echo", "a-{{ msg }}-b"), map[string]string{"msg": "123"}, []byte("a-123-b"), false},
+		{"pipeline", Command("echo", "{{a}}\n{{b}}").Pipe("sort", "{{order}}"), map[string]string{"a": "1", "b": "2", "order": "-r"}, []byte("2\n1"), false},
+		{"escape", Command("echo", "\\{{msg}}"), map[string]string{"msg": "123"}, []byte("{{msg}}"), false},
+		{"missing", Command("echo", "{{msg}}"), map[string]string{}, nil, true},
+		{"missingInPreCmd", Command("echo", "{{msg}}").Pipe("sort"), map[string]string{}, nil, true},
+		{"unclosed", Command("echo", "{{msg"), map[string]string{"msg": "123"}, nil, true},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.cmd.SetArgTemplate(tt.tmpl).Output()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Cmd.SetArgTemplate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Cmd.SetArgTemplate() = %v, want %v", string(got), string(tt.want))
+			}
+		})
+	}
+}
+
+func TestCmd_ClosureExitCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		cmd      *Cmd
+		args     []string
+		wantCode int
+	}{
+		{"", Command("bash", "-c"), []string{"exit 3"}, 3},
+		{"pipeline", Command("echo", "123").Pipe("bash", "-c"), []string{"exit 4"}, 4},
+		{"preCmdFailed", Command("bash", "-c", "exit 2").Pipe("bash", "-c"), []string{"exit 5"}, 5},
+		{"onlyPreCmdFailed", Command("bash", "-c", "exit 2").Pipe("cat"), nil, 2},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			closures := map[string]func(...string) ([]byte, error){
+				"OutputClosure":         tt.cmd.OutputClosure(),
+				"CombinedOutputClosure": tt.cmd.CombinedOutputClosure(),
+			}
+			for name, closure := range closures {
+				_, err := closure(tt.args...)
+				eerr, ok := err.(*exec.ExitError)
+				if !ok {
+					t.Errorf("Cmd.%v() error = %v, want *exec.ExitError", name, err)
+					continue
+				}
+				if eerr.ExitCode() != tt.wantCode {
+					t.Errorf("Cmd.%v() exit code = %v, want %v", name, eerr.ExitCode(), tt.wantCode)
+				}
+			}
+		})
+	}
+}
+
+func TestCmd_SetStdinString(t *testing.T) {
+	tests := []struct {
+		name    string
+		cmd     *Cmd
+		want    []byte
+		wantErr bool
+	}{
+		{"", Command("sort").SetStdinString("2\n1"), []byte("1\n2"), false},
+		{"", Command("sort").Pipe("uniq").SetStdinString("2\n1\n2"), []byte("1\n2"), false},
+		{"", Command("sort").SetStdinBytes([]byte("2\n1")), []byte("1\n2"), false},
+		{"", Command("sort").Pipe("sort", "-r").SetStdinBytes([]byte("1\n2")), []byte("2\n1"), false},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.cmd.Output()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Cmd.SetStdinString() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(string(got), string(tt.want)) {
+				t.Errorf("Cmd.SetStdinString() = %v, want %v", string(got), string(tt.want))
+			}
+		})
+	}
+}
+
+// countingReadCloser counts how many times it is closed
+type countingReadCloser struct {
+	io.Reader
+	closed int
+}
+
+func (rc *countingReadCloser) Close() error {
+	rc.closed++
+	return nil
+}
+
+func TestCmd_SetStdinReadCloser(t *testing.T) {
+	tests := []struct {
+		name    string
+		cmd     func(rc io.ReadCloser) *Cmd
+		want    []byte
+		wantErr bool
+	}{
+		{"", func(rc io.ReadCloser) *Cmd { return Command("sort").SetStdinReadCloser(rc) }, []byte("1\n2"), false},
+		{"pipeline", func(rc io.ReadCloser) *Cmd { return Command("sort").Pipe("sort", "-r").SetStdinReadCloser(rc) }, []byte("2\n1"), false},
+		{"failed", func(rc io.ReadCloser) *Cmd { return Command("bash", "-c", "cat; exit 1").SetStdinReadCloser(rc) }, nil, true},
+		{"notStarted", func(rc io.ReadCloser) *Cmd { return Command("/not/exist").SetStdinReadCloser(rc) }, nil, true},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			rc := &countingReadCloser{Reader: bytes.NewBufferString("2\n1")}
+			got, err := tt.cmd(rc).Output()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Cmd.SetStdinReadCloser() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(string(got), string(tt.want)) {
+				t.Errorf("Cmd.SetStdinReadCloser() = %v, want %v", string(got), string(tt.want))
+			}
+			if rc.closed != 1 {
+				t.Errorf("Cmd.SetStdinReadCloser() closed %v times, want 1", rc.closed)
+			}
+		})
+	}
+}
+
+func TestCmd_SetStdinReadCloser_startFailure(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(c *Cmd) error
+	}{
+		{"Run", func(c *Cmd) error { return c.Run() }},
+		{"RunTimeout", func(c *Cmd) error { return c.RunTimeout(time.Second) }},
+		{"RunForever", func(c *Cmd) error { return c.RunForever(nil) }},
+		{"ScanOutput", func(c *Cmd) error { return c.ScanOutput(func(string) error { return nil }) }},
+		{"Start", func(c *Cmd) error { return c.Start() }},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			rc := &countingReadCloser{Reader: bytes.NewBufferString("1")}
+			err := tt.run(Command("/not/exist").SetStdinReadCloser(rc))
+			if err == nil {
+				t.Errorf("%v() expects an error", tt.name)
+			}
+			if rc.closed != 1 {
+				t.Errorf("%v() closed stdin %v times, want 1", tt.name, rc.closed)
+			}
+		})
+	}
+}
+
 func TestCmd_SetStdout(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -502,6 +718,144 @@ func TestCmd_SetStderr(t *testing.T) {
 	}
 }
 
+func TestCmd_RunWithLineHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		cmd        *Cmd
+		wantStdout []string
+		wantStderr []string
+		wantErr    bool
+	}{
+		{"", Command("seq", "1", "5"), []string{"1", "2", "3", "4", "5"}, nil, false},
+		{"pipeline", Command("seq", "1", "5").Pipe("sort", "-r"), []string{"5", "4", "3", "2", "1"}, nil, false},
+		{"noTrailingNewline", Command("printf", "1\\n2"), []string{"1", "2"}, nil, false},
+		{
+			"mergedStderr",
+			Command("bash", "-c", "echo pre >&2; seq 1 2").Pipe("bash", "-c", "cat; echo post >&2; exit 1"),
+			[]string{"1", "2"},
+			[]string{"pre", "post"},
+			true,
+		},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			var gotStdout, gotStderr []string
+			err := tt.cmd.RunWithLineHandler(
+				func(line string) { gotStdout = append(gotStdout, line) },
+				func(line string) { gotStderr = append(gotStderr, line) },
+			)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Cmd.RunWithLineHandler() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(gotStdout, tt.wantStdout) {
+				t.Errorf("Cmd.RunWithLineHandler() stdout = %v, want %v", gotStdout, tt.wantStdout)
+			}
+			if !reflect.DeepEqual(gotStderr, tt.wantStderr) {
+				t.Errorf("Cmd.RunWithLineHandler() stderr = %v, want %v", gotStderr, tt.wantStderr)
+			}
+		})
+	}
+}
+
+func TestCmd_TailOutput(t *testing.T) {
+	tests := []struct {
+		name    string
+		cmd     *Cmd
+		n       int
+		want    string
+		wantErr bool
+	}{
+		{"", Command("seq", "1", "1000"), 10, "991\n992\n993\n994\n995\n996\n997\n998\n999\n1000", false},
+		{"fewerLines", Command("seq", "1", "3"), 10, "1\n2\n3", false},
+		{"pipeline", Command("seq", "1", "1000").Pipe("sort", "-n", "-r"), 3, "3\n2\n1", false},
+		{"stderr", Command("bash", "-c", "seq 1 5; echo failed >&2; exit 1"), 2, "5\nfailed", true},
+		{"zero", Command("seq", "1", "3"), 0, "", false},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.cmd.TailOutput(tt.n)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Cmd.TailOutput() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Cmd.TailOutput() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// captureLogger records messages logged through it by level
+type captureLogger struct {
+	mu     *sync.Mutex
+	infos  *[]string
+	errors *[]string
+}
+
+func newCaptureLogger() captureLogger {
+	return captureLogger{
+		mu:     &sync.Mutex{},
+		infos:  &[]string{},
+		errors: &[]string{},
+	}
+}
+
+func (l captureLogger) Enabled() bool { return true }
+
+func (l captureLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	*l.infos = append(*l.infos, msg)
+}
+
+func (l captureLogger) Error(err error, msg string, keysAndValues ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	*l.errors = append(*l.errors, msg)
+}
+
+func (l captureLogger) V(level int) logr.Logger { return l }
+
+func (l captureLogger) WithValues(keysAndValues ...interface{}) logr.Logger { return l }
+
+func (l captureLogger) WithName(name string) logr.Logger { return l }
+
+func TestCmd_RunLogged(t *testing.T) {
+	tests := []struct {
+		name       string
+		cmd        *Cmd
+		wantInfos  []string
+		wantErrors []string
+		wantErr    bool
+	}{
+		{"", Command("seq", "1", "3"), []string{"1", "2", "3"}, []string{}, false},
+		{
+			"partialLine",
+			Command("bash", "-c", "echo out; echo err >&2; printf partial; printf failed >&2; exit 1"),
+			[]string{"out", "partial"},
+			[]string{"err", "failed"},
+			true,
+		},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			logger := newCaptureLogger()
+			err := tt.cmd.RunLogged(logger)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Cmd.RunLogged() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(*logger.infos, tt.wantInfos) {
+				t.Errorf("Cmd.RunLogged() infos = %v, want %v", *logger.infos, tt.wantInfos)
+			}
+			if !reflect.DeepEqual(*logger.errors, tt.wantErrors) {
+				t.Errorf("Cmd.RunLogged() errors = %v, want %v", *logger.errors, tt.wantErrors)
+			}
+		})
+	}
+}
+
 func TestCmd_RunForever(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -515,7 +869,7 @@ func TestCmd_RunForever(t *testing.T) {
 			Command("sort", "-x"),
 			nil,
 			true,
-			"exit status 2",
+			ErrExitedInRunForever.Error() + ": exit status 2",
 		},
 		{
 			"exitInRunForever",
@@ -558,6 +912,28 @@ func TestCmd_RunForever(t *testing.T) {
 			true,
 			"probe failed: failed run forever",
 		},
+		{
+			"stdoutReady",
+			Command("bash", "-c", "sleep 1; echo 'server listening on :8080'; sleep 5"),
+			&Probe{
+				Handler:          StdoutContainsHandler("listening on :8080"),
+				SuccessThreshold: 1,
+				FailureThreshold: 5,
+			},
+			false,
+			"",
+		},
+		{
+			"stdoutNeverReady",
+			Command("bash", "-c", "echo starting; sleep 5"),
+			&Probe{
+				Handler:          StdoutContainsHandler("listening on :8080"),
+				SuccessThreshold: 1,
+				FailureThreshold: 2,
+			},
+			true,
+			`probe failed: stdout does not contain "listening on :8080"`,
+		},
 	}
 	for i := range tests {
 		tt := tests[i]
@@ -569,3 +945,237 @@ func TestCmd_RunForever(t *testing.T) {
 		})
 	}
 }
+
+func TestCmd_RunForeverExitCode(t *testing.T) {
+	err := Command("bash", "-c", "exit 3").RunForever(nil)
+	if !errors.Is(err, ErrExitedInRunForever) {
+		t.Fatalf("Cmd.RunForever() error = %v, want %v", err, ErrExitedInRunForever)
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		t.Fatalf("Cmd.RunForever() error = %v, want wrapping *exec.ExitError", err)
+	}
+	if exitErr.ExitCode() != 3 {
+		t.Errorf("Cmd.RunForever() exit code = %v, want 3", exitErr.ExitCode())
+	}
+
+	// probe failure is distinguishable from exiting
+	err = Command("sleep", "5").RunForever(&Probe{
+		Handler: func(*exec.Cmd) error {
+			return errors.New("not ready")
+		},
+		FailureThreshold: 1,
+	})
+	var probeErr *ProbeError
+	if !errors.As(err, &probeErr) || errors.Is(err, ErrExitedInRunForever) {
+		t.Errorf("Cmd.RunForever() error = %v, want *ProbeError", err)
+	}
+}
+
+func TestCmd_RunTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		cmd     *Cmd
+		timeout time.Duration
+		wantErr error
+	}{
+		{"completesInTime", Command("sleep", "0.1"), 2 * time.Second, nil},
+		{"pipeCompletesInTime", Command("echo", "2\n1").Pipe("sort"), 2 * time.Second, nil},
+		{"timeout", Command("sleep", "5"), 200 * time.Millisecond, ErrTimeout},
+		{"pipeTimeout", Command("echo", "1").Pipe("sleep", "5"), 200 * time.Millisecond, ErrTimeout},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			err := tt.cmd.RunTimeout(tt.timeout)
+			if err != tt.wantErr {
+				t.Errorf("Cmd.RunTimeout() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("Cmd.RunTimeout() took %v, process was not killed in time", elapsed)
+			}
+		})
+	}
+}
+
+func TestCmd_OutputStrings(t *testing.T) {
+	tests := []struct {
+		name       string
+		cmd        *Cmd
+		wantStdout string
+		wantStderr string
+		wantErr    bool
+	}{
+		{"", Command("echo", "2\n1").Pipe("sort"), "1\n2", "", false},
+		{"bothStreams", Command("bash", "-c", "echo out; echo err >&2"), "out", "err", false},
+		{"exitError", Command("bash", "-c", "echo out; echo err >&2; exit 1"), "out", "err", true},
+		{"lookuperr", Command("echox", "123"), "", "", true},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			stdout, stderr, err := tt.cmd.OutputStrings()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Cmd.OutputStrings() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if stdout != tt.wantStdout {
+				t.Errorf("Cmd.OutputStrings() stdout = %v, want %v", stdout, tt.wantStdout)
+			}
+			if stderr != tt.wantStderr {
+				t.Errorf("Cmd.OutputStrings() stderr = %v, want %v", stderr, tt.wantStderr)
+			}
+		})
+	}
+}
+
+type errWriter struct{}
+
+func (errWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("write error")
+}
+
+func TestTee(t *testing.T) {
+	buf1 := &bytes.Buffer{}
+	buf2 := &bytes.Buffer{}
+	c := Tee(Command("echo", "-n", "hello\nworld").Pipe("sort", "-r"), buf1, errWriter{}, buf2)
+	got, err := c.Output()
+	if err != nil {
+		t.Fatalf("Cmd.Output() error = %v", err)
+	}
+	if string(got) != "world\nhello" {
+		t.Errorf("Cmd.Output() = %q, want %q", got, "world\nhello")
+	}
+	want := "world\nhello\n"
+	if buf1.String() != want {
+		t.Errorf("Tee() writer 1 got = %q, want %q", buf1.String(), want)
+	}
+	if buf2.String() != want {
+		t.Errorf("Tee() writer 2 got = %q, want %q", buf2.String(), want)
+	}
+}
+
+func TestCmd_RedirectStageInput(t *testing.T) {
+	f, err := ioutil.TempFile(t.TempDir(), "input")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("b\nc\na\n") //nolint
+	f.Close()
+
+	open := func() io.Reader {
+		r, err := os.Open(f.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { r.Close() })
+		return r
+	}
+
+	tests := []struct {
+		name string
+		cmd  func() *Cmd
+		want string
+	}{
+		{
+			name: "first stage",
+			cmd: func() *Cmd {
+				return Command("cat").Pipe("sort").RedirectStageInput(0, open())
+			},
+			want: "a\nb\nc",
+		},
+		{
+			name: "first stage overrides SetIO",
+			cmd: func() *Cmd {
+				c := Command("cat").Pipe("sort", "-r")
+				c.SetIO(strings.NewReader("ignored"), nil, nil)
+				return c.RedirectStageInput(0, open())
+			},
+			want: "c\nb\na",
+		},
+		{
+			name: "middle stage breaks the pipe",
+			cmd: func() *Cmd {
+				return Command("echo", "ignored").Pipe("cat").Pipe("sort").RedirectStageInput(1, open())
+			},
+			want: "a\nb\nc",
+		},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.cmd().Output()
+			if err != nil {
+				t.Fatalf("Cmd.Output() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Cmd.Output() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCmd_RedirectStageOutput(t *testing.T) {
+	buf := &bytes.Buffer{}
+	c := Command("echo", "hello").Pipe("cat").RedirectStageOutput(0, buf)
+	got, err := c.Output()
+	if err != nil {
+		t.Fatalf("Cmd.Output() error = %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("Cmd.Output() = %q, want empty output", got)
+	}
+	if buf.String() != "hello\n" {
+		t.Errorf("redirected output = %q, want %q", buf.String(), "hello\n")
+	}
+}
+
+func TestCmd_ScanOutput(t *testing.T) {
+	var lines []string
+	err := Command("printf", "a\\nb\\r\\n\\nc").Pipe("cat").ScanOutput(func(line string) error {
+		lines = append(lines, line)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Cmd.ScanOutput() error = %v", err)
+	}
+	want := []string{"a", "b", "", "c"}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("Cmd.ScanOutput() lines = %q, want %q", lines, want)
+	}
+}
+
+func TestCmd_ScanOutputAbort(t *testing.T) {
+	errAbort := errors.New("abort")
+	c := Command("sh", "-c", "echo oops >&2; while true; do echo line; sleep 0.01; done")
+	count := 0
+	done := make(chan error, 1)
+	go func() {
+		done <- c.ScanOutput(func(line string) error {
+			count++
+			if count == 3 {
+				return errAbort
+			}
+			return nil
+		})
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, errAbort) {
+			t.Errorf("Cmd.ScanOutput() error = %v, want %v", err, errAbort)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Cmd.ScanOutput() should return after aborting")
+	}
+	if count != 3 {
+		t.Errorf("Cmd.ScanOutput() called fn %v times, want 3", count)
+	}
+	if state := c.Command().ProcessState; state == nil {
+		t.Errorf("the process should be released after aborting")
+	}
+	stderr, err := c.ReadStderr()
+	if err != nil || string(stderr) != "oops" {
+		t.Errorf("Cmd.ReadStderr() = %q, %v, want %q", stderr, err, "oops")
+	}
+}
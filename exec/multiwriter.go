@@ -17,11 +17,13 @@ package exec
 import (
 	"bytes"
 	"io"
+	"sync"
 )
 
 // writerWithBuffer warps a writer with buffer
 // so you can read bytes from the buffer
 type writerWithBuffer struct {
+	mu     sync.Mutex
 	buffer *bytes.Buffer
 	w      io.Writer
 }
@@ -39,9 +41,142 @@ func newWriterWithBuffer(w io.Writer) io.ReadWriter {
 }
 
 func (mwr *writerWithBuffer) Write(p []byte) (n int, err error) {
+	mwr.mu.Lock()
+	defer mwr.mu.Unlock()
 	return mwr.w.Write(p)
 }
 
 func (mwr *writerWithBuffer) Read(p []byte) (n int, err error) {
+	mwr.mu.Lock()
+	defer mwr.mu.Unlock()
 	return mwr.buffer.Read(p)
 }
+
+// Bytes returns a copy of the unread portion of the buffer without
+// consuming it, it is safe to call while the command is still writing.
+func (mwr *writerWithBuffer) Bytes() []byte {
+	mwr.mu.Lock()
+	defer mwr.mu.Unlock()
+	b := mwr.buffer.Bytes()
+	ret := make([]byte, len(b))
+	copy(ret, b)
+	return ret
+}
+
+// teeWriter duplicates writes to all writers. Unlike io.MultiWriter, an
+// error of one writer does not stop writing to others, the failed writer is
+// skipped afterwards.
+type teeWriter struct {
+	writers []io.Writer
+	failed  []bool
+}
+
+func newTeeWriter(writers ...io.Writer) io.Writer {
+	tw := &teeWriter{}
+	for _, w := range writers {
+		if w != nil {
+			tw.writers = append(tw.writers, w)
+		}
+	}
+	tw.failed = make([]bool, len(tw.writers))
+	return tw
+}
+
+func (tw *teeWriter) Write(p []byte) (n int, err error) {
+	for i, w := range tw.writers {
+		if tw.failed[i] {
+			continue
+		}
+		n, err := w.Write(p)
+		if err == nil && n != len(p) {
+			err = io.ErrShortWrite
+		}
+		if err != nil {
+			tw.failed[i] = true
+		}
+	}
+	return len(p), nil
+}
+
+// lineWriter splits bytes written to it into lines and calls handler with
+// each of them, the line ending is trimmed.
+type lineWriter struct {
+	mu      sync.Mutex
+	buf     []byte
+	handler func(line string)
+}
+
+func newLineWriter(handler func(line string)) *lineWriter {
+	return &lineWriter{
+		handler: handler,
+	}
+}
+
+func (lw *lineWriter) Write(p []byte) (n int, err error) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	lw.buf = append(lw.buf, p...)
+	for {
+		i := bytes.IndexByte(lw.buf, '\n')
+		if i < 0 {
+			break
+		}
+		lw.emit(lw.buf[:i])
+		lw.buf = lw.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush calls handler with the remaining incomplete line if any
+func (lw *lineWriter) Flush() {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	if len(lw.buf) > 0 {
+		lw.emit(lw.buf)
+		lw.buf = nil
+	}
+}
+
+func (lw *lineWriter) emit(line []byte) {
+	if lw.handler != nil {
+		lw.handler(string(bytes.TrimSuffix(line, []byte("\r"))))
+	}
+}
+
+// lineRing retains the last n lines added to it, it is safe to be called
+// concurrently.
+type lineRing struct {
+	mu    sync.Mutex
+	lines []string
+	next  int
+	full  bool
+}
+
+func newLineRing(n int) *lineRing {
+	return &lineRing{
+		lines: make([]string, n),
+	}
+}
+
+func (r *lineRing) Add(line string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.lines) == 0 {
+		return
+	}
+	r.lines[r.next] = line
+	r.next = (r.next + 1) % len(r.lines)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// Lines returns the retained lines from the oldest to the newest
+func (r *lineRing) Lines() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.full {
+		return append([]string{}, r.lines[:r.next]...)
+	}
+	return append(append([]string{}, r.lines[r.next:]...), r.lines[:r.next]...)
+}
@@ -0,0 +1,66 @@
+// Copyright 2023 jim.zoumo@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNamespaceUnsupported is returned by Start if namespaces are set on a
+// platform other than linux.
+var ErrNamespaceUnsupported = errors.New("exec: namespaces are only supported on linux")
+
+// NamespaceType is the type of linux namespace
+type NamespaceType string
+
+const (
+	NamespaceNet   NamespaceType = "net"
+	NamespacePID   NamespaceType = "pid"
+	NamespaceMount NamespaceType = "mnt"
+)
+
+// NamespaceConfig describes the linux namespaces a command runs in.
+// It only works on linux, Start returns ErrNamespaceUnsupported elsewhere.
+type NamespaceConfig struct {
+	// Paths maps the namespace type to the namespace file to enter,
+	// e.g. /var/run/netns/foo or /proc/1234/ns/net.
+	// An empty path means that the command runs in a new namespace.
+	//
+	// A Go process is multi-threaded, so it can not enter an existing mount
+	// namespace, only a new one can be created.
+	Paths map[NamespaceType]string
+}
+
+// TargetNamespaces returns a NamespaceConfig to enter the given namespaces
+// of the process with pid.
+func TargetNamespaces(pid int, types ...NamespaceType) NamespaceConfig {
+	ns := NamespaceConfig{
+		Paths: make(map[NamespaceType]string, len(types)),
+	}
+	for _, typ := range types {
+		ns.Paths[typ] = fmt.Sprintf("/proc/%d/ns/%s", pid, typ)
+	}
+	return ns
+}
+
+// SetNamespaces sets the linux namespaces for every command in the
+// pipeline, they take effect when the command starts.
+func (c *Cmd) SetNamespaces(ns NamespaceConfig) *Cmd {
+	for cmd := c; cmd != nil; cmd = cmd.preCmd {
+		cmd.namespaces = &ns
+	}
+	return c
+}
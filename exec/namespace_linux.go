@@ -0,0 +1,130 @@
+// Copyright 2023 jim.zoumo@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+var namespaceFlags = map[NamespaceType]int{
+	NamespaceNet:   unix.CLONE_NEWNET,
+	NamespacePID:   unix.CLONE_NEWPID,
+	NamespaceMount: unix.CLONE_NEWNS,
+}
+
+type namespaceToEnter struct {
+	typ  NamespaceType
+	path string
+	flag int
+}
+
+// startInNamespaces starts cmd in the given namespaces.
+//
+// New namespaces are created by SysProcAttr.Cloneflags. For existing
+// namespaces, the calling thread is locked and enters them by setns(2)
+// before starting the command, the new process inherits the thread's
+// namespaces. Then the thread restores its original namespaces.
+func startInNamespaces(cmd *exec.Cmd, ns *NamespaceConfig) error {
+	if ns == nil || len(ns.Paths) == 0 {
+		return cmd.Start()
+	}
+
+	var cloneflags int
+	toEnter := []namespaceToEnter{}
+	for typ, path := range ns.Paths {
+		flag, ok := namespaceFlags[typ]
+		if !ok {
+			return fmt.Errorf("exec: unknown namespace type %q", typ)
+		}
+		if path == "" {
+			cloneflags |= flag
+			continue
+		}
+		if typ == NamespaceMount {
+			return fmt.Errorf("exec: can not enter mount namespace %v, a multi-threaded process can only create a new one", path)
+		}
+		toEnter = append(toEnter, namespaceToEnter{typ: typ, path: path, flag: flag})
+	}
+
+	if cloneflags != 0 {
+		if cmd.SysProcAttr == nil {
+			cmd.SysProcAttr = &syscall.SysProcAttr{}
+		}
+		cmd.SysProcAttr.Cloneflags |= uintptr(cloneflags)
+	}
+	if len(toEnter) == 0 {
+		return cmd.Start()
+	}
+
+	runtime.LockOSThread()
+	restore, err := enterNamespaces(toEnter)
+	if err != nil {
+		if restore() == nil {
+			runtime.UnlockOSThread()
+		}
+		return err
+	}
+	startErr := cmd.Start()
+	if err := restore(); err != nil {
+		// keep the thread locked, it is dirty and will be terminated
+		// when the goroutine exits
+		return fmt.Errorf("exec: failed to restore namespaces: %w", err)
+	}
+	runtime.UnlockOSThread()
+	return startErr
+}
+
+// enterNamespaces makes the current thread enter the namespaces, the
+// returned restore function must be called to switch back to the original
+// namespaces even if an error is returned.
+func enterNamespaces(toEnter []namespaceToEnter) (restore func() error, err error) {
+	originals := []*os.File{}
+	restore = func() error {
+		var restoreErr error
+		for i := len(originals) - 1; i >= 0; i-- {
+			if restoreErr == nil {
+				restoreErr = unix.Setns(int(originals[i].Fd()), toEnter[i].flag)
+			}
+			originals[i].Close() //nolint
+		}
+		return restoreErr
+	}
+
+	for _, ns := range toEnter {
+		original, err := os.Open(fmt.Sprintf("/proc/self/task/%d/ns/%s", unix.Gettid(), ns.typ))
+		if err != nil {
+			return restore, err
+		}
+		target, err := os.Open(ns.path)
+		if err != nil {
+			original.Close() //nolint
+			return restore, err
+		}
+		err = unix.Setns(int(target.Fd()), ns.flag)
+		target.Close() //nolint
+		if err != nil {
+			original.Close() //nolint
+			return restore, fmt.Errorf("exec: failed to enter %v namespace %v: %w", ns.typ, ns.path, err)
+		}
+		originals = append(originals, original)
+	}
+	return restore, nil
+}
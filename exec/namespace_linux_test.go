@@ -0,0 +1,70 @@
+// Copyright 2023 jim.zoumo@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestCmd_SetNamespaces(t *testing.T) {
+	// start a process in a new net namespace as the target
+	holder := Command("sleep", "10").SetNamespaces(NamespaceConfig{
+		Paths: map[NamespaceType]string{NamespaceNet: ""},
+	})
+	if err := holder.Start(); err != nil {
+		t.Skipf("skip without privileges to create net namespace: %v", err)
+	}
+	defer holder.Command().Process.Kill() //nolint
+
+	pid := holder.Command().Process.Pid
+	want, err := os.Readlink(fmt.Sprintf("/proc/%d/ns/net", pid))
+	if err != nil {
+		t.Fatalf("failed to read target net namespace: %v", err)
+	}
+	self, err := os.Readlink("/proc/self/ns/net")
+	if err != nil {
+		t.Fatalf("failed to read current net namespace: %v", err)
+	}
+	if want == self {
+		t.Fatalf("target should be in a new net namespace")
+	}
+
+	got, err := Command("readlink", "/proc/self/ns/net").
+		SetNamespaces(TargetNamespaces(pid, NamespaceNet)).
+		Output()
+	if err != nil {
+		t.Fatalf("Cmd.SetNamespaces() error = %v", err)
+	}
+	if string(got) != want {
+		t.Errorf("Cmd.SetNamespaces() net namespace = %v, want %v", string(got), want)
+	}
+
+	// the namespaces of the current process must be restored
+	got, err = Command("readlink", "/proc/self/ns/net").Output()
+	if err != nil {
+		t.Fatalf("Cmd.Output() error = %v", err)
+	}
+	if string(got) != self {
+		t.Errorf("net namespace is not restored, got %v, want %v", string(got), self)
+	}
+
+	// entering an existing mount namespace is not supported
+	err = Command("true").SetNamespaces(TargetNamespaces(pid, NamespaceMount)).Run()
+	if err == nil {
+		t.Errorf("Cmd.SetNamespaces() should fail to enter an existing mount namespace")
+	}
+}
@@ -125,9 +125,11 @@ func (w *worker) doProbe() (keepGoing bool) {
 	}
 
 	err := w.probe.Handler(w.runningCmd)
+	// only keep the error of the latest probe, a handler such as
+	// StdoutContainsHandler fails before it eventually succeeds
+	w.lastErr = err
 	result := success
 	if err != nil {
-		w.lastErr = err
 		result = failure
 	}
 
@@ -15,6 +15,7 @@
 package exec
 
 import (
+	"bytes"
 	"fmt"
 	"os/exec"
 
@@ -45,3 +46,27 @@ func IsCmdRunningHandler(cmd *exec.Cmd) error {
 	}
 	return nil
 }
+
+// bufferedOutput is implemented by the stdout and stderr writers set up by
+// Cmd, it allows reading the live buffer without consuming it.
+type bufferedOutput interface {
+	Bytes() []byte
+}
+
+// StdoutContainsHandler returns a probe handler which succeeds once the
+// buffered standard output of the running command contains substr.
+func StdoutContainsHandler(substr string) func(cmd *exec.Cmd) error {
+	return func(cmd *exec.Cmd) error {
+		if cmd == nil {
+			return fmt.Errorf("command is not running")
+		}
+		buffered, ok := cmd.Stdout.(bufferedOutput)
+		if !ok {
+			return fmt.Errorf("command stdout is not buffered")
+		}
+		if !bytes.Contains(buffered.Bytes(), []byte(substr)) {
+			return fmt.Errorf("stdout does not contain %q", substr)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2023 jim.zoumo@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"errors"
+	"os/exec"
+	"time"
+)
+
+// Result bundles the outputs and status of a finished command
+type Result struct {
+	// Stdout is the standard output of the last command in pipeline
+	Stdout []byte
+	// Stderr is the merged standard error of all commands in pipeline
+	Stderr []byte
+	// ExitCode is the exit code of the rightmost failed command in
+	// pipeline, or -1 if it was terminated by a signal.
+	ExitCode int
+	// Duration is the time taken to run the command
+	Duration time.Duration
+	// Command is the command line rendered by String()
+	Command string
+}
+
+// Result runs the command and returns its result. If the command exits
+// non-zero, both the result and the *exec.ExitError are returned. For other
+// errors, e.g. the command can not be started, the result is nil.
+func (c *Cmd) Result() (*Result, error) {
+	start := time.Now()
+	err := c.Run()
+	duration := time.Since(start)
+
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return nil, err
+	}
+
+	stdout, _ := c.ReadStdout()
+	stderr, _ := c.ReadStderr()
+	result := &Result{
+		Stdout:   stdout,
+		Stderr:   stderr,
+		Duration: duration,
+		Command:  c.String(),
+	}
+	if exitErr != nil {
+		result.ExitCode = exitErr.ExitCode()
+		exitErr.Stderr = stderr
+	}
+	return result, err
+}
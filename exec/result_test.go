@@ -0,0 +1,99 @@
+// Copyright 2023 jim.zoumo@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"errors"
+	"os/exec"
+	"testing"
+)
+
+func TestCmd_Result(t *testing.T) {
+	tests := []struct {
+		name         string
+		cmd          *Cmd
+		wantStdout   string
+		wantStderr   string
+		wantExitCode int
+		wantCommand  string
+		wantErr      bool
+	}{
+		{
+			name:        "success",
+			cmd:         Command("sh", "-c", "echo out; echo err >&2"),
+			wantStdout:  "out",
+			wantStderr:  "err",
+			wantCommand: `sh -c 'echo out; echo err >&2'`,
+		},
+		{
+			name:         "exit error",
+			cmd:          Command("sh", "-c", "echo out; echo err >&2; exit 3"),
+			wantStdout:   "out",
+			wantStderr:   "err",
+			wantExitCode: 3,
+			wantCommand:  `sh -c 'echo out; echo err >&2; exit 3'`,
+			wantErr:      true,
+		},
+		{
+			name:         "pipeline",
+			cmd:          Command("echo", "hello").Pipe("sh", "-c", "cat; exit 2"),
+			wantStdout:   "hello",
+			wantExitCode: 2,
+			wantCommand:  `echo hello | sh -c 'cat; exit 2'`,
+			wantErr:      true,
+		},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.cmd.Result()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Cmd.Result() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var exitErr *exec.ExitError
+			if err != nil && !errors.As(err, &exitErr) {
+				t.Errorf("Cmd.Result() error = %v, want *exec.ExitError", err)
+			}
+			if got == nil {
+				t.Fatalf("Cmd.Result() should return result")
+			}
+			if string(got.Stdout) != tt.wantStdout {
+				t.Errorf("Result.Stdout = %q, want %q", got.Stdout, tt.wantStdout)
+			}
+			if string(got.Stderr) != tt.wantStderr {
+				t.Errorf("Result.Stderr = %q, want %q", got.Stderr, tt.wantStderr)
+			}
+			if got.ExitCode != tt.wantExitCode {
+				t.Errorf("Result.ExitCode = %v, want %v", got.ExitCode, tt.wantExitCode)
+			}
+			if got.Command != tt.wantCommand {
+				t.Errorf("Result.Command = %v, want %v", got.Command, tt.wantCommand)
+			}
+			if got.Duration <= 0 {
+				t.Errorf("Result.Duration = %v, want > 0", got.Duration)
+			}
+		})
+	}
+}
+
+func TestCmd_ResultStartError(t *testing.T) {
+	got, err := Command("command-not-exists").Result()
+	if err == nil {
+		t.Errorf("Cmd.Result() want error")
+	}
+	if got != nil {
+		t.Errorf("Cmd.Result() = %v, want nil on start error", got)
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2023 jim.zoumo@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	placeholderLeft  = "{{"
+	placeholderRight = "}}"
+)
+
+// renderArgs substitutes the {{name}} placeholders in args with values in
+// tmpl. \{{ is rendered as a literal {{.
+func renderArgs(args []string, tmpl map[string]string) ([]string, error) {
+	rendered := make([]string, 0, len(args))
+	for _, arg := range args {
+		r, err := renderArg(arg, tmpl)
+		if err != nil {
+			return nil, err
+		}
+		rendered = append(rendered, r)
+	}
+	return rendered, nil
+}
+
+func renderArg(arg string, tmpl map[string]string) (string, error) {
+	var b strings.Builder
+	rest := arg
+	for {
+		i := strings.Index(rest, placeholderLeft)
+		if i < 0 {
+			b.WriteString(rest)
+			return b.String(), nil
+		}
+		if i > 0 && rest[i-1] == '\\' {
+			// escaped
+			b.WriteString(rest[:i-1])
+			b.WriteString(placeholderLeft)
+			rest = rest[i+len(placeholderLeft):]
+			continue
+		}
+		b.WriteString(rest[:i])
+		rest = rest[i+len(placeholderLeft):]
+
+		j := strings.Index(rest, placeholderRight)
+		if j < 0 {
+			return "", fmt.Errorf("exec: unclosed placeholder in arg %q", arg)
+		}
+		key := strings.TrimSpace(rest[:j])
+		value, ok := tmpl[key]
+		if !ok {
+			return "", fmt.Errorf("exec: missing value for placeholder %q in arg %q", key, arg)
+		}
+		b.WriteString(value)
+		rest = rest[j+len(placeholderRight):]
+	}
+}
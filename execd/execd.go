@@ -34,8 +34,29 @@ const (
 var (
 	// ErrNotRunning ...
 	ErrNotRunning = errors.New("execd: process is not running")
+
+	// DefaultRestartPolicy restarts the daemon unlimited times, checking it
+	// every second
+	DefaultRestartPolicy = RestartPolicy{
+		MaxRestarts:   -1,
+		InitialDelay:  1 * time.Second,
+		BackoffFactor: 1,
+	}
 )
 
+// RestartPolicy controls how the daemon is restarted after it exits
+type RestartPolicy struct {
+	// MaxRestarts is the max number of restarts, -1 means unlimited.
+	MaxRestarts int
+	// InitialDelay is the interval of checking whether the daemon is
+	// running and the delay before the first restart.
+	// Defaults to 1 second if it is not greater than 0.
+	InitialDelay time.Duration
+	// BackoffFactor multiplies the delay after every restart, the delay
+	// is constant if it is not greater than 1.
+	BackoffFactor float64
+}
+
 // D represents an external daemon command being prepared or run.
 //
 // A daemon is a long-time running background process providing reliable services for others.
@@ -107,6 +128,7 @@ type D struct {
 
 	gracePeriod      time.Duration
 	gracefulShutDown func(*exec.Cmd) error
+	restartPolicy    *RestartPolicy
 
 	lookPathErr error
 	stopCh      chan struct{}
@@ -196,12 +218,26 @@ func (c *D) SetGracefulShutDown(f func(*exec.Cmd) error) {
 	c.gracefulShutDown = f
 }
 
+// SetRestartPolicy sets the policy of restarting the daemon after it exits,
+// defaults to DefaultRestartPolicy.
+// It must be called before RunForever.
+func (c *D) SetRestartPolicy(p RestartPolicy) {
+	if p.InitialDelay <= 0 {
+		p.InitialDelay = DefaultRestartPolicy.InitialDelay
+	}
+	if p.BackoffFactor < 1 {
+		p.BackoffFactor = 1
+	}
+	c.restartPolicy = &p
+}
+
 // RunForever starts the specified command and waits for it to complete in another goroutine.
 // If there is no error, the daemon will run forever.
 //
-// In the meantime, It starts a goroutine to keep the background process alive.
-// But if the error occurs more than `crashBackOff` times when command is starting,
-// it will stop tracking anymore.
+// In the meantime, It starts a goroutine to keep the background process alive
+// following the restart policy. But if the error occurs more than `crashBackOff`
+// times when command is starting, or the daemon has been restarted MaxRestarts
+// times, it will stop tracking anymore.
 func (c *D) RunForever() error {
 	if c.lookPathErr != nil {
 		return c.lookPathErr
@@ -297,14 +333,25 @@ func (c *D) run() error {
 }
 
 func (c *D) keepalive() {
+	policy := DefaultRestartPolicy
+	if c.restartPolicy != nil {
+		policy = *c.restartPolicy
+	}
 	go func() {
-		tick := time.NewTicker(1 * time.Second)
-		defer tick.Stop()
+		delay := policy.InitialDelay
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
 		restartErrTimes := 0
+		restarts := 0
 		for {
 			select {
-			case <-tick.C:
+			case <-timer.C:
 				if !c.IsRunning() {
+					if policy.MaxRestarts >= 0 && restarts >= policy.MaxRestarts {
+						fmt.Printf("execd(%v): restarted %v times, stop tracking the daemon\n", c.Name(), restarts)
+						return
+					}
+					restarts++
 					c.cmd = c.delegate()
 					err := c.run()
 					if err != nil {
@@ -316,7 +363,9 @@ func (c *D) keepalive() {
 						fmt.Printf("execd(%v): error restart command: %v\n", c.Name(), err)
 						restartErrTimes++
 					}
+					delay = time.Duration(float64(delay) * policy.BackoffFactor)
 				}
+				timer.Reset(delay)
 			case <-c.stopCh:
 				return
 			}
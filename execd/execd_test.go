@@ -17,6 +17,9 @@ package execd
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -34,6 +37,16 @@ func init() {
 		}
 	})
 
+	reexec.Register("execd-test-fail", func() {
+		// record the start time and exit with an error
+		f, err := os.OpenFile(os.Getenv("EXECD_TEST_FILE"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err == nil {
+			fmt.Fprintln(f, time.Now().UnixNano())
+			f.Close()
+		}
+		os.Exit(1)
+	})
+
 	reexec.Register("execd-test-stop", func() {
 		var i int
 		for {
@@ -111,3 +124,64 @@ func TestCrasLoopBackoff(t *testing.T) {
 	}
 	cmd.Stop()
 }
+
+func TestSetRestartPolicy(t *testing.T) {
+	if reexec.Init() {
+		os.Exit(0)
+	}
+
+	file := filepath.Join(t.TempDir(), "starts")
+	c := reexec.Command("execd-test-fail")
+	c.Env = append(os.Environ(), "EXECD_TEST_FILE="+file)
+	cmd := DaemonFrom(c)
+	cmd.SetRestartPolicy(RestartPolicy{
+		MaxRestarts:   3,
+		InitialDelay:  50 * time.Millisecond,
+		BackoffFactor: 2,
+	})
+	if err := cmd.RunForever(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Stop() //nolint:errcheck
+
+	// restarts after 50ms, 100ms and 200ms, then stops tracking
+	<-time.After(1500 * time.Millisecond)
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Fields(string(data))
+	if len(lines) != 4 {
+		t.Fatalf("daemon started %v times, want 4", len(lines))
+	}
+	starts := make([]time.Time, 0, len(lines))
+	for _, line := range lines {
+		n, err := strconv.ParseInt(line, 10, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		starts = append(starts, time.Unix(0, n))
+	}
+	// the start times are recorded by the daemon itself, allow some latency
+	// of starting the process
+	want := 50 * time.Millisecond
+	var last time.Duration
+	for i := 1; i < len(starts); i++ {
+		delay := starts[i].Sub(starts[i-1])
+		if delay < want*4/5 || delay <= last {
+			t.Errorf("restart %v delay = %v, want growing and about %v", i, delay, want)
+		}
+		last = delay
+		want *= 2
+	}
+}
+
+func TestSetRestartPolicy_defaults(t *testing.T) {
+	cmd := &D{}
+	cmd.SetRestartPolicy(RestartPolicy{MaxRestarts: 1})
+	want := RestartPolicy{MaxRestarts: 1, InitialDelay: time.Second, BackoffFactor: 1}
+	if *cmd.restartPolicy != want {
+		t.Errorf("SetRestartPolicy() = %+v, want %+v", *cmd.restartPolicy, want)
+	}
+}
@@ -17,6 +17,7 @@ package heap
 import (
 	"container/heap"
 	"fmt"
+	"sync"
 )
 
 type KeyError struct {
@@ -73,7 +74,7 @@ func (h *containerHeap) Len() int {
 // index i should sort before the element with index j.
 // Implement standard heap.Interface.
 func (h *containerHeap) Less(i, j int) bool {
-	if i > len(h.ordered) || j > len(h.ordered) {
+	if i >= len(h.ordered) || j >= len(h.ordered) {
 		return false
 	}
 	x, ok := h.items[h.ordered[i]]
@@ -130,6 +131,10 @@ func (h *containerHeap) Peek() interface{} {
 }
 
 // PeekSecond returns the second item of heap without removing it.
+//
+// By the heap invariant, the second item is one of the children of the head,
+// i.e. index 1 or 2. The right child only exists if there are more than 2
+// items.
 func (h *containerHeap) PeekSecond() interface{} {
 	if len(h.ordered) < 2 {
 		return nil
@@ -153,6 +158,23 @@ func (h *containerHeap) GetByKey(key string) (interface{}, bool) {
 	return item.obj, true
 }
 
+// clone returns a copy of the heap data, the stored objects are shared with
+// the original one.
+func (h *containerHeap) clone() *containerHeap {
+	items := make(map[string]*containerHeapItem, len(h.items))
+	for key, item := range h.items {
+		copied := *item
+		items[key] = &copied
+	}
+	ordered := make([]string, len(h.ordered))
+	copy(ordered, h.ordered)
+	return &containerHeap{
+		items:    items,
+		ordered:  ordered,
+		lessFunc: h.lessFunc,
+	}
+}
+
 // Heap is a producer/consumer queue that implements a heap data structure.
 // It can be used to implement priority queues and similar data structures.
 type Heap struct {
@@ -162,10 +184,31 @@ type Heap struct {
 	// keyFunc is used to make the key used for queued item insertion and retrieval, and
 	// should be deterministic.
 	keyFunc KeyFunc
+	// capacity is the max number of objects in the heap, 0 means unlimited
+	capacity int
+	// onPop is called with each object removed by Pop and PopN
+	onPop func(obj interface{})
+
+	// synchronized guards all methods with mu if it is true
+	synchronized bool
+	mu           sync.RWMutex
 }
 
-func New(keyfunc KeyFunc, lessfunc LessFunc) *Heap {
-	return &Heap{
+// Options configures optional behaviors of a heap
+type Options func(*Heap)
+
+// WithOnPop sets a callback which is called exactly once with each object
+// removed by Pop or PopN, in the order they are popped. It is not called for
+// Peek, Remove or evictions. The callback is called after the heap is
+// unlocked, so it is safe to call methods of the heap in it.
+func WithOnPop(f func(obj interface{})) Options {
+	return func(h *Heap) {
+		h.onPop = f
+	}
+}
+
+func New(keyfunc KeyFunc, lessfunc LessFunc, opts ...Options) *Heap {
+	h := &Heap{
 		data: &containerHeap{
 			items:    make(map[string]*containerHeapItem),
 			ordered:  make([]string, 0),
@@ -173,43 +216,127 @@ func New(keyfunc KeyFunc, lessfunc LessFunc) *Heap {
 		},
 		keyFunc: keyfunc,
 	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// NewSynchronized returns a heap which is safe for concurrent use.
+// The functions passed to Range and WalkSorted must not call methods of
+// the heap, otherwise they may deadlock.
+func NewSynchronized(keyfunc KeyFunc, lessfunc LessFunc, opts ...Options) *Heap {
+	h := New(keyfunc, lessfunc, opts...)
+	h.synchronized = true
+	return h
+}
+
+// NewBounded returns a heap which keeps at most capacity objects. When it is
+// full, inserting an object evicts the head if the object should be placed
+// after the head, otherwise the object itself is dropped, e.g. use a min-heap
+// to keep the largest objects.
+// If capacity <= 0, the heap is unlimited.
+func NewBounded(keyfunc KeyFunc, lessfunc LessFunc, capacity int, opts ...Options) *Heap {
+	h := New(keyfunc, lessfunc, opts...)
+	if capacity > 0 {
+		h.capacity = capacity
+	}
+	return h
+}
+
+func (h *Heap) lock() {
+	if h.synchronized {
+		h.mu.Lock()
+	}
+}
+
+func (h *Heap) unlock() {
+	if h.synchronized {
+		h.mu.Unlock()
+	}
+}
+
+func (h *Heap) rlock() {
+	if h.synchronized {
+		h.mu.RLock()
+	}
+}
+
+func (h *Heap) runlock() {
+	if h.synchronized {
+		h.mu.RUnlock()
+	}
 }
 
 func (h *Heap) Len() int {
+	h.rlock()
+	defer h.runlock()
 	return h.data.Len()
 }
 
 // Add inserts an item, and puts it in the queue. The item is updated if it
 // already exists.
 func (h *Heap) AddOrUpdate(obj interface{}) error {
+	_, err := h.AddOrEvict(obj)
+	return err
+}
+
+// AddOrEvict inserts or updates an item like AddOrUpdate, it returns the
+// object evicted from a bounded heap, which may be obj itself, or nil if no
+// object is evicted.
+func (h *Heap) AddOrEvict(obj interface{}) (interface{}, error) {
 	key, err := h.keyFunc(obj)
 	if err != nil {
-		return KeyError{Obj: obj, Err: err}
+		return nil, KeyError{Obj: obj, Err: err}
 	}
-	if _, exists := h.data.items[key]; exists {
-		h.data.items[key].obj = obj
-		heap.Fix(h.data, h.data.items[key].index)
+	h.lock()
+	defer h.unlock()
+	_, evicted := h.addOrUpdate(key, obj, h.capacity)
+	return evicted, nil
+}
+
+// addOrUpdate inserts or updates obj. If capacity > 0, it keeps at most
+// capacity objects in the heap by evicting the head, the object already in
+// the heap is kept on ties. It returns whether obj is still in the heap after
+// evicting and the last evicted object.
+func (h *Heap) addOrUpdate(key string, obj interface{}, capacity int) (bool, interface{}) {
+	if item, exists := h.data.items[key]; exists {
+		item.obj = obj
+		heap.Fix(h.data, item.index)
 	} else {
+		if capacity > 0 && h.data.Len() >= capacity && !h.data.lessFunc(h.data.Peek(), obj) {
+			return false, obj
+		}
 		heap.Push(h.data, &containerHeapItem{key: key, obj: obj})
 	}
-	return nil
+	var evicted interface{}
+	for capacity > 0 && h.data.Len() > capacity {
+		evicted = heap.Pop(h.data)
+	}
+	// obj itself may be evicted if the heap held more than capacity objects
+	_, accepted := h.data.items[key]
+	return accepted, evicted
 }
 
 // AddIfNotPresent inserts an item, and puts it in the queue. If an item with
 // the key is present in the heap, no changes is made to the item.
 func (h *Heap) AddIfNotPresent(obj interface{}) error {
+	h.lock()
+	defer h.unlock()
 	key, err := h.keyFunc(obj)
 	if err != nil {
 		return KeyError{Obj: obj, Err: err}
 	}
 	if _, exists := h.data.items[key]; !exists {
-		heap.Push(h.data, &containerHeapItem{key: key, obj: obj})
+		h.addOrUpdate(key, obj, h.capacity)
 	}
 	return nil
 }
 
 // UpdateIfPresent update an item's obj and fix the order if it is present in the heap.
 func (h *Heap) UpdateIfPresent(obj interface{}) error {
+	h.lock()
+	defer h.unlock()
 	key, err := h.keyFunc(obj)
 	if err != nil {
 		return KeyError{Obj: obj, Err: err}
@@ -221,8 +348,35 @@ func (h *Heap) UpdateIfPresent(obj interface{}) error {
 	return nil
 }
 
+// OfferTopK keeps the heap as the top k objects, the head of the heap is the
+// worst one of them, e.g. use a min-heap to keep the k largest objects.
+//
+// If the heap has less than k objects, obj is inserted. Otherwise, obj is
+// only inserted if it is better than the head, i.e. the head should be
+// placed before obj, and the head is evicted. On ties, the object already in
+// the heap is kept. If an object with the same key is present, it is updated.
+//
+// It returns whether obj is accepted, i.e. it is in the heap after the
+// objects exceeding k are evicted. obj is rejected if k is not greater than 0.
+func (h *Heap) OfferTopK(obj interface{}, k int) (bool, error) {
+	if k <= 0 {
+		return false, nil
+	}
+	key, err := h.keyFunc(obj)
+	if err != nil {
+		return false, KeyError{Obj: obj, Err: err}
+	}
+
+	h.lock()
+	defer h.unlock()
+	accepted, _ := h.addOrUpdate(key, obj, k)
+	return accepted, nil
+}
+
 // Delete removes an item.
 func (h *Heap) Remove(obj interface{}) error {
+	h.lock()
+	defer h.unlock()
 	key, err := h.keyFunc(obj)
 	if err != nil {
 		return KeyError{Obj: obj, Err: err}
@@ -236,24 +390,74 @@ func (h *Heap) Remove(obj interface{}) error {
 
 // Pop returns the head of the heap and removes it.
 func (h *Heap) Pop() interface{} {
+	h.lock()
 	if len(h.data.ordered) == 0 {
+		h.unlock()
 		return nil
 	}
-	return heap.Pop(h.data)
+	obj := heap.Pop(h.data)
+	h.unlock()
+
+	if h.onPop != nil {
+		h.onPop(obj)
+	}
+	return obj
+}
+
+// PopN pops up to n items from the heap and returns them in the order they
+// are popped. It returns an empty slice if the heap is empty.
+func (h *Heap) PopN(n int) []interface{} {
+	h.lock()
+	if n > h.data.Len() {
+		n = h.data.Len()
+	}
+	if n < 0 {
+		n = 0
+	}
+	ret := make([]interface{}, 0, n)
+	for i := 0; i < n; i++ {
+		ret = append(ret, heap.Pop(h.data))
+	}
+	h.unlock()
+
+	if h.onPop != nil {
+		for _, obj := range ret {
+			h.onPop(obj)
+		}
+	}
+	return ret
 }
 
 // Peek returns the head of the heap without removing it.
 func (h *Heap) Peek() interface{} {
+	h.rlock()
+	defer h.runlock()
 	return h.data.Peek()
 }
 
-// PeekSecond returns the second item of heap without removing it.
+// PeekSecond returns the second item of heap without removing it, which is
+// the item that Pop would return after the head is popped, e.g. the second
+// smallest item of a min-heap. It returns nil if there are less than 2 items.
 func (h *Heap) PeekSecond() interface{} {
+	h.rlock()
+	defer h.runlock()
 	return h.data.PeekSecond()
 }
 
+// Get returns the requested item, or sets exists=false.
+func (h *Heap) Get(obj interface{}) (interface{}, bool, error) {
+	key, err := h.keyFunc(obj)
+	if err != nil {
+		return nil, false, KeyError{Obj: obj, Err: err}
+	}
+	item, exists := h.GetByKey(key)
+	return item, exists, nil
+}
+
 // GetByKey returns the requested item, or sets exists=false.
 func (h *Heap) GetByKey(key string) (interface{}, bool) {
+	h.rlock()
+	defer h.runlock()
 	return h.data.GetByKey(key)
 }
 
@@ -262,13 +466,47 @@ func (h *Heap) GetByKey(key string) (interface{}, bool) {
 //
 // Range does not guarantee the order.
 func (h *Heap) Range(f func(i int, key string, obj interface{}) bool) {
+	h.rlock()
+	defer h.runlock()
 	for _, item := range h.data.items {
 		f(item.index, item.key, item.obj)
 	}
 }
 
+// WalkSorted calls f sequentially for each object in the order they would be
+// popped. If f returns false, WalkSorted stops the iteration.
+//
+// WalkSorted works on a copy of the heap, so the heap itself is not changed.
+func (h *Heap) WalkSorted(f func(obj interface{}) bool) {
+	h.rlock()
+	data := h.data.clone()
+	h.runlock()
+	for data.Len() > 0 {
+		if !f(heap.Pop(data)) {
+			return
+		}
+	}
+}
+
+// Clone returns an independent copy of the heap with the same keyFunc,
+// lessFunc, capacity and options. It is a shallow copy, the objects are shared with
+// the original heap, so they should not be modified in place.
+func (h *Heap) Clone() *Heap {
+	h.rlock()
+	defer h.runlock()
+	return &Heap{
+		data:         h.data.clone(),
+		keyFunc:      h.keyFunc,
+		capacity:     h.capacity,
+		onPop:        h.onPop,
+		synchronized: h.synchronized,
+	}
+}
+
 // List returns a list of all the items.
 func (h *Heap) List() []interface{} {
+	h.rlock()
+	defer h.runlock()
 	if len(h.data.items) == 0 {
 		return []interface{}{}
 	}
@@ -15,6 +15,11 @@
 package heap
 
 import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sync"
 	"testing"
 )
 
@@ -204,25 +209,33 @@ func TestHeap_Delete(t *testing.T) {
 	}
 }
 
-// // TestHeap_Get tests Heap.Get.
-// func TestHeap_Get(t *testing.T) {
-// 	h := New(testHeapObjectKeyFunc, compareInts)
-// 	h.Add(mkHeapObj("foo", 10))
-// 	h.Add(mkHeapObj("bar", 1))
-// 	h.Add(mkHeapObj("bal", 31))
-// 	h.Add(mkHeapObj("baz", 11))
-
-// 	// Get works with the key.
-// 	obj, exists, err := h.Get(mkHeapObj("baz", 0))
-// 	if exists == false || obj.(testHeapObject).val != 11 {
-// 		t.Fatalf("unexpected error in getting element")
-// 	}
-// 	// Get non-existing object.
-// 	_, exists, err = h.Get(mkHeapObj("non-existing", 0))
-// 	if exists == true {
-// 		t.Fatalf("didn't expect to get any object")
-// 	}
-// }
+// TestHeap_Get tests Heap.Get.
+func TestHeap_Get(t *testing.T) {
+	h := New(testHeapObjectKeyFunc, compareInts)
+	h.AddOrUpdate(mkHeapObj("foo", 10))
+	h.AddOrUpdate(mkHeapObj("bar", 1))
+	h.AddOrUpdate(mkHeapObj("bal", 31))
+	h.AddOrUpdate(mkHeapObj("baz", 11))
+
+	// Get works with the key.
+	obj, exists, err := h.Get(mkHeapObj("baz", 0))
+	if err != nil || exists == false || obj.(testHeapObject).val != 11 {
+		t.Fatalf("unexpected error in getting element")
+	}
+	// Get non-existing object.
+	_, exists, err = h.Get(mkHeapObj("non-existing", 0))
+	if err != nil || exists == true {
+		t.Fatalf("didn't expect to get any object")
+	}
+	// Get fails if the key func fails.
+	h = New(func(obj interface{}) (string, error) {
+		return "", errors.New("key error")
+	}, compareInts)
+	_, _, err = h.Get(mkHeapObj("baz", 0))
+	if _, ok := err.(KeyError); !ok {
+		t.Fatalf("expected a KeyError, got %v", err)
+	}
+}
 
 // TestHeap_GetByKey tests Heap.GetByKey and is very similar to TestHeap_Get.
 func TestHeap_GetByKey(t *testing.T) {
@@ -325,3 +338,402 @@ func TestHeap_PeekSecond(t *testing.T) {
 		t.Fatalf("expected %d, got %d", e, a)
 	}
 }
+
+func TestHeap_PeekSecondAfterRemove(t *testing.T) {
+	tests := []struct {
+		name  string
+		input []int
+		want  interface{}
+	}{
+		{"size 3 to 2", []int{0, 1, 2}, 2},
+		{"size 4 to 3, left is second", []int{0, 1, 3, 2}, 2},
+		{"size 4 to 3, right is second", []int{0, 3, 1, 2}, 2},
+		{"size 5 to 4", []int{0, 4, 1, 3, 2}, 2},
+		{"size 2 to 1", []int{0, 1}, nil},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			h := New(testHeapObjectKeyFunc, compareInts)
+			for _, v := range tt.input {
+				h.AddOrUpdate(mkHeapObj(fmt.Sprint(v), v))
+			}
+			h.Remove(mkHeapObj(fmt.Sprint(h.Peek().(testHeapObject).val), 0))
+
+			var got interface{}
+			if second := h.PeekSecond(); second != nil {
+				got = second.(testHeapObject).val
+			}
+			if got != tt.want {
+				t.Errorf("Heap.PeekSecond() = %v, want %v", got, tt.want)
+			}
+			// the second item is the one popped after the head
+			if h.Len() >= 2 {
+				h.Pop()
+				if head := h.Peek().(testHeapObject).val; head != tt.want {
+					t.Errorf("Heap.Peek() = %v after Pop, want %v", head, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestContainerHeap_LessOutOfRange(t *testing.T) {
+	h := New(testHeapObjectKeyFunc, compareInts)
+	h.AddOrUpdate(mkHeapObj("a", 0))
+	h.AddOrUpdate(mkHeapObj("b", 1))
+	if h.data.Less(0, 2) || h.data.Less(2, 0) {
+		t.Errorf("containerHeap.Less() should be false for out of range index")
+	}
+}
+
+func TestHeap_WalkSorted(t *testing.T) {
+	h := New(testHeapObjectKeyFunc, compareInts)
+	h.WalkSorted(func(obj interface{}) bool {
+		t.Errorf("unexpected item %v", obj)
+		return true
+	})
+
+	items := map[string]int{
+		"a": 4,
+		"b": 2,
+		"c": 1,
+		"d": 6,
+		"e": 3,
+		"f": 5,
+	}
+	for k, v := range items {
+		h.AddIfNotPresent(mkHeapObj(k, v))
+	}
+
+	got := []int{}
+	h.WalkSorted(func(obj interface{}) bool {
+		got = append(got, obj.(testHeapObject).val.(int))
+		return true
+	})
+	want := []int{1, 2, 3, 4, 5, 6}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	// stop early
+	got = []int{}
+	h.WalkSorted(func(obj interface{}) bool {
+		got = append(got, obj.(testHeapObject).val.(int))
+		return len(got) < 3
+	})
+	want = []int{1, 2, 3}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	// the heap must be intact
+	if h.Len() != len(items) {
+		t.Errorf("expected %d items, got %d", len(items), h.Len())
+	}
+	for i := 1; i <= len(items); i++ {
+		if e, a := i, h.Pop().(testHeapObject).val.(int); a != e {
+			t.Fatalf("expected %d, got %d", e, a)
+		}
+	}
+}
+
+func TestHeap_Synchronized(t *testing.T) {
+	h := NewSynchronized(testHeapObjectKeyFunc, compareInts)
+	const (
+		workers = 8
+		amount  = 200
+	)
+
+	wg := sync.WaitGroup{}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < amount; i++ {
+				obj := mkHeapObj(fmt.Sprintf("%d-%d", w, i), i)
+				h.AddOrUpdate(obj)
+				h.UpdateIfPresent(obj)
+				h.AddIfNotPresent(obj)
+				h.Peek()
+				h.PeekSecond()
+				h.GetByKey(obj.name)
+				h.List()
+				h.Len()
+				h.WalkSorted(func(interface{}) bool { return false })
+				h.Range(func(int, string, interface{}) bool { return true })
+				if i%2 == 0 {
+					h.Remove(obj)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if got, want := h.Len(), workers*amount/2; got != want {
+		t.Errorf("Heap.Len() = %v, want %v", got, want)
+	}
+	prev := -1
+	for h.Len() > 0 {
+		val := h.Pop().(testHeapObject).val.(int)
+		if val < prev {
+			t.Fatalf("got %v after %v, heap invariant is broken", val, prev)
+		}
+		prev = val
+	}
+}
+
+func TestHeap_OfferTopK(t *testing.T) {
+	// min-heap keeps the largest values, the head is the worst of them
+	h := New(testHeapObjectKeyFunc, compareInts)
+	const k = 10
+	for _, v := range rand.Perm(1000) {
+		accepted, err := h.OfferTopK(mkHeapObj(fmt.Sprint(v), v), k)
+		if err != nil {
+			t.Fatalf("Heap.OfferTopK() error = %v", err)
+		}
+		if h.Len() > k {
+			t.Fatalf("Heap.Len() = %v after OfferTopK(), want <= %v", h.Len(), k)
+		}
+		if accepted {
+			if _, ok := h.GetByKey(fmt.Sprint(v)); !ok {
+				t.Errorf("Heap.OfferTopK(%v) = true, but it is not in heap", v)
+			}
+		}
+	}
+
+	got := []int{}
+	for h.Len() > 0 {
+		got = append(got, h.Pop().(testHeapObject).val.(int))
+	}
+	want := []int{990, 991, 992, 993, 994, 995, 996, 997, 998, 999}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Heap.OfferTopK() kept %v, want %v", got, want)
+	}
+}
+
+func TestHeap_OfferTopKTie(t *testing.T) {
+	h := New(testHeapObjectKeyFunc, compareInts)
+	h.OfferTopK(mkHeapObj("a", 1), 2) //nolint
+	h.OfferTopK(mkHeapObj("b", 2), 2) //nolint
+
+	tests := []struct {
+		name string
+		obj  testHeapObject
+		want bool
+	}{
+		{"worse", mkHeapObj("c", 0), false},
+		{"tie with head", mkHeapObj("d", 1), false},
+		{"better", mkHeapObj("e", 3), true},
+		{"update present", mkHeapObj("b", 0), true},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := h.OfferTopK(tt.obj, 2)
+			if err != nil {
+				t.Fatalf("Heap.OfferTopK() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Heap.OfferTopK() = %v, want %v", got, tt.want)
+			}
+			if h.Len() != 2 {
+				t.Errorf("Heap.Len() = %v, want 2", h.Len())
+			}
+		})
+	}
+	if _, ok := h.GetByKey("a"); ok {
+		t.Errorf("the worst object should be evicted")
+	}
+}
+
+func TestHeap_OfferTopKRejected(t *testing.T) {
+	h := New(testHeapObjectKeyFunc, compareInts)
+	if ok, err := h.OfferTopK(mkHeapObj("a", 1), 0); ok || err != nil {
+		t.Errorf("Heap.OfferTopK() with k = 0 = %v, %v, want false, nil", ok, err)
+	}
+
+	// obj is rejected if it is evicted from a heap holding more than k objects
+	for i := 0; i < 5; i++ {
+		h.AddOrUpdate(mkHeapObj(fmt.Sprint(i), i)) //nolint
+	}
+	if ok, err := h.OfferTopK(mkHeapObj("better than head", 1), 2); ok || err != nil {
+		t.Errorf("Heap.OfferTopK() evicted by shrinking = %v, %v, want false, nil", ok, err)
+	}
+	if _, ok := h.GetByKey("better than head"); ok {
+		t.Errorf("the evicted object should not be in heap")
+	}
+	if h.Len() != 2 {
+		t.Errorf("Heap.Len() = %v, want 2", h.Len())
+	}
+
+	// OfferTopK fails if the key func fails.
+	h = New(func(obj interface{}) (string, error) {
+		return "", errors.New("key error")
+	}, compareInts)
+	ok, err := h.OfferTopK(mkHeapObj("a", 1), 1)
+	if _, isKeyErr := err.(KeyError); ok || !isKeyErr {
+		t.Errorf("Heap.OfferTopK() = %v, %v, want false and a KeyError", ok, err)
+	}
+}
+
+func TestNewBounded(t *testing.T) {
+	h := NewBounded(testHeapObjectKeyFunc, compareInts, 10)
+	for _, v := range rand.Perm(1000) {
+		h.AddOrUpdate(mkHeapObj(fmt.Sprint(v), v))
+		if h.Len() > 10 {
+			t.Fatalf("Heap.Len() = %v, want <= 10", h.Len())
+		}
+	}
+
+	got := []int{}
+	for h.Len() > 0 {
+		got = append(got, h.Pop().(testHeapObject).val.(int))
+	}
+	want := []int{990, 991, 992, 993, 994, 995, 996, 997, 998, 999}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("bounded heap kept %v, want %v", got, want)
+	}
+}
+
+func TestHeap_AddOrEvict(t *testing.T) {
+	h := NewBounded(testHeapObjectKeyFunc, compareInts, 2)
+	tests := []struct {
+		name string
+		obj  testHeapObject
+		want interface{}
+	}{
+		{"not full", mkHeapObj("a", 1), nil},
+		{"full", mkHeapObj("b", 2), nil},
+		{"evict head", mkHeapObj("c", 3), mkHeapObj("a", 1)},
+		{"evict itself", mkHeapObj("d", 0), mkHeapObj("d", 0)},
+		{"tie with head", mkHeapObj("e", 2), mkHeapObj("e", 2)},
+		{"update present", mkHeapObj("c", 4), nil},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := h.AddOrEvict(tt.obj)
+			if err != nil {
+				t.Fatalf("Heap.AddOrEvict() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Heap.AddOrEvict() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	for _, want := range []interface{}{mkHeapObj("b", 2), mkHeapObj("c", 4)} {
+		if got := h.Pop(); !reflect.DeepEqual(got, want) {
+			t.Errorf("Heap.Pop() = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestHeap_Clone(t *testing.T) {
+	h := New(testHeapObjectKeyFunc, compareInts)
+	for i := 0; i < 5; i++ {
+		h.AddOrUpdate(mkHeapObj(fmt.Sprint(i), i))
+	}
+
+	clone := h.Clone()
+	clone.Pop()
+	clone.AddOrUpdate(mkHeapObj("3", -1))
+	clone.AddOrUpdate(mkHeapObj("new", 10))
+	clone.Remove(mkHeapObj("4", 0))
+
+	want := []int{0, 1, 2, 3, 4}
+	got := []int{}
+	h.WalkSorted(func(obj interface{}) bool {
+		got = append(got, obj.(testHeapObject).val.(int))
+		return true
+	})
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("original heap = %v after mutating the clone, want %v", got, want)
+	}
+
+	want = []int{-1, 1, 2, 10}
+	got = []int{}
+	for clone.Len() > 0 {
+		got = append(got, clone.Pop().(testHeapObject).val.(int))
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("cloned heap = %v, want %v", got, want)
+	}
+	if h.Len() != 5 {
+		t.Errorf("Heap.Len() = %v, want 5", h.Len())
+	}
+}
+
+func TestHeap_PopN(t *testing.T) {
+	tests := []struct {
+		name string
+		n    int
+		want []int
+		left int
+	}{
+		{"zero", 0, []int{}, 5},
+		{"negative", -1, []int{}, 5},
+		{"less than size", 3, []int{1, 2, 3}, 2},
+		{"equal to size", 5, []int{1, 2, 3, 4, 5}, 0},
+		{"larger than size", 10, []int{1, 2, 3, 4, 5}, 0},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			h := New(testHeapObjectKeyFunc, compareInts)
+			for _, v := range []int{3, 5, 1, 4, 2} {
+				h.AddOrUpdate(mkHeapObj(fmt.Sprint(v), v))
+			}
+			got := []int{}
+			for _, obj := range h.PopN(tt.n) {
+				got = append(got, obj.(testHeapObject).val.(int))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Heap.PopN() = %v, want %v", got, tt.want)
+			}
+			if h.Len() != tt.left {
+				t.Errorf("Heap.Len() = %v, want %v", h.Len(), tt.left)
+			}
+		})
+	}
+
+	h := New(testHeapObjectKeyFunc, compareInts)
+	if got := h.PopN(3); got == nil || len(got) != 0 {
+		t.Errorf("Heap.PopN() = %#v on empty heap, want empty non-nil slice", got)
+	}
+}
+
+func TestHeap_OnPop(t *testing.T) {
+	popped := []int{}
+	h := New(testHeapObjectKeyFunc, compareInts, WithOnPop(func(obj interface{}) {
+		popped = append(popped, obj.(testHeapObject).val.(int))
+	}))
+	for _, v := range []int{3, 5, 1, 4, 2, 6} {
+		h.AddOrUpdate(mkHeapObj(fmt.Sprint(v), v))
+	}
+
+	// Peek, Remove and empty Pop do not fire the callback
+	h.Peek()
+	h.PeekSecond()
+	h.Remove(mkHeapObj("6", 6))
+	if len(popped) != 0 {
+		t.Errorf("OnPop should not be called, got %v", popped)
+	}
+
+	got := []int{h.Pop().(testHeapObject).val.(int)}
+	for _, obj := range h.PopN(2) {
+		got = append(got, obj.(testHeapObject).val.(int))
+	}
+	for h.Len() > 0 {
+		got = append(got, h.Pop().(testHeapObject).val.(int))
+	}
+	h.Pop()
+
+	want := []int{1, 2, 3, 4, 5}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Heap.Pop() = %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(popped, want) {
+		t.Errorf("OnPop got %v, want %v", popped, want)
+	}
+}
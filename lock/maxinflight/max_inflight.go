@@ -15,22 +15,41 @@
 package maxinflight
 
 import (
+	"context"
+	"math"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 var (
 	InfinityTokenBucket = NewInfinity()
 )
 
+// acquirePollInterval is the interval of retrying TryAcquire in AcquireContext
+// for the buckets which can not be waited on
+const acquirePollInterval = time.Millisecond
+
 type TokenBucket interface {
 	// TryAccept returns true if a token is taken immediately. Otherwise,
 	// it returns false.
 	TryAcquire() bool
+	// AcquireContext blocks until a token is taken or the context is done.
+	// It returns the context's error if no token is taken.
+	AcquireContext(ctx context.Context) error
 	// Release add a token back to the lock
 	Release()
+	// TryAcquireN returns true if n tokens are taken immediately. Otherwise,
+	// it returns false and no token is taken.
+	TryAcquireN(n uint32) bool
+	// ReleaseN adds n tokens back to the lock
+	ReleaseN(n uint32)
 	// Resize changes the max in flight lock's capacity
 	Resize(n uint32)
+	// InUse returns the number of tokens currently held
+	InUse() uint32
+	// Cap returns the max in flight lock's capacity
+	Cap() uint32
 }
 
 type TokenBucketType string
@@ -64,6 +83,29 @@ func newBucket(t TokenBucketType, size uint32) TokenBucket {
 	return nil
 }
 
+// pollAcquire calls tryAcquire every acquirePollInterval until it returns
+// true or the context is done
+func pollAcquire(ctx context.Context, tryAcquire func() bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if tryAcquire() {
+		return nil
+	}
+	ticker := time.NewTicker(acquirePollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if tryAcquire() {
+				return nil
+			}
+		}
+	}
+}
+
 // Infinity is a special lock, it always return true when
 // you try to acquire one token
 type infinity struct {
@@ -73,6 +115,10 @@ func (*infinity) TryAcquire() bool {
 	return true
 }
 
+func (*infinity) AcquireContext(ctx context.Context) error {
+	return ctx.Err()
+}
+
 // Release add a token back to the lock
 func (*infinity) Release() {
 }
@@ -81,6 +127,24 @@ func (*infinity) Release() {
 func (*infinity) Resize(n uint32) {
 }
 
+func (*infinity) TryAcquireN(n uint32) bool {
+	return true
+}
+
+// ReleaseN adds n tokens back to the lock
+func (*infinity) ReleaseN(n uint32) {
+}
+
+// InUse always returns 0 because the tokens are not counted
+func (*infinity) InUse() uint32 {
+	return 0
+}
+
+// Cap always returns math.MaxUint32
+func (*infinity) Cap() uint32 {
+	return math.MaxUint32
+}
+
 // use a larger range of values than max to avoid overflow when increacing count
 type atomicTokenBucket struct {
 	max   uint32 // range of 0 ~ 4,294,967,295
@@ -115,8 +179,12 @@ func (f *atomicTokenBucket) TryAcquire() bool {
 	return true
 }
 
+func (f *atomicTokenBucket) AcquireContext(ctx context.Context) error {
+	return pollAcquire(ctx, f.TryAcquire)
+}
+
 func (f *atomicTokenBucket) Release() {
-	if f.count <= 0 {
+	if atomic.LoadInt64(&f.count) <= 0 {
 		return
 	}
 	count := atomic.AddInt64(&f.count, -1)
@@ -125,12 +193,60 @@ func (f *atomicTokenBucket) Release() {
 	}
 }
 
+func (f *atomicTokenBucket) TryAcquireN(n uint32) bool {
+	for {
+		count := atomic.LoadInt64(&f.count)
+		max := int64(atomic.LoadUint32(&f.max))
+		newCount := count
+		if newCount < 0 {
+			// reset count to 0
+			newCount = 0
+		}
+		newCount += int64(n)
+		if newCount > max {
+			return false
+		}
+		// all or nothing, retry if count is changed by others
+		if atomic.CompareAndSwapInt64(&f.count, count, newCount) {
+			return true
+		}
+	}
+}
+
+func (f *atomicTokenBucket) ReleaseN(n uint32) {
+	for {
+		count := atomic.LoadInt64(&f.count)
+		if count <= 0 {
+			return
+		}
+		newCount := count - int64(n)
+		if newCount < 0 {
+			newCount = 0
+		}
+		if atomic.CompareAndSwapInt64(&f.count, count, newCount) {
+			return
+		}
+	}
+}
+
 func (f *atomicTokenBucket) Resize(n uint32) {
-	if f.max != n {
+	if atomic.LoadUint32(&f.max) != n {
 		atomic.StoreUint32(&f.max, n)
 	}
 }
 
+func (f *atomicTokenBucket) InUse() uint32 {
+	count := atomic.LoadInt64(&f.count)
+	if count < 0 {
+		return 0
+	}
+	return uint32(count)
+}
+
+func (f *atomicTokenBucket) Cap() uint32 {
+	return atomic.LoadUint32(&f.max)
+}
+
 type channelTokenBucket struct {
 	ch chan bool
 }
@@ -150,6 +266,18 @@ func (l *channelTokenBucket) TryAcquire() bool {
 	}
 }
 
+func (l *channelTokenBucket) AcquireContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	select {
+	case l.ch <- true:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func (l *channelTokenBucket) Release() {
 	select {
 	case <-l.ch:
@@ -157,10 +285,37 @@ func (l *channelTokenBucket) Release() {
 	}
 }
 
+// TryAcquireN takes tokens one by one and puts them back if it fails, so it
+// may fail under contention even if there are n free slots in the end.
+func (l *channelTokenBucket) TryAcquireN(n uint32) bool {
+	for i := uint32(0); i < n; i++ {
+		if !l.TryAcquire() {
+			// roll back the taken tokens
+			l.ReleaseN(i)
+			return false
+		}
+	}
+	return true
+}
+
+func (l *channelTokenBucket) ReleaseN(n uint32) {
+	for i := uint32(0); i < n; i++ {
+		l.Release()
+	}
+}
+
 func (l *channelTokenBucket) Resize(n uint32) {
 	// not implement
 }
 
+func (l *channelTokenBucket) InUse() uint32 {
+	return uint32(len(l.ch))
+}
+
+func (l *channelTokenBucket) Cap() uint32 {
+	return uint32(cap(l.ch))
+}
+
 type mutexTokenBucket struct {
 	count int64
 	max   uint32
@@ -174,10 +329,6 @@ func newMutex(n uint32) *mutexTokenBucket {
 }
 
 func (f *mutexTokenBucket) TryAcquire() bool {
-	if f.count >= int64(f.max) {
-		return false
-	}
-
 	f.m.Lock()
 	defer f.m.Unlock()
 
@@ -189,26 +340,44 @@ func (f *mutexTokenBucket) TryAcquire() bool {
 	return true
 }
 
+func (f *mutexTokenBucket) AcquireContext(ctx context.Context) error {
+	return pollAcquire(ctx, f.TryAcquire)
+}
+
 func (f *mutexTokenBucket) Release() {
+	f.m.Lock()
+	defer f.m.Unlock()
+
 	if f.count == 0 {
 		return
 	}
 
+	f.count--
+}
+
+func (f *mutexTokenBucket) TryAcquireN(n uint32) bool {
 	f.m.Lock()
 	defer f.m.Unlock()
 
-	if f.count == 0 {
-		return
+	if f.count+int64(n) > int64(f.max) {
+		return false
 	}
 
-	f.count--
+	f.count += int64(n)
+	return true
 }
 
-func (f *mutexTokenBucket) Resize(n uint32) {
-	if f.max == n {
-		return
+func (f *mutexTokenBucket) ReleaseN(n uint32) {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	f.count -= int64(n)
+	if f.count < 0 {
+		f.count = 0
 	}
+}
 
+func (f *mutexTokenBucket) Resize(n uint32) {
 	f.m.Lock()
 	defer f.m.Unlock()
 
@@ -216,3 +385,15 @@ func (f *mutexTokenBucket) Resize(n uint32) {
 		f.max = n
 	}
 }
+
+func (f *mutexTokenBucket) InUse() uint32 {
+	f.m.Lock()
+	defer f.m.Unlock()
+	return uint32(f.count)
+}
+
+func (f *mutexTokenBucket) Cap() uint32 {
+	f.m.Lock()
+	defer f.m.Unlock()
+	return f.max
+}
@@ -15,10 +15,12 @@
 package maxinflight
 
 import (
+	"context"
 	"math"
 	"sync"
 	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestMaxInflight_TryAcquire(t *testing.T) {
@@ -131,3 +133,188 @@ func Test_atomicLock_TryAcquire(t *testing.T) {
 		t.Errorf("atomicMaxInFlightLock.TryAcquire() = %v, want %v", success, want)
 	}
 }
+
+func TestMaxInflight_AcquireContext(t *testing.T) {
+	for _, bucketType := range []TokenBucketType{Atomic, Channel, Mutex} {
+		bucketType := bucketType
+		t.Run(string(bucketType), func(t *testing.T) {
+			l := newBucket(bucketType, 1)
+			if err := l.AcquireContext(context.Background()); err != nil {
+				t.Fatalf("AcquireContext() error = %v", err)
+			}
+
+			// the bucket is full, wait until timeout
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+			if err := l.AcquireContext(ctx); err != context.DeadlineExceeded {
+				t.Errorf("AcquireContext() on full bucket error = %v, want %v", err, context.DeadlineExceeded)
+			}
+
+			// the second acquirer unblocks after a Release
+			acquired := make(chan error, 1)
+			go func() {
+				acquired <- l.AcquireContext(context.Background())
+			}()
+			select {
+			case err := <-acquired:
+				t.Fatalf("AcquireContext() returned %v before Release", err)
+			case <-time.After(20 * time.Millisecond):
+			}
+			l.Release()
+			select {
+			case err := <-acquired:
+				if err != nil {
+					t.Errorf("AcquireContext() error = %v", err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("AcquireContext() is not unblocked after Release")
+			}
+
+			// the canceled acquirer returns promptly
+			ctx, cancel = context.WithCancel(context.Background())
+			go func() {
+				acquired <- l.AcquireContext(ctx)
+			}()
+			cancel()
+			select {
+			case err := <-acquired:
+				if err != context.Canceled {
+					t.Errorf("AcquireContext() error = %v, want %v", err, context.Canceled)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("AcquireContext() is not unblocked after cancel")
+			}
+		})
+	}
+}
+
+func TestInfinity_AcquireContext(t *testing.T) {
+	l := NewInfinity()
+	if err := l.AcquireContext(context.Background()); err != nil {
+		t.Errorf("AcquireContext() error = %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.AcquireContext(ctx); err != context.Canceled {
+		t.Errorf("AcquireContext() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestMaxInflight_InUse(t *testing.T) {
+	for _, bucketType := range []TokenBucketType{Atomic, Channel, Mutex} {
+		bucketType := bucketType
+		t.Run(string(bucketType), func(t *testing.T) {
+			l := newBucket(bucketType, 10)
+			if got := l.Cap(); got != 10 {
+				t.Errorf("Cap() = %v, want 10", got)
+			}
+			if got := l.InUse(); got != 0 {
+				t.Errorf("InUse() = %v, want 0", got)
+			}
+
+			const k = 7
+			for i := 0; i < k; i++ {
+				l.TryAcquire()
+			}
+			if got := l.InUse(); got != k {
+				t.Errorf("InUse() = %v, want %v", got, k)
+			}
+
+			// full bucket
+			for i := 0; i < 10; i++ {
+				l.TryAcquire()
+			}
+			if got := l.InUse(); got != 10 {
+				t.Errorf("InUse() = %v, want 10", got)
+			}
+
+			l.Release()
+			l.Release()
+			if got := l.InUse(); got != 8 {
+				t.Errorf("InUse() = %v, want 8", got)
+			}
+		})
+	}
+
+	l := NewInfinity()
+	l.TryAcquire()
+	if got := l.InUse(); got != 0 {
+		t.Errorf("infinity InUse() = %v, want 0", got)
+	}
+	if got := l.Cap(); got != math.MaxUint32 {
+		t.Errorf("infinity Cap() = %v, want %v", got, uint32(math.MaxUint32))
+	}
+}
+
+func TestMaxInflight_TryAcquireN(t *testing.T) {
+	for _, bucketType := range []TokenBucketType{Atomic, Channel, Mutex} {
+		bucketType := bucketType
+		t.Run(string(bucketType), func(t *testing.T) {
+			l := newBucket(bucketType, 5)
+			if !l.TryAcquireN(3) {
+				t.Fatalf("TryAcquireN(3) = false, want true")
+			}
+			// only 2 slots remain
+			if l.TryAcquireN(3) {
+				t.Errorf("TryAcquireN(3) = true with 2 slots remaining, want false")
+			}
+			if got := l.InUse(); got != 3 {
+				t.Errorf("InUse() = %v after failed TryAcquireN, want 3", got)
+			}
+			if !l.TryAcquireN(2) {
+				t.Errorf("TryAcquireN(2) = false with 2 slots remaining, want true")
+			}
+
+			l.ReleaseN(3)
+			if got := l.InUse(); got != 2 {
+				t.Errorf("InUse() = %v, want 2", got)
+			}
+			if !l.TryAcquireN(3) {
+				t.Errorf("TryAcquireN(3) = false after ReleaseN, want true")
+			}
+
+			// release more than taken
+			l.ReleaseN(10)
+			if got := l.InUse(); got != 0 {
+				t.Errorf("InUse() = %v, want 0", got)
+			}
+		})
+	}
+}
+
+func TestMaxInflight_TryAcquireNConcurrent(t *testing.T) {
+	// channel bucket is excluded because it may fail spuriously under contention
+	for _, bucketType := range []TokenBucketType{Atomic, Mutex} {
+		bucketType := bucketType
+		t.Run(string(bucketType), func(t *testing.T) {
+			l := newBucket(bucketType, 100)
+			g := sync.WaitGroup{}
+			var got uint32
+			for i := 0; i < 1000; i++ {
+				g.Add(1)
+				go func() {
+					defer g.Done()
+					if l.TryAcquireN(3) {
+						atomic.AddUint32(&got, 1)
+					}
+				}()
+			}
+			g.Wait()
+			// all or nothing, 33 * 3 = 99 tokens are taken
+			if got != 33 {
+				t.Errorf("TryAcquireN(3) succeeded %v times, want 33", got)
+			}
+			if inUse := l.InUse(); inUse != 99 {
+				t.Errorf("InUse() = %v, want 99", inUse)
+			}
+		})
+	}
+}
+
+func TestInfinity_TryAcquireN(t *testing.T) {
+	l := NewInfinity()
+	if !l.TryAcquireN(math.MaxUint32) {
+		t.Errorf("TryAcquireN() = false, want true")
+	}
+	l.ReleaseN(math.MaxUint32)
+}
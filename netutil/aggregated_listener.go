@@ -26,6 +26,12 @@ import (
 var (
 	ErrAccecptClosed     = &net.OpError{Op: "accept", Err: fmt.Errorf("use of closed network connection")}
 	ErrNoEnoughListeners = errors.New("must supply at least two listeners")
+	ErrListenerClosed    = errors.New("listener is closed")
+)
+
+const (
+	// errChanSize is the buffer size of the channel returned by Errors()
+	errChanSize = 100
 )
 
 // AggregatedListener is a listener aggregated by other listeners in
@@ -52,6 +58,33 @@ type AggregatedListener interface {
 	// AcceptUnix accepts the next unix incoming call and returns the new
 	// unix connection.
 	AcceptUnix() (*net.UnixConn, error)
+
+	// Add adds a listener and accepts it on background. The listener is
+	// closed when the aggregated listener is closed. It returns
+	// ErrListenerClosed if the aggregated listener is closed.
+	Add(ln net.Listener) error
+
+	// Errors returns a channel reporting the non-temporary accept errors of
+	// underlying listeners as *ListenerError, the listener which produced
+	// the error is not accepted any more. Errors are dropped if the channel
+	// is full. The channel is closed after all listeners are stopped.
+	Errors() <-chan error
+}
+
+// ListenerError is an accept error of an underlying listener of
+// AggregatedListener.
+type ListenerError struct {
+	// Addr is the address of the listener which produced the error
+	Addr net.Addr
+	Err  error
+}
+
+func (e *ListenerError) Error() string {
+	return fmt.Sprintf("listener %v: %v", e.Addr, e.Err)
+}
+
+func (e *ListenerError) Unwrap() error {
+	return e.Err
 }
 
 // TCPListener represent a tcp listener
@@ -83,13 +116,20 @@ type aggregatedListener struct {
 	acceptTCPC  chan *acceptResult
 	acceptUnixC chan *acceptResult
 
+	// mu guards listeners, running and closed
+	mu      sync.Mutex
 	tcpLns  []TCPListener
 	unixLns []UnixListener
 	lns     []net.Listener
+	// running is the number of running accept goroutines
+	running int
+	// closed is true if Close is called or all accept goroutines stopped
+	closed bool
 
 	closeOnce    sync.Once
 	closeAcceptC chan struct{}
 	closeC       chan struct{}
+	errC         chan error
 }
 
 // NewAggregatedListener aggregate all input listeners into one to
@@ -109,55 +149,58 @@ func NewAggregatedListener(listeners ...net.Listener) (AggregatedListener, error
 		acceptUnixC:  make(chan *acceptResult),
 		closeC:       make(chan struct{}),
 		closeAcceptC: make(chan struct{}),
+		errC:         make(chan error, errChanSize),
 		major:        listeners[0],
 	}
 
+	l.mu.Lock()
+	defer l.mu.Unlock()
 	for i := range listeners {
-		switch ln := listeners[i].(type) {
-		case TCPListener:
-			l.tcpLns = append(l.tcpLns, ln)
-		case UnixListener:
-			l.unixLns = append(l.unixLns, ln)
-		default:
-			l.lns = append(l.lns, ln)
-		}
+		l.addLocked(listeners[i])
 	}
-	l.acceptBackgroup()
 	return l, nil
 }
 
-func (l *aggregatedListener) acceptBackgroup() {
-	wg := sync.WaitGroup{}
-
-	for i := range l.lns {
-		ll := l.lns[i]
-		wg.Add(1)
-		go func() {
-			l.acceptFromListener(ll, l.acceptC)
-			wg.Done()
-		}()
-	}
-	for i := range l.tcpLns {
-		ll := l.tcpLns[i]
-		wg.Add(1)
-		go func() {
-			l.acceptFromListener(ll, l.acceptTCPC)
-			wg.Done()
-		}()
+// Add adds a listener and accepts it on background.
+func (l *aggregatedListener) Add(ln net.Listener) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return ErrListenerClosed
 	}
-	for i := range l.unixLns {
-		ll := l.unixLns[i]
-		wg.Add(1)
-		go func() {
-			l.acceptFromListener(ll, l.acceptUnixC)
-			wg.Done()
-		}()
+	l.addLocked(ln)
+	return nil
+}
+
+// addLocked categorizes ln and starts a goroutine to accept it, l.mu must
+// be held.
+func (l *aggregatedListener) addLocked(ln net.Listener) {
+	var resultChan chan *acceptResult
+	switch ll := ln.(type) {
+	case TCPListener:
+		l.tcpLns = append(l.tcpLns, ll)
+		resultChan = l.acceptTCPC
+	case UnixListener:
+		l.unixLns = append(l.unixLns, ll)
+		resultChan = l.acceptUnixC
+	default:
+		l.lns = append(l.lns, ll)
+		resultChan = l.acceptC
 	}
 
+	l.running++
 	go func() {
-		wg.Wait()
-		// close channel after all listener accecpt goroutine stopped
-		close(l.closeC)
+		l.acceptFromListener(ln, resultChan)
+
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.running--
+		if l.running == 0 {
+			// close channel after all listener accecpt goroutine stopped
+			l.closed = true
+			close(l.closeC)
+			close(l.errC)
+		}
 	}()
 }
 
@@ -172,6 +215,9 @@ func (l *aggregatedListener) acceptFromListener(ln net.Listener, resultChan chan
 				needBreak = false
 			}
 		}
+		if needBreak {
+			l.reportError(ln, err)
+		}
 
 		select {
 		case resultChan <- &acceptResult{conn, err}:
@@ -184,6 +230,28 @@ func (l *aggregatedListener) acceptFromListener(ln net.Listener, resultChan chan
 	}
 }
 
+// reportError sends the accept error of ln to errC, the errors caused by
+// closing the aggregated listener are ignored.
+func (l *aggregatedListener) reportError(ln net.Listener, err error) {
+	// Close holds the lock until all listeners are closed
+	l.mu.Lock()
+	closed := l.closed
+	l.mu.Unlock()
+	if closed {
+		return
+	}
+	select {
+	case l.errC <- &ListenerError{Addr: ln.Addr(), Err: err}:
+	default:
+	}
+}
+
+// Errors returns a channel reporting the non-temporary accept errors of
+// underlying listeners.
+func (l *aggregatedListener) Errors() <-chan error {
+	return l.errC
+}
+
 // AcceptTCP accepts the next tcp incoming call and returns the new
 // tcp connection.
 func (l *aggregatedListener) AcceptTCP() (*net.TCPConn, error) {
@@ -233,6 +301,10 @@ func (l *aggregatedListener) Addr() net.Addr {
 func (l *aggregatedListener) Close() error {
 	var closeErr error
 	l.closeOnce.Do(func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.closed = true
+
 		var errors []error
 		for _, ln := range l.lns {
 			if err := ln.Close(); err != nil {
@@ -257,6 +329,8 @@ func (l *aggregatedListener) Close() error {
 
 // Addrs returns all listeners' network addresses.
 func (l *aggregatedListener) Addrs() []net.Addr {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 	var addrs []net.Addr
 
 	for _, ln := range l.lns {
@@ -273,6 +347,8 @@ func (l *aggregatedListener) Addrs() []net.Addr {
 
 // TCPAddrs returns all tcp listeners' network addresses.
 func (l *aggregatedListener) TCPAddrs() []*net.TCPAddr {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 	var addrs []*net.TCPAddr
 	for _, ln := range l.tcpLns {
 		addrs = append(addrs, ln.Addr().(*net.TCPAddr))
@@ -282,8 +358,10 @@ func (l *aggregatedListener) TCPAddrs() []*net.TCPAddr {
 
 // UnixAddrs returns all unix listeners' network addresses.
 func (l *aggregatedListener) UnixAddrs() []*net.UnixAddr {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 	var addrs []*net.UnixAddr
-	for _, ln := range l.tcpLns {
+	for _, ln := range l.unixLns {
 		addrs = append(addrs, ln.Addr().(*net.UnixAddr))
 	}
 	return addrs
@@ -263,3 +263,91 @@ func TestAggregatedListener_AcceptUnix(t *testing.T) {
 		t.Fatalf("got = %v, want = %v", got, attempts)
 	}
 }
+
+func TestAggregatedListener_Addrs(t *testing.T) {
+	ln, tcpLn, unixLn := createTestAggregatedLister(t)
+	defer ln.Close()
+
+	tcpAddrs := ln.TCPAddrs()
+	if len(tcpAddrs) != 1 || tcpAddrs[0].String() != tcpLn.Addr().String() {
+		t.Errorf("TCPAddrs() = %v, want [%v]", tcpAddrs, tcpLn.Addr())
+	}
+	unixAddrs := ln.UnixAddrs()
+	if len(unixAddrs) != 1 || unixAddrs[0].String() != unixLn.Addr().String() {
+		t.Errorf("UnixAddrs() = %v, want [%v]", unixAddrs, unixLn.Addr())
+	}
+	if addrs := ln.Addrs(); len(addrs) != 2 {
+		t.Errorf("Addrs() = %v, want 2 addresses", addrs)
+	}
+}
+
+func TestAggregatedListener_Add(t *testing.T) {
+	ln, _, _ := createTestAggregatedLister(t)
+
+	tcpLn2, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ln.Add(tcpLn2); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if addrs := ln.TCPAddrs(); len(addrs) != 2 {
+		t.Errorf("TCPAddrs() = %v, want 2 addresses", addrs)
+	}
+
+	go func() {
+		c, err := net.Dial("tcp", tcpLn2.Addr().String())
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		c.Close()
+	}()
+	c, err := ln.AcceptTCP()
+	if err != nil {
+		t.Fatalf("AcceptTCP() error = %v", err)
+	}
+	if c.LocalAddr().String() != tcpLn2.Addr().String() {
+		t.Errorf("AcceptTCP() accepted from %v, want %v", c.LocalAddr(), tcpLn2.Addr())
+	}
+	c.Close()
+
+	ln.Close()
+	// the added listener is closed too
+	if _, err := tcpLn2.Accept(); err == nil {
+		t.Errorf("the added listener should be closed")
+	}
+
+	tcpLn3, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tcpLn3.Close()
+	if err := ln.Add(tcpLn3); !errors.Is(err, ErrListenerClosed) {
+		t.Errorf("Add() after Close error = %v, want %v", err, ErrListenerClosed)
+	}
+}
+
+func TestAggregatedListener_Errors(t *testing.T) {
+	ln, _, unixLn := createTestAggregatedLister(t)
+
+	unixLn.Close()
+	select {
+	case err := <-ln.Errors():
+		var lnErr *ListenerError
+		if !errors.As(err, &lnErr) {
+			t.Fatalf("Errors() got %v, want *ListenerError", err)
+		}
+		if lnErr.Addr.String() != unixLn.Addr().String() {
+			t.Errorf("Errors() got error of %v, want %v", lnErr.Addr, unixLn.Addr())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting for accept error")
+	}
+
+	ln.Close()
+	// errors caused by Close are not reported, and the channel is closed
+	for err := range ln.Errors() {
+		t.Errorf("Errors() got unexpected error %v after Close", err)
+	}
+}
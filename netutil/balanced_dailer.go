@@ -38,6 +38,10 @@ type BalancedDialer interface {
 	// Balanceable networks are "tcp", "tcp4" (IPv4-only), "tcp6" (IPv6-only),
 	// "udp", "udp4" (IPv4-only), "udp6" (IPv6-only), other networks will not be
 	// balanced.
+	//
+	// Because UDP is connectionless, dialing an udp address does not fail even
+	// if the remote host is unreachable, so the address picked by balancer is
+	// used unless it can not be dialed locally, e.g. there is no route to it.
 	DialContext(ctx context.Context, network, address string) (net.Conn, error)
 }
 
@@ -59,11 +63,24 @@ type Balancer interface {
 	Balance(ctx context.Context, addrList []net.Addr) []net.Addr
 }
 
+// ConnTracker is an optional interface implemented by a Balancer which
+// tracks the connections dialed to each address. After a connection to addr
+// is established, the dialer calls Track and returns the connection returned
+// by it, which is usually a TrackedConn notifying the balancer on Close.
+type ConnTracker interface {
+	Track(addr net.Addr, conn net.Conn) net.Conn
+}
+
 type Options struct {
 	// BalancerBuilder build a client side load balancer
 	BalancerBuilder BalancerBuilder
 	// custom resolver, If not set, net.DefaultResolver will be used
 	Resolver Resolver
+	// UnhealthyCooldown is how long an address is considered unhealthy after
+	// dialing it fails. Unhealthy addresses are moved to the back of the
+	// balanced addresses, so they are only tried if all healthy addresses
+	// fail. If not set, health of addresses is not tracked.
+	UnhealthyCooldown time.Duration
 	// custom dail function, If not set, net.DailContext will be used
 	dialer func(ctx context.Context, network, address string) (net.Conn, error)
 }
@@ -75,6 +92,10 @@ type baseBalancedDialer struct {
 	dial            func(ctx context.Context, network, address string) (net.Conn, error)
 	balancerbuilder BalancerBuilder
 	balancers       sync.Map
+
+	unhealthyCooldown time.Duration
+	// unhealthy maps network/address to the time its cooldown expires
+	unhealthy sync.Map
 }
 
 func NewBalancedDialer(opt Options) BalancedDialer {
@@ -97,6 +118,9 @@ func NewBalancedDialer(opt Options) BalancedDialer {
 	} else {
 		d.balancerbuilder = &rrBalancerBuilder{}
 	}
+	if opt.UnhealthyCooldown > 0 {
+		d.unhealthyCooldown = opt.UnhealthyCooldown
+	}
 	return d
 }
 
@@ -163,26 +187,36 @@ func (d *baseBalancedDialer) lookupAddrs(ctx context.Context, network, addr stri
 }
 
 func (d *baseBalancedDialer) dialSerial(ctx context.Context, network, host string, addrList AddrList) (net.Conn, error) {
+	var balancer Balancer
 	if len(addrList) > 1 {
 		// TODO: consider cgo dns resolver
 		// purego dns will always return the same dns list
 		// sort.Sort(addrList)
 
 		// get balancer to resort addresses
-		b, ok := d.balancers.Load(host)
+		// the address lists of different networks are different, e.g. udp4
+		// and udp6, so balance them separately
+		key := network + "/" + host
+		b, ok := d.balancers.Load(key)
 		if !ok {
-			b, _ = d.balancers.LoadOrStore(host, d.balancerbuilder.Build(host, addrList))
+			b, _ = d.balancers.LoadOrStore(key, d.balancerbuilder.Build(host, addrList))
 		}
-		balancer := b.(Balancer)
+		balancer = b.(Balancer)
 		addrList = balancer.Balance(ctx, addrList)
+		addrList = d.sortByHealth(network, addrList)
 	}
 	var firstErr error
 	for _, addr := range addrList {
 		addrstr := addr.String()
 		c, err := d.dial(ctx, network, addrstr)
 		if err == nil {
+			d.markHealthy(network, addrstr)
+			if tracker, ok := balancer.(ConnTracker); ok {
+				c = tracker.Track(addr, c)
+			}
 			return c, nil
 		}
+		d.markUnhealthy(network, addrstr)
 		if firstErr == nil {
 			firstErr = err
 		}
@@ -193,6 +227,42 @@ func (d *baseBalancedDialer) dialSerial(ctx context.Context, network, host strin
 	return nil, firstErr
 }
 
+// sortByHealth moves the unhealthy addresses to the back of addrList and
+// keeps the order of the others.
+func (d *baseBalancedDialer) sortByHealth(network string, addrList AddrList) AddrList {
+	if d.unhealthyCooldown <= 0 {
+		return addrList
+	}
+	now := time.Now()
+	healthy := make(AddrList, 0, len(addrList))
+	unhealthy := AddrList{}
+	for _, addr := range addrList {
+		key := network + "/" + addr.String()
+		if v, ok := d.unhealthy.Load(key); ok {
+			if now.Before(v.(time.Time)) {
+				unhealthy = append(unhealthy, addr)
+				continue
+			}
+			// cooldown expired
+			d.unhealthy.Delete(key)
+		}
+		healthy = append(healthy, addr)
+	}
+	return append(healthy, unhealthy...)
+}
+
+func (d *baseBalancedDialer) markUnhealthy(network, addr string) {
+	if d.unhealthyCooldown > 0 {
+		d.unhealthy.Store(network+"/"+addr, time.Now().Add(d.unhealthyCooldown))
+	}
+}
+
+func (d *baseBalancedDialer) markHealthy(network, addr string) {
+	if d.unhealthyCooldown > 0 {
+		d.unhealthy.Delete(network + "/" + addr)
+	}
+}
+
 type AddrList []net.Addr
 
 func (s AddrList) Len() int {
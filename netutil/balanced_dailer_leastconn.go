@@ -0,0 +1,116 @@
+// Copyright 2023 jim.zoumo@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netutil
+
+import (
+	"context"
+	"net"
+	"sort"
+	"sync"
+)
+
+// LeastConnBalancerBuilder creates a Balancer which puts the addresses with
+// less active connections first, it is better than RoundRobin for long-lived
+// connections. Addresses with the same active connections keep their order.
+type LeastConnBalancerBuilder struct{}
+
+func (b *LeastConnBalancerBuilder) Build(host string, addrs []net.Addr) Balancer {
+	return &leastConnBalancer{
+		host:  host,
+		conns: map[string]int{},
+	}
+}
+
+var _ ConnTracker = &leastConnBalancer{}
+
+type leastConnBalancer struct {
+	host string
+
+	mu sync.Mutex
+	// conns is the number of active connections of each address, addresses
+	// without active connections are removed.
+	conns map[string]int
+}
+
+func (b *leastConnBalancer) Balance(ctx context.Context, addrs []net.Addr) []net.Addr {
+	if len(addrs) <= 1 {
+		return addrs
+	}
+	b.mu.Lock()
+	counts := make([]int, len(addrs))
+	for i, addr := range addrs {
+		counts[i] = b.conns[addr.String()]
+	}
+	b.mu.Unlock()
+
+	indexes := make([]int, len(addrs))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sort.SliceStable(indexes, func(i, j int) bool {
+		return counts[indexes[i]] < counts[indexes[j]]
+	})
+	// do not sort addrs in place, it may be shared by the caller
+	newAddr := make([]net.Addr, 0, len(addrs))
+	for _, i := range indexes {
+		newAddr = append(newAddr, addrs[i])
+	}
+	return newAddr
+}
+
+// Track records an active connection to addr until the returned connection
+// is closed.
+func (b *leastConnBalancer) Track(addr net.Addr, conn net.Conn) net.Conn {
+	key := addr.String()
+	b.mu.Lock()
+	b.conns[key]++
+	b.mu.Unlock()
+	return NewTrackedConn(conn, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.conns[key]--
+		if b.conns[key] <= 0 {
+			delete(b.conns, key)
+		}
+	})
+}
+
+// TrackedConn wraps a net.Conn and calls onClose once it is closed, no
+// matter how many times Close is called.
+type TrackedConn struct {
+	net.Conn
+
+	closeOnce sync.Once
+	onClose   func()
+}
+
+// NewTrackedConn returns a TrackedConn wrapping conn
+func NewTrackedConn(conn net.Conn, onClose func()) *TrackedConn {
+	return &TrackedConn{
+		Conn:    conn,
+		onClose: onClose,
+	}
+}
+
+// Close closes the underlying connection and calls onClose on the first call
+func (c *TrackedConn) Close() error {
+	err := c.Conn.Close()
+	c.closeOnce.Do(func() {
+		if c.onClose != nil {
+			c.onClose()
+		}
+	})
+	return err
+}
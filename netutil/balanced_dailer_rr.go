@@ -58,7 +58,9 @@ func (b *rrBalancer) Balance(ctx context.Context, addrs []net.Addr) []net.Addr {
 	}
 	nextIndex := atomic.AddUint64(&b.next, 1)
 	nextIndex = nextIndex % uint64(addrsLen)
-	newAddr := addrs[nextIndex:]
+	// do not append to addrs[nextIndex:] directly, it may overwrite addrs
+	newAddr := make([]net.Addr, 0, addrsLen)
+	newAddr = append(newAddr, addrs[nextIndex:]...)
 	newAddr = append(newAddr, addrs[0:nextIndex]...)
 	return newAddr
 }
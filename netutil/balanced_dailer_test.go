@@ -0,0 +1,249 @@
+// Copyright 2023 jim.zoumo@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netutil
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type fakeResolver struct {
+	ips []net.IPAddr
+}
+
+func (r *fakeResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	return r.ips, nil
+}
+
+func (r *fakeResolver) LookupPort(ctx context.Context, network, service string) (int, error) {
+	return strconv.Atoi(service)
+}
+
+func TestBalancedDialer_DialUDP(t *testing.T) {
+	resolver := &fakeResolver{
+		ips: []net.IPAddr{
+			{IP: net.ParseIP("127.0.0.1")},
+			{IP: net.ParseIP("127.0.0.2")},
+			{IP: net.ParseIP("::1")},
+			{IP: net.ParseIP("127.0.0.3")},
+		},
+	}
+	d := NewBalancedDialer(Options{Resolver: resolver})
+
+	count := map[string]int{}
+	for i := 0; i < 9; i++ {
+		conn, err := d.DialContext(context.Background(), "udp4", "example.com:53")
+		if err != nil {
+			t.Fatalf("BalancedDialer.DialContext() error = %v", err)
+		}
+		addr, ok := conn.RemoteAddr().(*net.UDPAddr)
+		if !ok {
+			t.Fatalf("BalancedDialer.DialContext() got remote addr %T, want *net.UDPAddr", conn.RemoteAddr())
+		}
+		count[addr.IP.String()]++
+		conn.Close()
+	}
+
+	want := map[string]int{
+		"127.0.0.1": 3,
+		"127.0.0.2": 3,
+		"127.0.0.3": 3,
+	}
+	if len(count) != len(want) {
+		t.Errorf("BalancedDialer.DialContext() dialed %v, want %v", count, want)
+	}
+	for ip, n := range want {
+		if count[ip] != n {
+			t.Errorf("BalancedDialer.DialContext() dialed %v %v times, want %v", ip, count[ip], n)
+		}
+	}
+}
+
+func TestBalancedDialer_DialUDPFallback(t *testing.T) {
+	dialed := []string{}
+	d := NewBalancedDialer(Options{
+		Resolver: &fakeResolver{
+			ips: []net.IPAddr{
+				{IP: net.ParseIP("127.0.0.1")},
+				{IP: net.ParseIP("127.0.0.2")},
+			},
+		},
+	}).(*baseBalancedDialer)
+	d.dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+		dialed = append(dialed, address)
+		if address == "127.0.0.1:53" {
+			return nil, &net.OpError{Op: "dial", Net: network, Err: errNoSuitableAddress}
+		}
+		return (&net.Dialer{}).DialContext(ctx, network, address)
+	}
+
+	for i := 0; i < 2; i++ {
+		conn, err := d.DialContext(context.Background(), "udp4", "example.com:53")
+		if err != nil {
+			t.Fatalf("BalancedDialer.DialContext() error = %v", err)
+		}
+		if got := conn.RemoteAddr().String(); got != "127.0.0.2:53" {
+			t.Errorf("BalancedDialer.DialContext() remote addr = %v, want 127.0.0.2:53", got)
+		}
+		conn.Close()
+	}
+	if len(dialed) != 3 {
+		t.Errorf("BalancedDialer.DialContext() dialed %v, want 3 attempts", dialed)
+	}
+}
+
+func TestBalancedDialer_UnhealthyCooldown(t *testing.T) {
+	cooldown := 200 * time.Millisecond
+	d := NewBalancedDialer(Options{
+		Resolver: &fakeResolver{
+			ips: []net.IPAddr{
+				{IP: net.ParseIP("127.0.0.1")},
+				{IP: net.ParseIP("127.0.0.2")},
+			},
+		},
+		UnhealthyCooldown: cooldown,
+	}).(*baseBalancedDialer)
+	bad := 0
+	d.dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+		if address == "127.0.0.1:80" {
+			bad++
+			return nil, &net.OpError{Op: "dial", Net: network, Err: errNoSuitableAddress}
+		}
+		c, _ := net.Pipe()
+		return c, nil
+	}
+	dial := func() {
+		conn, err := d.DialContext(context.Background(), "tcp4", "example.com:80")
+		if err != nil {
+			t.Fatalf("BalancedDialer.DialContext() error = %v", err)
+		}
+		conn.Close()
+	}
+
+	// the bad address is picked first by round robin at most once
+	for i := 0; i < 10; i++ {
+		dial()
+	}
+	if bad != 1 {
+		t.Errorf("BalancedDialer.DialContext() dialed the bad address %v times during cooldown, want 1", bad)
+	}
+
+	// the bad address is tried again after cooldown
+	time.Sleep(cooldown)
+	for i := 0; i < 2; i++ {
+		dial()
+	}
+	if bad != 2 {
+		t.Errorf("BalancedDialer.DialContext() dialed the bad address %v times after cooldown, want 2", bad)
+	}
+}
+
+func TestRRBalancer_Balance(t *testing.T) {
+	addrs := make([]net.Addr, 0, 10)
+	for i := 1; i <= 3; i++ {
+		addrs = append(addrs, &net.UDPAddr{IP: net.IPv4(127, 0, 0, byte(i)), Port: 53})
+	}
+	want := make([]net.Addr, len(addrs))
+	copy(want, addrs)
+
+	b := &rrBalancer{}
+	seen := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		got := b.Balance(context.Background(), addrs)
+		if len(got) != len(addrs) {
+			t.Fatalf("rrBalancer.Balance() got %v, want %v addresses", got, len(addrs))
+		}
+		seen[got[0].String()] = true
+	}
+	if len(seen) != 3 {
+		t.Errorf("rrBalancer.Balance() picked %v, want all addresses", seen)
+	}
+	for i := range want {
+		if addrs[i] != want[i] {
+			t.Errorf("rrBalancer.Balance() modified input, got %v, want %v", addrs, want)
+			break
+		}
+	}
+}
+
+func TestLeastConnBalancer(t *testing.T) {
+	d := NewBalancedDialer(Options{
+		Resolver: &fakeResolver{
+			ips: []net.IPAddr{
+				{IP: net.ParseIP("127.0.0.1")},
+				{IP: net.ParseIP("127.0.0.2")},
+				{IP: net.ParseIP("127.0.0.3")},
+			},
+		},
+		BalancerBuilder: &LeastConnBalancerBuilder{},
+	}).(*baseBalancedDialer)
+	var dialed []string
+	d.dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+		dialed = append(dialed, address)
+		c, _ := net.Pipe()
+		return c, nil
+	}
+	dial := func() net.Conn {
+		conn, err := d.DialContext(context.Background(), "tcp4", "example.com:80")
+		if err != nil {
+			t.Fatalf("BalancedDialer.DialContext() error = %v", err)
+		}
+		return conn
+	}
+	countDialed := func() map[string]int {
+		count := map[string]int{}
+		for _, addr := range dialed {
+			count[addr]++
+		}
+		return count
+	}
+
+	conns := map[string][]net.Conn{}
+	for i := 0; i < 6; i++ {
+		conn := dial()
+		addr := dialed[len(dialed)-1]
+		conns[addr] = append(conns[addr], conn)
+	}
+	for _, addr := range []string{"127.0.0.1:80", "127.0.0.2:80", "127.0.0.3:80"} {
+		if n := countDialed()[addr]; n != 2 {
+			t.Errorf("BalancedDialer.DialContext() dialed %v %v times, want 2", addr, n)
+		}
+	}
+
+	// close all connections of one address, closing twice counts once
+	for _, conn := range conns["127.0.0.2:80"] {
+		conn.Close()
+		conn.Close()
+	}
+	dialed = nil
+	for i := 0; i < 2; i++ {
+		defer dial().Close()
+	}
+	if n := countDialed()["127.0.0.2:80"]; n != 2 {
+		t.Errorf("BalancedDialer.DialContext() dialed %v, want 127.0.0.2:80 twice", dialed)
+	}
+
+	// all addresses have 2 connections now, spread load again
+	dialed = nil
+	for i := 0; i < 3; i++ {
+		defer dial().Close()
+	}
+	if count := countDialed(); len(count) != 3 {
+		t.Errorf("BalancedDialer.DialContext() dialed %v, want all addresses", dialed)
+	}
+}
@@ -46,6 +46,18 @@ func (dev *Interface) IsLoopback() bool {
 	return (dev.Flags & net.FlagLoopback) > 0
 }
 
+// IsUp returns true if the net interface is up
+func (dev *Interface) IsUp() bool {
+	return (dev.Flags & net.FlagUp) > 0
+}
+
+// HardwareAddr returns the MAC address of the net interface in string form,
+// e.g. "00:00:5e:00:53:01". It returns "" if the interface has no MAC
+// address. Use dev.Interface.HardwareAddr to get the raw address.
+func (dev *Interface) HardwareAddr() string {
+	return dev.Interface.HardwareAddr.String()
+}
+
 // Addr represents a network end point address.
 type Addr struct {
 	*net.IPNet
@@ -67,14 +79,14 @@ func (addr Addr) MaskSize() int {
 	return mask
 }
 
-// IsIPv4 returns true if the ip is not an IPv4 address
+// IsIPv4 returns true if the ip is an IPv4 address
 func (addr Addr) IsIPv4() bool {
 	return addr.IP.To4() != nil
 }
 
-// IsIPv6 returns true if the ip is not an IPv6 address
+// IsIPv6 returns true if the ip is an IPv6 address except IPv4-mapped IPv6 address
 func (addr Addr) IsIPv6() bool {
-	return len(addr.IP) == net.IPv6len
+	return len(addr.IP) == net.IPv6len && addr.IP.To4() == nil
 }
 
 // IsLoopback reports whether ip is a loopback address.
@@ -95,6 +107,37 @@ func (addrs AddrSlice) Contains(ip string) bool {
 	return false
 }
 
+// IPv4 returns the IPv4 addresses in the collection
+func (addrs AddrSlice) IPv4() AddrSlice {
+	ret := AddrSlice{}
+	for _, addr := range addrs {
+		if addr.IsIPv4() {
+			ret = append(ret, addr)
+		}
+	}
+	return ret
+}
+
+// IPv6 returns the IPv6 addresses in the collection
+func (addrs AddrSlice) IPv6() AddrSlice {
+	ret := AddrSlice{}
+	for _, addr := range addrs {
+		if addr.IsIPv6() {
+			ret = append(ret, addr)
+		}
+	}
+	return ret
+}
+
+// First returns the first address in the list
+// It returns nil if there is no element in the slice
+func (addrs AddrSlice) First() *Addr {
+	if len(addrs) == 0 {
+		return nil
+	}
+	return &addrs[0]
+}
+
 // InterfaceSlice reprecents a list of net interfaces
 type InterfaceSlice []Interface
 
@@ -118,14 +161,13 @@ func (ifaces InterfaceSlice) Contains(name string) bool {
 	return false
 }
 
-// Filter filters some interfaces by filsterFunc if it returns true
+// Filter returns the interfaces for which filterFunc returns true
 func (ifaces InterfaceSlice) Filter(filterFunc func(iface Interface) bool) InterfaceSlice {
 	ret := []Interface{}
 	for _, iface := range ifaces {
 		if filterFunc(iface) {
-			continue
+			ret = append(ret, iface)
 		}
-		ret = append(ret, iface)
 	}
 	return ret
 }
@@ -167,6 +209,17 @@ func InterfacesByLoopback() (InterfaceSlice, error) {
 	return ret, nil
 }
 
+// InterfacesByUp returns a list of network interfaces which are up.
+func InterfacesByUp() (InterfaceSlice, error) {
+	ifaces, err := Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	return ifaces.Filter(func(iface Interface) bool {
+		return iface.IsUp()
+	}), nil
+}
+
 // InterfacesByIP returns the local network interfaces that is using the
 // specified IP address.
 func InterfacesByIP(ip string) (InterfaceSlice, error) {
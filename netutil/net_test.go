@@ -23,12 +23,14 @@ import (
 var (
 	ifaceLo = Interface{
 		Interface: net.Interface{
-			Name: "lo",
+			Name:  "lo",
+			Flags: net.FlagUp | net.FlagLoopback,
 		},
 	}
 	ifaceEth0 = Interface{
 		Interface: net.Interface{
-			Name: "eth0",
+			Name:         "eth0",
+			HardwareAddr: net.HardwareAddr{0x00, 0x00, 0x5e, 0x00, 0x53, 0x01},
 		},
 	}
 
@@ -78,15 +80,41 @@ func TestInterfaceSlice_Filter(t *testing.T) {
 		want       InterfaceSlice
 	}{
 		{
-			"",
+			"keepMatched",
 			ifaceCases,
 			func(iface Interface) bool {
 				return iface.Name == "lo"
 			},
 			InterfaceSlice{
-				ifaceEth0,
+				ifaceLo,
 			},
 		},
+		{
+			"up",
+			ifaceCases,
+			func(iface Interface) bool {
+				return iface.IsUp()
+			},
+			InterfaceSlice{
+				ifaceLo,
+			},
+		},
+		{
+			"keepAll",
+			ifaceCases,
+			func(iface Interface) bool {
+				return true
+			},
+			ifaceCases,
+		},
+		{
+			"keepNone",
+			ifaceCases,
+			func(iface Interface) bool {
+				return false
+			},
+			InterfaceSlice{},
+		},
 	}
 	for i := range tests {
 		tt := tests[i]
@@ -98,6 +126,31 @@ func TestInterfaceSlice_Filter(t *testing.T) {
 	}
 }
 
+func TestInterfacesByUp(t *testing.T) {
+	ifaces, err := InterfacesByUp()
+	if err != nil {
+		t.Fatalf("InterfacesByUp() error = %v", err)
+	}
+	for _, iface := range ifaces {
+		if !iface.IsUp() {
+			t.Errorf("InterfacesByUp() got interface %v which is not up", iface.Name)
+		}
+	}
+	// the loopback interface is always up
+	if !ifaces.Contains("lo") {
+		t.Errorf("InterfacesByUp() got %v, want lo", ifaces)
+	}
+}
+
+func TestInterface_HardwareAddr(t *testing.T) {
+	if got := ifaceEth0.HardwareAddr(); got != "00:00:5e:00:53:01" {
+		t.Errorf("Interface.HardwareAddr() = %v, want 00:00:5e:00:53:01", got)
+	}
+	if got := ifaceLo.HardwareAddr(); got != "" {
+		t.Errorf("Interface.HardwareAddr() = %v, want empty", got)
+	}
+}
+
 func TestInterfaceSlice_Get(t *testing.T) {
 	tests := []struct {
 		name   string
@@ -185,8 +238,11 @@ func TestAddr_IsIPv6(t *testing.T) {
 		addr Addr
 		want bool
 	}{
-		{"", Addr{&net.IPNet{IP: net.IPv4(127, 0, 0, 1)}}, true},
+		{"", Addr{&net.IPNet{IP: net.IPv4(127, 0, 0, 1)}}, false},
+		{"", Addr{&net.IPNet{IP: net.ParseIP("::ffff:192.0.2.1")}}, false},
+		{"", Addr{&net.IPNet{IP: []byte{1, 1, 1, 1}}}, false},
 		{"", Addr{&net.IPNet{IP: net.ParseIP("fe80::1ba8:6946:ab33:da50")}}, true},
+		{"", Addr{&net.IPNet{IP: net.IPv6loopback}}, true},
 		{"", Addr{&net.IPNet{IP: []byte{1, 1, 1, 1, 1}}}, false},
 	}
 	for i := range tests {
@@ -198,3 +254,72 @@ func TestAddr_IsIPv6(t *testing.T) {
 		})
 	}
 }
+
+var (
+	addrV4       = Addr{&net.IPNet{IP: []byte{192, 168, 0, 1}}}
+	addrV4Mapped = Addr{&net.IPNet{IP: net.IPv4(127, 0, 0, 1)}}
+	addrV6       = Addr{&net.IPNet{IP: net.ParseIP("fe80::1ba8:6946:ab33:da50")}}
+	addrV6Lo     = Addr{&net.IPNet{IP: net.IPv6loopback}}
+	addrCases    = AddrSlice{addrV6, addrV4, addrV6Lo, addrV4Mapped}
+)
+
+func TestAddrSlice_IPv4(t *testing.T) {
+	tests := []struct {
+		name  string
+		addrs AddrSlice
+		want  AddrSlice
+	}{
+		{"mixed", addrCases, AddrSlice{addrV4, addrV4Mapped}},
+		{"onlyIPv6", AddrSlice{addrV6, addrV6Lo}, AddrSlice{}},
+		{"empty", nil, AddrSlice{}},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.addrs.IPv4(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("AddrSlice.IPv4() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddrSlice_IPv6(t *testing.T) {
+	tests := []struct {
+		name  string
+		addrs AddrSlice
+		want  AddrSlice
+	}{
+		{"mixed", addrCases, AddrSlice{addrV6, addrV6Lo}},
+		{"onlyIPv4", AddrSlice{addrV4, addrV4Mapped}, AddrSlice{}},
+		{"empty", nil, AddrSlice{}},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.addrs.IPv6(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("AddrSlice.IPv6() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddrSlice_First(t *testing.T) {
+	tests := []struct {
+		name  string
+		addrs AddrSlice
+		want  *Addr
+	}{
+		{"mixed", addrCases, &addrV6},
+		{"firstIPv4", addrCases.IPv4(), &addrV4},
+		{"noIPv6", AddrSlice{addrV4}.IPv6(), nil},
+		{"empty", nil, nil},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.addrs.First(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("AddrSlice.First() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2023 jim.zoumo@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netutil
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+)
+
+// FreePort asks the OS for a free TCP port on all interfaces.
+func FreePort() (int, error) {
+	return FreePortOn("")
+}
+
+// FreePortOn asks the OS for a free TCP port on the given host.
+//
+// The port is released before returning, so it may be taken by others
+// before it is used.
+func FreePortOn(host string) (int, error) {
+	ln, err := net.Listen("tcp", net.JoinHostPort(host, "0"))
+	if err != nil {
+		return 0, err
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port, nil
+}
+
+// FreePorts asks the OS for n distinct free TCP ports on all interfaces.
+func FreePorts(n int) ([]int, error) {
+	// keep all listeners open until all ports are allocated, so that the
+	// same port is not returned twice
+	lns := make([]net.Listener, 0, n)
+	defer func() {
+		for _, ln := range lns {
+			ln.Close()
+		}
+	}()
+	ports := make([]int, 0, n)
+	for i := 0; i < n; i++ {
+		ln, err := net.Listen("tcp", ":0")
+		if err != nil {
+			return nil, err
+		}
+		lns = append(lns, ln)
+		ports = append(ports, ln.Addr().(*net.TCPAddr).Port)
+	}
+	return ports, nil
+}
+
+// WaitForPort dials the TCP address every interval until it connects or ctx
+// is done. It returns nil as soon as a connection is established, otherwise
+// an error wrapping ctx.Err() with the last dial error is returned as soon
+// as ctx is done.
+func WaitForPort(ctx context.Context, address string, interval time.Duration) error {
+	dialer := net.Dialer{}
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	var lastErr error
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for port %v: %w, last error: %v", address, ctx.Err(), lastErr)
+		case <-timer.C:
+		}
+		conn, err := dialer.DialContext(ctx, "tcp", address)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+		lastErr = err
+		timer.Reset(interval)
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright 2023 jim.zoumo@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netutil
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// freeAddress returns a local TCP address which is not listened
+func freeAddress(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	return addr
+}
+
+func TestWaitForPort(t *testing.T) {
+	addr := freeAddress(t)
+
+	lnC := make(chan net.Listener, 1)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			t.Error(err)
+		}
+		lnC <- ln
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	err := WaitForPort(ctx, addr, 10*time.Millisecond)
+	if err != nil {
+		t.Errorf("WaitForPort() error = %v", err)
+	}
+	if d := time.Since(start); d < 100*time.Millisecond || d > time.Second {
+		t.Errorf("WaitForPort() should return once the port is up, took %v", d)
+	}
+	if ln := <-lnC; ln != nil {
+		ln.Close()
+	}
+}
+
+func TestWaitForPort_Timeout(t *testing.T) {
+	addr := freeAddress(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := WaitForPort(ctx, addr, 10*time.Millisecond)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("WaitForPort() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestWaitForPort_CancelDuringInterval(t *testing.T) {
+	addr := freeAddress(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	err := WaitForPort(ctx, addr, time.Hour)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("WaitForPort() error = %v, want %v", err, context.Canceled)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("WaitForPort() should return once ctx is cancelled, took %v", d)
+	}
+}
+
+func TestFreePort(t *testing.T) {
+	port, err := FreePort()
+	if err != nil {
+		t.Fatalf("FreePort() error = %v", err)
+	}
+	ln, err := net.Listen("tcp", ":"+strconv.Itoa(port))
+	if err != nil {
+		t.Errorf("FreePort() = %v is not bindable: %v", port, err)
+	} else {
+		ln.Close()
+	}
+
+	port, err = FreePortOn("127.0.0.1")
+	if err != nil {
+		t.Fatalf("FreePortOn() error = %v", err)
+	}
+	ln, err = net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	if err != nil {
+		t.Errorf("FreePortOn() = %v is not bindable: %v", port, err)
+	} else {
+		ln.Close()
+	}
+
+	if _, err := FreePortOn("not a host"); err == nil {
+		t.Errorf("FreePortOn() with invalid host should fail")
+	}
+}
+
+func TestFreePorts(t *testing.T) {
+	ports, err := FreePorts(10)
+	if err != nil {
+		t.Fatalf("FreePorts() error = %v", err)
+	}
+	if len(ports) != 10 {
+		t.Fatalf("FreePorts() got %v ports, want 10", len(ports))
+	}
+	seen := map[int]bool{}
+	for _, port := range ports {
+		if seen[port] {
+			t.Errorf("FreePorts() returned duplicated port %v", port)
+		}
+		seen[port] = true
+		ln, err := net.Listen("tcp", ":"+strconv.Itoa(port))
+		if err != nil {
+			t.Errorf("FreePorts() port %v is not bindable: %v", port, err)
+			continue
+		}
+		ln.Close()
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2023 jim.zoumo@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netutil
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// recordTypeHandshake is the first byte of a TLS ClientHello record
+	recordTypeHandshake = 0x16
+)
+
+// tlsDetectTimeout is the max time to wait for the first byte of a
+// connection. If the client sends nothing in time, e.g. the server speaks
+// first, the connection is treated as plaintext.
+var tlsDetectTimeout = 3 * time.Second
+
+// peekConn is a net.Conn which can peek the incoming bytes without
+// consuming them.
+type peekConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func newPeekConn(conn net.Conn) *peekConn {
+	return &peekConn{
+		Conn: conn,
+		r:    bufio.NewReader(conn),
+	}
+}
+
+// Peek returns the next n bytes without advancing the reader.
+func (c *peekConn) Peek(n int) ([]byte, error) {
+	return c.r.Peek(n)
+}
+
+func (c *peekConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+type tlsDetectListener struct {
+	net.Listener
+	config *tls.Config
+
+	acceptC chan net.Conn
+	// err is the error returned by the inner listener, it is set before
+	// closeC is closed
+	err       error
+	closeOnce sync.Once
+	closeC    chan struct{}
+}
+
+// NewTLSDetectListener returns a listener which accepts both plaintext and
+// TLS connections. It peeks the first byte of each connection, if it looks
+// like a TLS ClientHello, the connection is wrapped by tls.Server with the
+// given config, otherwise it is returned as it is.
+//
+// The detection is done on background, so a slow client does not block
+// accepting others.
+func NewTLSDetectListener(l net.Listener, config *tls.Config) net.Listener {
+	tl := &tlsDetectListener{
+		Listener: l,
+		config:   config,
+		acceptC:  make(chan net.Conn),
+		closeC:   make(chan struct{}),
+	}
+	go tl.acceptBackground()
+	return tl
+}
+
+func (l *tlsDetectListener) acceptBackground() {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			l.closeWithError(err)
+			return
+		}
+		go l.detect(conn)
+	}
+}
+
+func (l *tlsDetectListener) detect(conn net.Conn) {
+	pc := newPeekConn(conn)
+	conn.SetReadDeadline(time.Now().Add(tlsDetectTimeout)) //nolint
+	first, err := pc.Peek(1)
+	conn.SetReadDeadline(time.Time{}) //nolint
+
+	var ret net.Conn = pc
+	var netErr net.Error
+	switch {
+	case err == nil && first[0] == recordTypeHandshake:
+		ret = tls.Server(pc, l.config)
+	case err == nil, errors.As(err, &netErr) && netErr.Timeout():
+		// plaintext or the client is waiting for server
+	default:
+		conn.Close()
+		return
+	}
+
+	select {
+	case l.acceptC <- ret:
+	case <-l.closeC:
+		ret.Close()
+	}
+}
+
+func (l *tlsDetectListener) closeWithError(err error) {
+	l.closeOnce.Do(func() {
+		l.err = err
+		close(l.closeC)
+	})
+}
+
+// Accept waits for and returns the next connection, it is a *tls.Conn if
+// the client starts a TLS handshake.
+func (l *tlsDetectListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.acceptC:
+		return conn, nil
+	case <-l.closeC:
+		return nil, l.err
+	}
+}
+
+// Close closes the inner listener, the connections being detected are
+// closed.
+func (l *tlsDetectListener) Close() error {
+	err := l.Listener.Close()
+	l.closeWithError(ErrAccecptClosed)
+	return err
+}
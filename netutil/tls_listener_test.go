@@ -0,0 +1,173 @@
+// Copyright 2023 jim.zoumo@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netutil
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/zoumo/golib/cert"
+)
+
+func newTestTLSConfig(t *testing.T) (*tls.Config, *x509.CertPool) {
+	key, err := cert.NewECPrivateKey(cert.CurveP256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crt, err := cert.NewSelfSignedCert(cert.Config{
+		CommonName: "localhost",
+		AltNames: cert.AltNames{
+			IPs: []net.IP{net.ParseIP("127.0.0.1")},
+		},
+		Usages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(crt)
+	return &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{crt.Raw}, PrivateKey: key}},
+	}, pool
+}
+
+// echoLine reads a line from conn and writes it back
+func echoLine(conn net.Conn) {
+	defer conn.Close()
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return
+	}
+	conn.Write([]byte(line)) //nolint
+}
+
+func TestTLSDetectListener(t *testing.T) {
+	config, pool := newTestTLSConfig(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := NewTLSDetectListener(ln, config)
+	defer l.Close()
+
+	isTLS := make(chan bool, 2)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			_, ok := conn.(*tls.Conn)
+			isTLS <- ok
+			go echoLine(conn)
+		}
+	}()
+
+	tests := []struct {
+		name    string
+		dial    func() (net.Conn, error)
+		wantTLS bool
+	}{
+		{
+			name: "plaintext",
+			dial: func() (net.Conn, error) {
+				return net.Dial("tcp", ln.Addr().String())
+			},
+		},
+		{
+			name: "tls",
+			dial: func() (net.Conn, error) {
+				return tls.Dial("tcp", ln.Addr().String(), &tls.Config{RootCAs: pool})
+			},
+			wantTLS: true,
+		},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := tt.dial()
+			if err != nil {
+				t.Fatalf("dial error = %v", err)
+			}
+			defer conn.Close()
+			conn.SetDeadline(time.Now().Add(5 * time.Second)) //nolint
+
+			if _, err := conn.Write([]byte("hello\n")); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			got, err := bufio.NewReader(conn).ReadString('\n')
+			if err != nil {
+				t.Fatalf("ReadString() error = %v", err)
+			}
+			if got != "hello\n" {
+				t.Errorf("got echo %q, want %q", got, "hello\n")
+			}
+			if ok := <-isTLS; ok != tt.wantTLS {
+				t.Errorf("accepted tls conn = %v, want %v", ok, tt.wantTLS)
+			}
+		})
+	}
+}
+
+func TestTLSDetectListener_ServerFirst(t *testing.T) {
+	old := tlsDetectTimeout
+	tlsDetectTimeout = 50 * time.Millisecond
+	defer func() { tlsDetectTimeout = old }()
+
+	config, _ := newTestTLSConfig(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := NewTLSDetectListener(ln, config)
+	defer l.Close()
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatalf("Accept() error = %v", err)
+	}
+	defer conn.Close()
+	if _, ok := conn.(*tls.Conn); ok {
+		t.Fatalf("silent client should be treated as plaintext")
+	}
+	conn.Write([]byte("greeting\n"))                        //nolint
+	client.SetReadDeadline(time.Now().Add(5 * time.Second)) //nolint
+	got, err := bufio.NewReader(client).ReadString('\n')
+	if err != nil || got != "greeting\n" {
+		t.Errorf("client got %q, %v, want %q", got, err, "greeting\n")
+	}
+}
+
+func TestTLSDetectListener_Close(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := NewTLSDetectListener(ln, &tls.Config{})
+	l.Close()
+	if _, err := l.Accept(); err == nil {
+		t.Errorf("Accept() should fail after Close")
+	}
+}
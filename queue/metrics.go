@@ -0,0 +1,42 @@
+// Copyright 2023 jim.zoumo@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queue
+
+import "time"
+
+// Metrics receives the processing events of a Queue.
+// The methods may be called concurrently by workers.
+type Metrics interface {
+	// IncProcessed is called after the Handler processed an object
+	IncProcessed()
+	// IncRequeued is called when an object is requeued because of an error
+	// or the HandleResult
+	IncRequeued()
+	// IncDropped is called when an object is given up after exceeding the
+	// max error retries, or its key can not be got
+	IncDropped()
+	// ObserveLatency is called with the time the Handler took to process
+	// an object
+	ObserveLatency(time.Duration)
+}
+
+var _ Metrics = noopMetrics{}
+
+type noopMetrics struct{}
+
+func (noopMetrics) IncProcessed()                {}
+func (noopMetrics) IncRequeued()                 {}
+func (noopMetrics) IncDropped()                  {}
+func (noopMetrics) ObserveLatency(time.Duration) {}
@@ -15,9 +15,13 @@
 package queue
 
 import (
+	"context"
+	"fmt"
+	"runtime/debug"
 	"sync"
 	"time"
 
+	"github.com/go-logr/logr"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/util/workqueue"
 )
@@ -44,6 +48,24 @@ type HandleResult struct {
 
 type Handler func(obj interface{}) (HandleResult, error)
 
+// HandlerContext is like Handler but receives a context which is cancelled
+// when the Queue is shut down.
+type HandlerContext func(ctx context.Context, obj interface{}) (HandleResult, error)
+
+// PanicHandler is called with the object and the recovered value when the
+// handler panics.
+type PanicHandler func(obj interface{}, recovered interface{})
+
+// KeyFunc returns the key of an object, objects with the same key are
+// deduplicated in the Queue.
+type KeyFunc func(obj interface{}) (string, error)
+
+// keyedObject holds the latest enqueued object of a key, the pointer is used
+// to tell whether the object has been replaced by a later Enqueue.
+type keyedObject struct {
+	obj interface{}
+}
+
 // Queue is a wrapper of kubernetes workqueue to do asynchronous work easily.
 // It requires a Handler and an optional key function.
 // After starting the Queue, you can call the Enqueque function to enqueue items.
@@ -51,7 +73,20 @@ type Handler func(obj interface{}) (HandleResult, error)
 // The worker will be invoked to call the Handler.
 type Queue struct {
 	// handler is called for each item in the queue
-	handler Handler
+	handler HandlerContext
+
+	// ctx is passed to the handler, it is cancelled by ShutDown
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// keyFunc gets the key of an enqueued object. If it is not nil, the key
+	// instead of the object is added to the work queue, and resolved back to
+	// the latest enqueued object before calling the handler.
+	keyFunc KeyFunc
+	// objMu guards objects
+	objMu sync.Mutex
+	// objects maps keys to the latest enqueued objects
+	objects map[string]*keyedObject
 
 	// queue is the work queue the worker polls
 	queue            workqueue.RateLimitingInterface
@@ -59,23 +94,77 @@ type Queue struct {
 
 	waitGroup sync.WaitGroup
 
+	// mu guards outstanding, active and idleCh
+	mu sync.Mutex
+	// outstanding holds the items from being added to the queue until
+	// they are finally forgotten or dropped
+	outstanding map[interface{}]struct{}
+	// active holds the items being processed by workers, the value is
+	// true if the item is added again during processing
+	active map[interface{}]bool
+	// idleCh is closed when outstanding becomes empty, it is lazily
+	// created by WaitForEmpty
+	idleCh chan struct{}
+
 	maxErrRetries int
 
+	logger logr.Logger
+
+	panicHandler PanicHandler
+
+	metrics Metrics
+
 	stopCh chan struct{}
+	// shutdownOnce makes ShutDown safe to be called more than once
+	shutdownOnce sync.Once
 }
 
 // NewQueue returns a new Queue
 func NewQueue(handler Handler) *Queue {
-	rateLimiter := workqueue.DefaultControllerRateLimiter()
+	return newQueue(func(_ context.Context, obj interface{}) (HandleResult, error) {
+		return handler(obj)
+	}, workqueue.DefaultControllerRateLimiter())
+}
+
+// NewQueueWithRateLimiter returns a new Queue using the given rate limiter,
+// which is used by the work queue for rate limited requeues and also records
+// the requeues asked by HandleResult.
+func NewQueueWithRateLimiter(handler Handler, rateLimiter workqueue.RateLimiter) *Queue {
+	return NewQueue(handler).SetRateLimiter(rateLimiter)
+}
+
+// NewQueueContext returns a new Queue with a HandlerContext. The context
+// passed to the handler is cancelled when ShutDown is called, so that
+// long-running handlers can return early.
+func NewQueueContext(handler HandlerContext) *Queue {
+	return newQueue(handler, workqueue.DefaultControllerRateLimiter())
+}
+
+func newQueue(handler HandlerContext, rateLimiter workqueue.RateLimiter) *Queue {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &Queue{
 		queue:            workqueue.NewRateLimitingQueue(rateLimiter),
 		queueRateLimiter: rateLimiter,
 		handler:          handler,
+		ctx:              ctx,
+		cancel:           cancel,
 		waitGroup:        sync.WaitGroup{},
+		logger:           logr.Discard(),
+		metrics:          noopMetrics{},
+		outstanding:      map[interface{}]struct{}{},
+		active:           map[interface{}]bool{},
 		stopCh:           make(chan struct{}),
 	}
 }
 
+// NewQueueWithKeyFunc returns a new Queue which gets keys from the enqueued
+// objects by keyFunc. Objects with the same key are deduplicated while they
+// are waiting in the queue, and the handler receives the latest one.
+// Objects that keyFunc fails on are dropped and logged.
+func NewQueueWithKeyFunc(handler Handler, keyFunc KeyFunc) *Queue {
+	return NewQueue(handler).SetKeyFunc(keyFunc)
+}
+
 // Run starts n workers to sync
 func (q *Queue) Run(workers int) {
 	for i := 0; i < workers; i++ {
@@ -83,6 +172,46 @@ func (q *Queue) Run(workers int) {
 	}
 }
 
+// RunContext starts n workers to sync, the Queue is shut down when the
+// context is done. It is fine to call ShutDown before the context is done.
+func (q *Queue) RunContext(ctx context.Context, workers int) {
+	q.Run(workers)
+	go func() {
+		select {
+		case <-ctx.Done():
+			q.ShutDown()
+		case <-q.stopCh:
+		}
+	}()
+}
+
+// SetRateLimiter replaces the work queue with a new one using the given rate
+// limiter, which is used for rate limited requeues and also records the
+// requeues asked by HandleResult. It must be called before the Queue is run
+// and any object is enqueued.
+func (q *Queue) SetRateLimiter(rateLimiter workqueue.RateLimiter) *Queue {
+	if rateLimiter != nil {
+		q.queue.ShutDown()
+		q.queue = workqueue.NewRateLimitingQueue(rateLimiter)
+		q.queueRateLimiter = rateLimiter
+	}
+	return q
+}
+
+// SetKeyFunc sets the function to get keys from the enqueued objects.
+// Objects with the same key are deduplicated while they are waiting in the
+// queue, and the handler receives the latest one. Objects that keyFunc fails
+// on are dropped and logged. It must be called before the Queue is run and
+// any object is enqueued.
+func (q *Queue) SetKeyFunc(keyFunc KeyFunc) *Queue {
+	q.keyFunc = keyFunc
+	q.objects = nil
+	if keyFunc != nil {
+		q.objects = map[string]*keyedObject{}
+	}
+	return q
+}
+
 // SetMaxRetries sets the max retry times of the queue
 func (q *Queue) SetMaxErrRetries(max int) *Queue {
 	if max >= -1 {
@@ -92,18 +221,71 @@ func (q *Queue) SetMaxErrRetries(max int) *Queue {
 	return q
 }
 
+// SetLogger sets the logger used to report recovered panics in Handler
+func (q *Queue) SetLogger(logger logr.Logger) *Queue {
+	if logger != nil {
+		q.logger = logger
+	}
+	return q
+}
+
+// SetPanicHandler sets the PanicHandler called when Handler panics.
+// The panic is recovered and the object is requeued like an error
+// whether or not the PanicHandler is set.
+func (q *Queue) SetPanicHandler(panicHandler PanicHandler) *Queue {
+	q.panicHandler = panicHandler
+	return q
+}
+
+// SetMetrics sets the Metrics to observe the processing of items
+func (q *Queue) SetMetrics(metrics Metrics) *Queue {
+	if metrics != nil {
+		q.metrics = metrics
+	}
+	return q
+}
+
 // Len returns the unprocessed item length
 func (q *Queue) Len() int {
 	return q.queue.Len()
 }
 
-// ShutDown shuts down the work queue and waits for the worker to ACK
-func (q *Queue) ShutDown() {
-	close(q.stopCh)
+// WaitForEmpty blocks until every enqueued item has been processed without
+// being requeued, or the context is done. Items waiting to be added by
+// EnqueueAfter or requeues are counted as well.
+func (q *Queue) WaitForEmpty(ctx context.Context) error {
+	for {
+		q.mu.Lock()
+		if len(q.outstanding) == 0 {
+			q.mu.Unlock()
+			return nil
+		}
+		if q.idleCh == nil {
+			q.idleCh = make(chan struct{})
+		}
+		idleCh := q.idleCh
+		q.mu.Unlock()
+
+		select {
+		case <-idleCh:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
 
-	// q shutdown the queue, then worker can't get key from queue
-	// processNextWorkItem return false, and then waitGroup -1
-	q.queue.ShutDown()
+// ShutDown shuts down the work queue and waits for the worker to ACK.
+// The context passed to HandlerContext is cancelled.
+// It is safe to call ShutDown more than once.
+func (q *Queue) ShutDown() {
+	q.shutdownOnce.Do(func() {
+		close(q.stopCh)
+		q.cancel()
+
+		// q shutdown the queue, then worker can't get key from queue
+		// processNextWorkItem return false, and then waitGroup -1
+		q.queue.ShutDown()
+	})
 	q.waitGroup.Wait()
 }
 
@@ -112,7 +294,8 @@ func (q *Queue) IsShuttingDown() bool {
 	return q.queue.ShuttingDown()
 }
 
-// Queue returns the rate limit work queue
+// Queue returns the rate limit work queue.
+// The items in it are keys if the Queue is created with a key function.
 func (q *Queue) Queue() workqueue.RateLimitingInterface {
 	return q.queue
 }
@@ -122,7 +305,12 @@ func (q *Queue) Enqueue(obj interface{}) {
 	if q.IsShuttingDown() {
 		return
 	}
-	q.queue.Add(obj)
+	item, ok := q.itemOf(obj)
+	if !ok {
+		return
+	}
+	q.track(item)
+	q.queue.Add(item)
 }
 
 // EnqueueRateLimited wraps queue.AddRateLimited. It adds an item to the workqueue
@@ -131,7 +319,12 @@ func (q *Queue) EnqueueRateLimited(obj interface{}) {
 	if q.IsShuttingDown() {
 		return
 	}
-	q.queue.AddRateLimited(obj)
+	item, ok := q.itemOf(obj)
+	if !ok {
+		return
+	}
+	q.track(item)
+	q.queue.AddRateLimited(item)
 }
 
 // EnqueueAfter wraps queue.AddAfter. It adds an item to the workqueue after the indicated duration has passed
@@ -139,7 +332,88 @@ func (q *Queue) EnqueueAfter(obj interface{}, after time.Duration) {
 	if q.IsShuttingDown() {
 		return
 	}
-	q.queue.AddAfter(obj, after)
+	item, ok := q.itemOf(obj)
+	if !ok {
+		return
+	}
+	q.track(item)
+	q.queue.AddAfter(item, after)
+}
+
+// itemOf returns the item added to the work queue for obj. If keyFunc is set,
+// it stores obj by its key and returns the key.
+func (q *Queue) itemOf(obj interface{}) (interface{}, bool) {
+	if q.keyFunc == nil {
+		return obj, true
+	}
+	key, err := q.keyFunc(obj)
+	if err != nil {
+		q.logger.Error(err, "failed to get key of object, drop it", "obj", obj)
+		q.metrics.IncDropped()
+		return nil, false
+	}
+	q.objMu.Lock()
+	defer q.objMu.Unlock()
+	q.objects[key] = &keyedObject{obj: obj}
+	return key, true
+}
+
+// objectOf resolves the item got from the work queue to the object. It returns
+// false if the object of the key has been processed and forgotten.
+func (q *Queue) objectOf(item interface{}) (interface{}, *keyedObject, bool) {
+	if q.keyFunc == nil {
+		return item, nil, true
+	}
+	q.objMu.Lock()
+	defer q.objMu.Unlock()
+	ko, ok := q.objects[item.(string)]
+	if !ok {
+		return nil, nil, false
+	}
+	return ko.obj, ko, true
+}
+
+// track counts the item as outstanding until it is finally forgotten
+func (q *Queue) track(item interface{}) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.outstanding[item] = struct{}{}
+	if _, ok := q.active[item]; ok {
+		// the work queue adds it back after processing
+		q.active[item] = true
+	}
+}
+
+// untrack stops counting the item as outstanding unless it has been added
+// again during processing.
+func (q *Queue) untrack(item interface{}) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.active[item] {
+		return
+	}
+	delete(q.outstanding, item)
+	if len(q.outstanding) == 0 && q.idleCh != nil {
+		// wake up all waiters
+		close(q.idleCh)
+		q.idleCh = nil
+	}
+}
+
+// forget forgets the item in the work queue, and removes the object of the
+// key unless it has been replaced by a later Enqueue.
+func (q *Queue) forget(item interface{}, ko *keyedObject) {
+	q.queue.Forget(item)
+	q.untrack(item)
+	if ko == nil {
+		return
+	}
+	q.objMu.Lock()
+	defer q.objMu.Unlock()
+	key := item.(string)
+	if q.objects[key] == ko {
+		delete(q.objects, key)
+	}
 }
 
 // Worker is a common worker for controllers
@@ -155,10 +429,19 @@ func (q *Queue) worker() {
 
 // ProcessNextWorkItem processes next item in queue by Handler
 func (q *Queue) processNextWorkItem() bool {
-	obj, quit := q.queue.Get()
+	item, quit := q.queue.Get()
 	if quit {
 		return false
 	}
+	return q.processItem(item)
+}
+
+// processItem processes the item got from the work queue
+func (q *Queue) processItem(item interface{}) bool {
+	q.startProcessing(item)
+	// finishProcessing must be called after Done, because Done may add
+	// the obj back to the queue if it was enqueued during processing.
+	defer q.finishProcessing(item)
 
 	// We call Done here so the workqueue knows we have finished
 	// processing this item. We also must remember to call Forget if we
@@ -166,35 +449,84 @@ func (q *Queue) processNextWorkItem() bool {
 	// not call Forget if a transient error occurs, instead the item is
 	// put back on the workqueue and attempted again after a back-off
 	// period.
-	defer q.queue.Done(obj)
+	defer q.queue.Done(item)
 
-	return q.handle(obj)
+	return q.handle(item)
 }
 
-func (q *Queue) handle(obj interface{}) bool {
-	result, err := q.handler(obj)
+func (q *Queue) startProcessing(item interface{}) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.active[item] = false
+}
+
+func (q *Queue) finishProcessing(item interface{}) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.active, item)
+}
+
+func (q *Queue) handle(item interface{}) bool {
+	obj, ko, ok := q.objectOf(item)
+	if !ok {
+		// the latest object of the key has been processed
+		q.queue.Forget(item)
+		q.untrack(item)
+		return true
+	}
+
+	start := time.Now()
+	result, panicked, err := q.callHandler(obj)
+	q.metrics.ObserveLatency(time.Since(start))
+	q.metrics.IncProcessed()
+	if panicked {
+		// the worker keeps running after a recovered panic, the obj is
+		// requeued according to max retries like an error.
+		q.handleError(item, ko, err)
+		return true
+	}
 	if err != nil {
-		q.handleError(obj, err)
+		q.handleError(item, ko, err)
 		return false
 	}
 
-	q.handleRequeue(obj, result)
+	q.handleRequeue(item, ko, result)
 	return true
 }
 
-func (q *Queue) handleError(obj interface{}, err error) {
+// callHandler calls the handler with obj and recovers from its panic.
+// A recovered panic is converted to an error with the stack captured.
+func (q *Queue) callHandler(obj interface{}) (result HandleResult, panicked bool, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			stack := debug.Stack()
+			err = fmt.Errorf("queue: handler panic: %v\n%s", r, stack)
+			panicked = true
+			q.logger.Error(err, "recovered from handler panic", "obj", obj)
+			if q.panicHandler != nil {
+				q.panicHandler(obj, r)
+			}
+		}
+	}()
+	result, err = q.handler(q.ctx, obj)
+	return result, false, err
+}
+
+func (q *Queue) handleError(item interface{}, ko *keyedObject, err error) {
 	if err == nil {
 		return
 	}
 	if q.maxErrRetries == ErrRetryForever ||
-		(q.maxErrRetries != ErrRetryNone && q.queue.NumRequeues(obj) < q.maxErrRetries) {
-		q.queue.AddRateLimited(obj)
+		(q.maxErrRetries != ErrRetryNone && q.queue.NumRequeues(item) < q.maxErrRetries) {
+		q.queue.AddRateLimited(item)
+		q.metrics.IncRequeued()
 		return
 	}
-	q.queue.Forget(obj)
+	q.forget(item, ko)
+	q.metrics.IncDropped()
 }
 
-func (q *Queue) handleRequeue(obj interface{}, result HandleResult) {
+func (q *Queue) handleRequeue(item interface{}, ko *keyedObject, result HandleResult) {
 	var requeueAfter time.Duration
 
 	if result.MaxRequeueTimes == 0 {
@@ -211,23 +543,29 @@ func (q *Queue) handleRequeue(obj interface{}, result HandleResult) {
 		requeueAfter = time.Microsecond
 	}
 
-	if result.MaxRequeueTimes > 0 && q.queue.NumRequeues(obj) >= result.MaxRequeueTimes {
+	if result.MaxRequeueTimes > 0 && q.queue.NumRequeues(item) >= result.MaxRequeueTimes {
 		// more than maximum requeue times
 		// skip requeue
 		requeueAfter = 0
 	}
 
 	if requeueAfter > 0 {
+		if q.IsShuttingDown() {
+			q.untrack(item)
+			return
+		}
+		// requeue the item directly, it is the key if keyFunc is set
 		if result.RequeueRateLimited {
-			q.EnqueueRateLimited(obj)
+			q.queue.AddRateLimited(item)
 		} else {
-			// EnqueueAfter does not record object requeues times, we need to
+			// AddAfter does not record object requeues times, we need to
 			// call rateLimiter.When to add 1 time explicitly.
-			q.queueRateLimiter.When(obj)
-			q.EnqueueAfter(obj, requeueAfter)
+			q.queueRateLimiter.When(item)
+			q.queue.AddAfter(item, requeueAfter)
 		}
+		q.metrics.IncRequeued()
 		return
 	}
 	// we should forget this obj if there is no need to requeue this obj
-	q.queue.Forget(obj)
+	q.forget(item, ko)
 }
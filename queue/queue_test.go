@@ -0,0 +1,635 @@
+// Copyright 2023 jim.zoumo@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queue
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/workqueue"
+)
+
+func TestQueue_HandlerPanic(t *testing.T) {
+	mu := sync.Mutex{}
+	calls := map[string]int{}
+	done := make(chan string, 10)
+
+	q := NewQueue(func(obj interface{}) (HandleResult, error) {
+		key := obj.(string)
+		mu.Lock()
+		calls[key]++
+		count := calls[key]
+		mu.Unlock()
+		if key == "panic" && count == 1 {
+			panic("panic on the first attempt")
+		}
+		done <- key
+		return HandleResult{}, nil
+	}).SetMaxErrRetries(3)
+	q.Run(1)
+	defer q.ShutDown()
+
+	q.Enqueue("panic")
+	q.Enqueue("normal")
+
+	got := map[string]bool{}
+	timeout := time.After(5 * time.Second)
+	for len(got) < 2 {
+		select {
+		case key := <-done:
+			got[key] = true
+		case <-timeout:
+			t.Fatalf("timeout waiting for items to be processed, got %v", got)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if calls["panic"] != 2 {
+		t.Errorf("handler should be called twice for panic item, got %v", calls["panic"])
+	}
+	if calls["normal"] != 1 {
+		t.Errorf("handler should be called once for normal item, got %v", calls["normal"])
+	}
+}
+
+func TestQueue_HandlerError(t *testing.T) {
+	handlerErr := errors.New("handler error")
+	tests := []struct {
+		name    string
+		handler Handler
+		want    bool
+	}{
+		// the worker is backed off by wait.Until after an error
+		{"error", func(obj interface{}) (HandleResult, error) {
+			return HandleResult{}, handlerErr
+		}, false},
+		// the worker keeps running after a recovered panic
+		{"panic", func(obj interface{}) (HandleResult, error) {
+			panic(handlerErr)
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := NewQueue(tt.handler)
+			defer q.ShutDown()
+
+			q.Enqueue("item")
+			if got := q.processNextWorkItem(); got != tt.want {
+				t.Errorf("processNextWorkItem() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueue_SetPanicHandler(t *testing.T) {
+	calls := 0
+	done := make(chan struct{})
+	panics := make(chan interface{}, 10)
+
+	q := NewQueue(func(obj interface{}) (HandleResult, error) {
+		calls++
+		if calls == 1 {
+			panic("panic on the first attempt")
+		}
+		close(done)
+		return HandleResult{}, nil
+	}).SetMaxErrRetries(3).SetPanicHandler(func(obj interface{}, recovered interface{}) {
+		panics <- []interface{}{obj, recovered}
+	})
+	q.Run(1)
+	defer q.ShutDown()
+
+	q.Enqueue("obj")
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting for obj to be retried after panic")
+	}
+
+	if len(panics) != 1 {
+		t.Fatalf("PanicHandler is called %v times, want 1", len(panics))
+	}
+	want := []interface{}{"obj", "panic on the first attempt"}
+	if got := <-panics; !reflect.DeepEqual(got, want) {
+		t.Errorf("PanicHandler got %v, want %v", got, want)
+	}
+}
+
+func TestQueue_callHandler(t *testing.T) {
+	q := NewQueue(func(obj interface{}) (HandleResult, error) {
+		panic("boom")
+	})
+	_, panicked, err := q.callHandler("obj")
+	if !panicked {
+		t.Fatalf("callHandler() should recover from panic")
+	}
+	if err == nil {
+		t.Fatalf("callHandler() should return an error")
+	}
+	if msg := err.Error(); !strings.Contains(msg, "boom") || !strings.Contains(msg, "goroutine") {
+		t.Errorf("callHandler() error should contain panic value and stack, got %v", msg)
+	}
+}
+
+func TestQueue_WaitForEmpty(t *testing.T) {
+	mu := sync.Mutex{}
+	processed := 0
+
+	q := NewQueue(func(obj interface{}) (HandleResult, error) {
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		processed++
+		mu.Unlock()
+		return HandleResult{}, nil
+	})
+	defer q.ShutDown()
+
+	// returns immediately if the queue is already empty
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := q.WaitForEmpty(ctx); err != nil {
+		t.Fatalf("WaitForEmpty() on empty queue error = %v", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		q.Enqueue(i)
+	}
+
+	// times out if there is no worker to process items
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := q.WaitForEmpty(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("WaitForEmpty() without workers error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	q.Run(2)
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := q.WaitForEmpty(ctx); err != nil {
+		t.Fatalf("WaitForEmpty() error = %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if processed != 10 {
+		t.Errorf("WaitForEmpty() returned after %v items processed, want 10", processed)
+	}
+}
+
+func TestQueue_WaitForEmpty_gotNotProcessing(t *testing.T) {
+	q := NewQueue(func(obj interface{}) (HandleResult, error) {
+		return HandleResult{}, nil
+	})
+	defer q.ShutDown()
+
+	q.Enqueue(1)
+	// the item is got by a worker but not processed yet
+	item, _ := q.queue.Get()
+	if q.Len() != 0 {
+		t.Fatalf("Len() = %v, want 0", q.Len())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := q.WaitForEmpty(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("WaitForEmpty() with an item got error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	go q.processItem(item)
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := q.WaitForEmpty(ctx); err != nil {
+		t.Fatalf("WaitForEmpty() error = %v", err)
+	}
+}
+
+func TestQueue_WaitForEmpty_enqueueDuringProcessing(t *testing.T) {
+	mu := sync.Mutex{}
+	processed := 0
+
+	var q *Queue
+	q = NewQueue(func(obj interface{}) (HandleResult, error) {
+		mu.Lock()
+		processed++
+		first := processed == 1
+		mu.Unlock()
+		if first {
+			// the work queue adds it back after processing
+			q.Enqueue(obj)
+			time.Sleep(10 * time.Millisecond)
+		}
+		return HandleResult{}, nil
+	})
+	defer q.ShutDown()
+
+	q.Enqueue(1)
+	q.Run(1)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := q.WaitForEmpty(ctx); err != nil {
+		t.Fatalf("WaitForEmpty() error = %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if processed != 2 {
+		t.Errorf("WaitForEmpty() returned after %v processings, want 2", processed)
+	}
+}
+
+// recordingRateLimiter records the calls of When and Forget
+type recordingRateLimiter struct {
+	workqueue.RateLimiter
+
+	mu      sync.Mutex
+	whens   int
+	forgets int
+}
+
+func (r *recordingRateLimiter) When(item interface{}) time.Duration {
+	r.mu.Lock()
+	r.whens++
+	r.mu.Unlock()
+	return r.RateLimiter.When(item)
+}
+
+func (r *recordingRateLimiter) Forget(item interface{}) {
+	r.mu.Lock()
+	r.forgets++
+	r.mu.Unlock()
+	r.RateLimiter.Forget(item)
+}
+
+func TestNewQueueWithRateLimiter(t *testing.T) {
+	rl := &recordingRateLimiter{
+		RateLimiter: workqueue.NewItemExponentialFailureRateLimiter(time.Millisecond, 10*time.Millisecond),
+	}
+	calls := 0
+	done := make(chan struct{})
+	q := NewQueueWithRateLimiter(func(obj interface{}) (HandleResult, error) {
+		calls++
+		switch calls {
+		case 1:
+			// requeued by RequeueAfter, rateLimiter.When is called manually
+			return HandleResult{RequeueAfter: time.Millisecond}, nil
+		case 2:
+			// requeued by AddRateLimited
+			return HandleResult{}, errors.New("failed")
+		default:
+			close(done)
+			return HandleResult{}, nil
+		}
+	}, rl).SetMaxErrRetries(3)
+	q.Run(1)
+	defer q.ShutDown()
+
+	q.Enqueue("obj")
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting for obj to be processed")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := q.WaitForEmpty(ctx); err != nil {
+		t.Fatalf("WaitForEmpty() error = %v", err)
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if rl.whens != 2 {
+		t.Errorf("RateLimiter.When() is called %v times, want 2", rl.whens)
+	}
+	if rl.forgets != 1 {
+		t.Errorf("RateLimiter.Forget() is called %v times, want 1", rl.forgets)
+	}
+}
+
+type keyedItem struct {
+	name  string
+	value int
+}
+
+func TestNewQueueWithKeyFunc(t *testing.T) {
+	mu := sync.Mutex{}
+	handled := []keyedItem{}
+
+	q := NewQueueWithKeyFunc(func(obj interface{}) (HandleResult, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		handled = append(handled, obj.(keyedItem))
+		return HandleResult{}, nil
+	}, func(obj interface{}) (string, error) {
+		item, ok := obj.(keyedItem)
+		if !ok {
+			return "", errors.New("unknown object")
+		}
+		return item.name, nil
+	})
+	defer q.ShutDown()
+
+	// objects with the same key are deduplicated before processing
+	q.Enqueue(keyedItem{name: "a", value: 1})
+	q.Enqueue(keyedItem{name: "a", value: 2})
+	q.Enqueue(keyedItem{name: "b", value: 1})
+	// objects which keyFunc fails on are dropped
+	q.Enqueue("unknown")
+	if q.Len() != 2 {
+		t.Fatalf("Len() = %v, want 2", q.Len())
+	}
+
+	q.Run(1)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := q.WaitForEmpty(ctx); err != nil {
+		t.Fatalf("WaitForEmpty() error = %v", err)
+	}
+
+	mu.Lock()
+	want := []keyedItem{{name: "a", value: 2}, {name: "b", value: 1}}
+	if !reflect.DeepEqual(handled, want) {
+		t.Errorf("handler got %v, want %v", handled, want)
+	}
+	mu.Unlock()
+
+	q.objMu.Lock()
+	if len(q.objects) != 0 {
+		t.Errorf("objects should be removed after processed, got %v", q.objects)
+	}
+	q.objMu.Unlock()
+}
+
+func TestNewQueueWithKeyFunc_Requeue(t *testing.T) {
+	calls := 0
+	done := make(chan interface{}, 1)
+	q := NewQueueWithKeyFunc(func(obj interface{}) (HandleResult, error) {
+		calls++
+		if calls == 1 {
+			return HandleResult{RequeueAfter: time.Millisecond}, nil
+		}
+		done <- obj
+		return HandleResult{}, nil
+	}, func(obj interface{}) (string, error) {
+		return obj.(keyedItem).name, nil
+	})
+	q.Run(1)
+	defer q.ShutDown()
+
+	obj := keyedItem{name: "a", value: 1}
+	q.Enqueue(obj)
+	select {
+	case got := <-done:
+		if got != obj {
+			t.Errorf("requeued object = %v, want %v", got, obj)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting for requeued object to be processed")
+	}
+}
+
+type fakeMetrics struct {
+	mu        sync.Mutex
+	processed int
+	requeued  int
+	dropped   int
+	latencies []time.Duration
+}
+
+func (m *fakeMetrics) IncProcessed() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.processed++
+}
+
+func (m *fakeMetrics) IncRequeued() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requeued++
+}
+
+func (m *fakeMetrics) IncDropped() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.dropped++
+}
+
+func (m *fakeMetrics) ObserveLatency(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.latencies = append(m.latencies, d)
+}
+
+func TestQueue_SetMetrics(t *testing.T) {
+	metrics := &fakeMetrics{}
+	mu := sync.Mutex{}
+	calls := map[string]int{}
+
+	q := NewQueueWithRateLimiter(func(obj interface{}) (HandleResult, error) {
+		key := obj.(string)
+		mu.Lock()
+		calls[key]++
+		count := calls[key]
+		mu.Unlock()
+		switch key {
+		case "requeue":
+			if count == 1 {
+				return HandleResult{RequeueAfter: time.Millisecond}, nil
+			}
+		case "error":
+			return HandleResult{}, errors.New("failed")
+		}
+		return HandleResult{}, nil
+	}, workqueue.NewItemExponentialFailureRateLimiter(time.Millisecond, 10*time.Millisecond)).
+		SetMaxErrRetries(1).
+		SetMetrics(metrics)
+	q.Run(1)
+	defer q.ShutDown()
+
+	q.Enqueue("normal")
+	q.Enqueue("requeue")
+	q.Enqueue("error")
+
+	// wait for all items including the delayed requeues to be processed
+	err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return calls["requeue"] == 2 && calls["error"] == 2, nil
+	})
+	if err != nil {
+		t.Fatalf("timeout waiting for items to be processed, got %v", calls)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := q.WaitForEmpty(ctx); err != nil {
+		t.Fatalf("WaitForEmpty() error = %v", err)
+	}
+
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	// normal: 1, requeue: 2, error: 2
+	if metrics.processed != 5 {
+		t.Errorf("processed = %v, want 5", metrics.processed)
+	}
+	if len(metrics.latencies) != 5 {
+		t.Errorf("observed latencies = %v, want 5", len(metrics.latencies))
+	}
+	// requeue by RequeueAfter and error retry once
+	if metrics.requeued != 2 {
+		t.Errorf("requeued = %v, want 2", metrics.requeued)
+	}
+	// error is dropped after exceeding max retries
+	if metrics.dropped != 1 {
+		t.Errorf("dropped = %v, want 1", metrics.dropped)
+	}
+}
+
+func TestQueue_SetKeyFunc_SetRateLimiter(t *testing.T) {
+	rl := &recordingRateLimiter{
+		RateLimiter: workqueue.NewItemExponentialFailureRateLimiter(time.Millisecond, 10*time.Millisecond),
+	}
+	mu := sync.Mutex{}
+	handled := []keyedItem{}
+	q := NewQueueContext(func(_ context.Context, obj interface{}) (HandleResult, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		handled = append(handled, obj.(keyedItem))
+		if len(handled) == 1 {
+			// requeued by AddRateLimited
+			return HandleResult{}, errors.New("failed")
+		}
+		return HandleResult{}, nil
+	}).SetRateLimiter(rl).SetKeyFunc(func(obj interface{}) (string, error) {
+		return obj.(keyedItem).name, nil
+	}).SetMaxErrRetries(3)
+	defer q.ShutDown()
+
+	q.Enqueue(keyedItem{name: "a", value: 1})
+	q.Enqueue(keyedItem{name: "a", value: 2})
+	q.Run(1)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := q.WaitForEmpty(ctx); err != nil {
+		t.Fatalf("WaitForEmpty() error = %v", err)
+	}
+
+	mu.Lock()
+	want := []keyedItem{{name: "a", value: 2}, {name: "a", value: 2}}
+	if !reflect.DeepEqual(handled, want) {
+		t.Errorf("handler got %v, want %v", handled, want)
+	}
+	mu.Unlock()
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if rl.whens != 1 {
+		t.Errorf("RateLimiter.When() is called %v times, want 1", rl.whens)
+	}
+}
+
+func TestNewQueueContext(t *testing.T) {
+	started := make(chan struct{})
+	cancelled := make(chan error, 1)
+	q := NewQueueContext(func(ctx context.Context, obj interface{}) (HandleResult, error) {
+		close(started)
+		select {
+		case <-ctx.Done():
+			cancelled <- ctx.Err()
+		case <-time.After(5 * time.Second):
+			cancelled <- nil
+		}
+		return HandleResult{}, nil
+	})
+	q.Run(1)
+
+	q.Enqueue("obj")
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting for handler to start")
+	}
+
+	shutdown := make(chan struct{})
+	go func() {
+		q.ShutDown()
+		close(shutdown)
+	}()
+
+	if err := <-cancelled; err != context.Canceled {
+		t.Errorf("handler context error = %v, want %v", err, context.Canceled)
+	}
+	select {
+	case <-shutdown:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting for ShutDown to return")
+	}
+}
+
+func TestQueue_RunContext(t *testing.T) {
+	processed := make(chan struct{}, 1)
+	q := NewQueue(func(obj interface{}) (HandleResult, error) {
+		processed <- struct{}{}
+		return HandleResult{}, nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	q.RunContext(ctx, 2)
+
+	// make sure the workers are running
+	q.Enqueue("obj")
+	select {
+	case <-processed:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting for obj to be processed")
+	}
+
+	cancel()
+	done := make(chan struct{})
+	go func() {
+		q.waitGroup.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting for workers to stop after context is cancelled")
+	}
+	if err := wait.PollImmediate(time.Millisecond, time.Second, func() (bool, error) {
+		return q.IsShuttingDown(), nil
+	}); err != nil {
+		t.Errorf("queue should be shutting down after context is cancelled")
+	}
+
+	// ShutDown after the context is done must not close channels twice
+	q.ShutDown()
+}
+
+func TestQueue_RunContext_ShutDown(t *testing.T) {
+	q := NewQueue(func(obj interface{}) (HandleResult, error) {
+		return HandleResult{}, nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	q.RunContext(ctx, 1)
+
+	q.ShutDown()
+	// cancel after ShutDown must not close channels twice
+	cancel()
+	q.ShutDown()
+}
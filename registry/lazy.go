@@ -0,0 +1,56 @@
+// Copyright 2023 jim.zoumo@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// LazyFactory constructs the value of a lazily registered entry.
+type LazyFactory func() (interface{}, error)
+
+// lazyValue memoizes the result of a LazyFactory. Like sync.Once, the
+// factory is called only once by concurrent callers, but it will be
+// called again by the next caller if it failed.
+type lazyValue struct {
+	factory LazyFactory
+
+	done  uint32
+	mu    sync.Mutex
+	value interface{}
+}
+
+func newLazyValue(factory LazyFactory) *lazyValue {
+	return &lazyValue{factory: factory}
+}
+
+func (l *lazyValue) get() (interface{}, error) {
+	if atomic.LoadUint32(&l.done) == 1 {
+		return l.value, nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.done == 0 {
+		v, err := l.factory()
+		if err != nil {
+			return nil, err
+		}
+		l.value = v
+		atomic.StoreUint32(&l.done, 1)
+	}
+	return l.value, nil
+}
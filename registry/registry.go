@@ -17,6 +17,7 @@ package registry
 import (
 	"fmt"
 	"sync"
+	"sync/atomic"
 )
 
 // Registry provides a place binding name and interface{}
@@ -26,24 +27,65 @@ type Registry interface {
 	// and the registry does not allow user to override the interface.
 	Register(name string, v interface{}) error
 
-	// Get returns an interface registered with the given name
+	// MustRegister is like Register but panics if registration fails.
+	// It is useful in package init blocks.
+	MustRegister(name string, v interface{})
+
+	// RegisterLazy registers a factory by name, the factory will not be
+	// called until the first Get. The value constructed by the factory is
+	// memoized, and the factory will be called again on next Get if it
+	// returns an error.
+	RegisterLazy(name string, factory LazyFactory) error
+
+	// Unregister removes the interface registered with the given name.
+	// It is a no-op if the name is not registered.
+	Unregister(name string)
+
+	// Get returns an interface registered with the given name.
+	// It returns false if the factory of a lazy interface fails.
 	Get(name string) (interface{}, bool)
 
+	// GetOrRegister returns the existing interface registered with the
+	// given name. Otherwise, it registers and returns the interface built
+	// by factory. The factory is called at most once for a name even if
+	// GetOrRegister is called concurrently.
+	// It returns nil if the factory of a lazy interface fails.
+	GetOrRegister(name string, factory func() interface{}) interface{}
+
 	// Range calls f sequentially for each key and value present in the registry.
 	// If f returns false, range stops the iteration.
+	// Lazy interfaces are constructed, and those whose factory fails are
+	// skipped, the same rule applies to Keys, Values and Snapshot.
 	Range(func(key string, value interface{}) bool)
 
 	// Keys returns the name of all registered interfaces
+	// Lazy interfaces whose factory fails are skipped.
 	Keys() []string
 
 	// Values returns all registered interfaces
+	// Lazy interfaces whose factory fails are skipped.
 	Values() []interface{}
+
+	// Snapshot returns a point-in-time copy of all registered interfaces
+	// keyed by name. Later changes to the registry are not reflected.
+	// Lazy interfaces whose factory fails are skipped.
+	Snapshot() map[string]interface{}
+
+	// Len returns the number of registered interfaces, including lazy
+	// interfaces whose factory fails.
+	Len() int
 }
 
 // registry is a struct binding name and interface such as Constructor
 type registry struct {
 	data            sync.Map
 	overrideAllowed bool
+
+	// writeMu serializes Register and Unregister to keep count consistent
+	// with data, reads are still lock free.
+	writeMu sync.Mutex
+	// count is the number of entries in data
+	count int64
 }
 
 // Config is a struct containing all config for registry
@@ -77,26 +119,94 @@ func New(config *Config) Registry {
 // It will panic if name corresponds to an already registered interface
 // and the registry does not allow user to override the interface.
 func (r *registry) Register(name string, v interface{}) error {
-	if r.overrideAllowed {
-		r.data.Store(name, v)
-	} else {
-		_, ok := r.data.LoadOrStore(name, v)
-		if ok {
-			return fmt.Errorf("[registry] Repeated registration key: %v", name)
-		}
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	_, ok := r.data.LoadOrStore(name, v)
+	if !ok {
+		atomic.AddInt64(&r.count, 1)
+		return nil
 	}
+	if !r.overrideAllowed {
+		return fmt.Errorf("[registry] Repeated registration key: %v", name)
+	}
+	r.data.Store(name, v)
 	return nil
 }
 
+// MustRegister is like Register but panics if registration fails.
+func (r *registry) MustRegister(name string, v interface{}) {
+	if err := r.Register(name, v); err != nil {
+		panic(err)
+	}
+}
+
+// RegisterLazy registers a factory by name, the factory will not be
+// called until the first Get.
+func (r *registry) RegisterLazy(name string, factory LazyFactory) error {
+	return r.Register(name, newLazyValue(factory))
+}
+
+// Unregister removes the interface registered with the given name.
+func (r *registry) Unregister(name string) {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	if _, ok := r.data.LoadAndDelete(name); ok {
+		atomic.AddInt64(&r.count, -1)
+	}
+}
+
 // Get returns an interface registered with the given name
 func (r *registry) Get(name string) (interface{}, bool) {
-	return r.data.Load(name)
+	v, err := r.lookup(name)
+	if err != nil {
+		return nil, false
+	}
+	return v, true
+}
+
+// lookup returns an interface registered with the given name, or
+// an error if it is not found or its lazy factory fails.
+func (r *registry) lookup(name string) (interface{}, error) {
+	v, ok := r.data.Load(name)
+	if !ok {
+		return nil, fmt.Errorf("[registry] Key not found: %v", name)
+	}
+	return resolve(v)
+}
+
+// GetOrRegister returns the existing interface registered with the given
+// name, or registers and returns the interface built by factory.
+func (r *registry) GetOrRegister(name string, factory func() interface{}) interface{} {
+	v, ok := r.data.Load(name)
+	if !ok {
+		r.writeMu.Lock()
+		// the factory is stored lazily so that it is called only once by
+		// concurrent callers no matter who wins the LoadOrStore.
+		v, ok = r.data.LoadOrStore(name, newLazyValue(func() (interface{}, error) {
+			return factory(), nil
+		}))
+		if !ok {
+			atomic.AddInt64(&r.count, 1)
+		}
+		r.writeMu.Unlock()
+	}
+	v, err := resolve(v)
+	if err != nil {
+		return nil
+	}
+	return v
 }
 
 // Range calls f sequentially for each key and value present in the registry.
 // If f returns false, range stops the iteration.
 func (r *registry) Range(f func(key string, value interface{}) bool) {
 	r.data.Range(func(k, v interface{}) bool {
+		v, err := resolve(v)
+		if err != nil {
+			return true
+		}
 		return f(k.(string), v)
 	})
 }
@@ -104,8 +214,8 @@ func (r *registry) Range(f func(key string, value interface{}) bool) {
 // Keys returns the name of all registered interfaces
 func (r *registry) Keys() []string {
 	names := []string{}
-	r.data.Range(func(k, v interface{}) bool {
-		names = append(names, k.(string))
+	r.Range(func(k string, v interface{}) bool {
+		names = append(names, k)
 		return true
 	})
 	return names
@@ -114,9 +224,33 @@ func (r *registry) Keys() []string {
 // Values returns all registered interfaces
 func (r *registry) Values() []interface{} {
 	ret := []interface{}{}
-	r.data.Range(func(k, v interface{}) bool {
+	r.Range(func(k string, v interface{}) bool {
 		ret = append(ret, v)
 		return true
 	})
 	return ret
 }
+
+// Snapshot returns a point-in-time copy of all registered interfaces
+// keyed by name.
+func (r *registry) Snapshot() map[string]interface{} {
+	ret := map[string]interface{}{}
+	r.Range(func(k string, v interface{}) bool {
+		ret[k] = v
+		return true
+	})
+	return ret
+}
+
+// Len returns the number of registered interfaces
+func (r *registry) Len() int {
+	return int(atomic.LoadInt64(&r.count))
+}
+
+// resolve constructs the value if v is registered lazily.
+func resolve(v interface{}) (interface{}, error) {
+	if lazy, ok := v.(*lazyValue); ok {
+		return lazy.get()
+	}
+	return v, nil
+}
@@ -15,7 +15,11 @@
 package registry
 
 import (
+	"errors"
 	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"testing"
 )
 
@@ -72,3 +76,240 @@ func Test_registry_Get(t *testing.T) {
 		})
 	}
 }
+
+func Test_registry_RegisterLazy(t *testing.T) {
+	r := New(nil)
+	var calls int32
+	err := r.RegisterLazy("test", func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return 1, nil
+	})
+	if err != nil {
+		t.Fatalf("registry.RegisterLazy() error = %v", err)
+	}
+	if err := r.RegisterLazy("test", nil); err == nil {
+		t.Errorf("registry.RegisterLazy() want error on repeated registration")
+	}
+	if got := atomic.LoadInt32(&calls); got != 0 {
+		t.Errorf("factory should not be called before Get, got %v calls", got)
+	}
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, ok := r.Get("test")
+			if !ok || !reflect.DeepEqual(got, 1) {
+				t.Errorf("registry.Get() = %v, %v, want 1, true", got, ok)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("factory should be called once, got %v calls", got)
+	}
+}
+
+func Test_registry_RegisterLazyError(t *testing.T) {
+	r := New(nil)
+	errFactory := errors.New("factory error")
+	calls := 0
+	r.RegisterLazy("test", func() (interface{}, error) { //nolint
+		calls++
+		if calls < 3 {
+			return nil, errFactory
+		}
+		return calls, nil
+	})
+
+	for i := 0; i < 2; i++ {
+		if _, err := r.(*registry).lookup("test"); !errors.Is(err, errFactory) {
+			t.Errorf("registry.lookup() error = %v, want %v", err, errFactory)
+		}
+	}
+	if got, ok := r.Get("test"); !ok || got != 3 {
+		t.Errorf("registry.Get() = %v, %v, want 3, true", got, ok)
+	}
+	if got, err := r.(*registry).lookup("test"); err != nil || got != 3 {
+		t.Errorf("registry.lookup() = %v, %v, want memoized value 3", got, err)
+	}
+	if calls != 3 {
+		t.Errorf("factory should not be called after it succeeds, got %v calls", calls)
+	}
+	if _, err := r.(*registry).lookup("test2"); err == nil {
+		t.Errorf("registry.lookup() want error for unregistered name")
+	}
+}
+
+func Test_registry_Unregister(t *testing.T) {
+	tests := []struct {
+		name            string
+		overrideAllowed bool
+	}{
+		{"overrideNotAllowed", false},
+		{"overrideAllowed", true},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			r := New(&Config{OverrideAllowed: tt.overrideAllowed})
+			if got := r.Len(); got != 0 {
+				t.Errorf("registry.Len() = %v, want 0", got)
+			}
+
+			r.Register("a", 1) //nolint
+			r.Register("b", 2) //nolint
+			// registering an existing name never changes Len
+			err := r.Register("a", 3)
+			if (err != nil) == tt.overrideAllowed {
+				t.Errorf("registry.Register() error = %v, overrideAllowed %v", err, tt.overrideAllowed)
+			}
+			if got := r.Len(); got != 2 {
+				t.Errorf("registry.Len() = %v, want 2", got)
+			}
+
+			r.Unregister("a")
+			if _, ok := r.Get("a"); ok {
+				t.Errorf("registry.Get() should return false after Unregister")
+			}
+			if got := r.Len(); got != 1 {
+				t.Errorf("registry.Len() = %v, want 1", got)
+			}
+
+			// unregistering an unknown name is a no-op
+			r.Unregister("a")
+			r.Unregister("c")
+			if got := r.Len(); got != 1 {
+				t.Errorf("registry.Len() = %v, want 1", got)
+			}
+
+			// the name can be registered again after Unregister
+			if err := r.Register("a", 4); err != nil {
+				t.Errorf("registry.Register() error = %v", err)
+			}
+			if got, _ := r.Get("a"); got != 4 {
+				t.Errorf("registry.Get() = %v, want 4", got)
+			}
+			if got := r.Len(); got != len(r.Keys()) {
+				t.Errorf("registry.Len() = %v, want %v", got, len(r.Keys()))
+			}
+		})
+	}
+}
+
+func Test_registry_LenConcurrent(t *testing.T) {
+	r := New(&Config{OverrideAllowed: true})
+	wg := sync.WaitGroup{}
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name := string(rune('a' + i%10))
+			r.Register(name, i) //nolint
+			if i%3 == 0 {
+				r.Unregister(name)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if got, want := r.Len(), len(r.Keys()); got != want {
+		t.Errorf("registry.Len() = %v, want %v", got, want)
+	}
+}
+
+func Test_registry_MustRegister(t *testing.T) {
+	r := New(nil)
+	r.MustRegister("test", 1)
+	if got, ok := r.Get("test"); !ok || got != 1 {
+		t.Errorf("registry.Get() = %v, %v, want 1, true", got, ok)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("registry.MustRegister() should panic on repeated registration")
+		}
+	}()
+	r.MustRegister("test", 2)
+}
+
+func Test_registry_GetOrRegister(t *testing.T) {
+	r := New(nil)
+	r.Register("exists", 1) //nolint
+
+	if got := r.GetOrRegister("exists", func() interface{} {
+		t.Errorf("factory should not be called for a registered name")
+		return 2
+	}); got != 1 {
+		t.Errorf("registry.GetOrRegister() = %v, want 1", got)
+	}
+
+	var calls int32
+	wg := sync.WaitGroup{}
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got := r.GetOrRegister("test", func() interface{} {
+				atomic.AddInt32(&calls, 1)
+				return 3
+			})
+			if got != 3 {
+				t.Errorf("registry.GetOrRegister() = %v, want 3", got)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("factory should be called once, got %v calls", got)
+	}
+	if got, ok := r.Get("test"); !ok || got != 3 {
+		t.Errorf("registry.Get() = %v, %v, want 3, true", got, ok)
+	}
+	if got := r.Len(); got != 2 {
+		t.Errorf("registry.Len() = %v, want 2", got)
+	}
+}
+
+func Test_registry_Snapshot(t *testing.T) {
+	r := New(nil)
+	want := map[string]interface{}{
+		"a": 1,
+		"b": "2",
+		"c": []int{3},
+	}
+	for k, v := range want {
+		r.Register(k, v) //nolint
+	}
+	r.RegisterLazy("lazy", func() (interface{}, error) { //nolint
+		return 4, nil
+	})
+	r.RegisterLazy("failed", func() (interface{}, error) { //nolint
+		return nil, errors.New("failed")
+	})
+	want["lazy"] = 4
+
+	got := r.Snapshot()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("registry.Snapshot() = %v, want %v", got, want)
+	}
+	// Keys and Values skip the failed lazy interface like Snapshot
+	keys := r.Keys()
+	sort.Strings(keys)
+	if want := []string{"a", "b", "c", "lazy"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("registry.Keys() = %v, want %v", keys, want)
+	}
+	if got := len(r.Values()); got != len(want) {
+		t.Errorf("registry.Values() got %v values, want %v", got, len(want))
+	}
+
+	// the snapshot is not affected by later changes
+	r.Unregister("a")
+	r.Register("d", 5) //nolint
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("registry.Snapshot() changed after registry changes, got %v", got)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2023 jim.zoumo@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+// TypedRegistry is a type safe wrapper of Registry binding name and T.
+// It has the same concurrency guarantees as Registry.
+type TypedRegistry[T any] struct {
+	registry Registry
+}
+
+// NewTyped returns a new TypedRegistry
+func NewTyped[T any](config *Config) *TypedRegistry[T] {
+	return &TypedRegistry[T]{
+		registry: New(config),
+	}
+}
+
+// Register registers a value by name.
+// It returns an error if name corresponds to an already registered value
+// and the registry does not allow user to override the value.
+func (r *TypedRegistry[T]) Register(name string, v T) error {
+	return r.registry.Register(name, v)
+}
+
+// Unregister removes the value registered with the given name.
+func (r *TypedRegistry[T]) Unregister(name string) {
+	r.registry.Unregister(name)
+}
+
+// Get returns a value registered with the given name
+func (r *TypedRegistry[T]) Get(name string) (T, bool) {
+	v, ok := r.registry.Get(name)
+	if !ok {
+		var zero T
+		return zero, false
+	}
+	// v may be a nil interface if T is an interface type
+	t, _ := v.(T)
+	return t, true
+}
+
+// Range calls f sequentially for each key and value present in the registry.
+// If f returns false, range stops the iteration.
+func (r *TypedRegistry[T]) Range(f func(key string, value T) bool) {
+	r.registry.Range(func(key string, value interface{}) bool {
+		t, _ := value.(T)
+		return f(key, t)
+	})
+}
+
+// Keys returns the name of all registered values
+func (r *TypedRegistry[T]) Keys() []string {
+	return r.registry.Keys()
+}
+
+// Values returns all registered values
+func (r *TypedRegistry[T]) Values() []T {
+	ret := []T{}
+	r.Range(func(_ string, v T) bool {
+		ret = append(ret, v)
+		return true
+	})
+	return ret
+}
+
+// Len returns the number of registered values
+func (r *TypedRegistry[T]) Len() int {
+	return r.registry.Len()
+}
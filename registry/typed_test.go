@@ -0,0 +1,94 @@
+// Copyright 2023 jim.zoumo@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"errors"
+	"sort"
+	"testing"
+)
+
+func TestTypedRegistry(t *testing.T) {
+	errFailed := errors.New("failed")
+	r := NewTyped[func() error](nil)
+
+	if err := r.Register("ok", func() error { return nil }); err != nil {
+		t.Fatalf("TypedRegistry.Register() error = %v", err)
+	}
+	if err := r.Register("failed", func() error { return errFailed }); err != nil {
+		t.Fatalf("TypedRegistry.Register() error = %v", err)
+	}
+	if err := r.Register("ok", func() error { return nil }); err == nil {
+		t.Errorf("TypedRegistry.Register() want error on repeated registration")
+	}
+
+	tests := []struct {
+		name    string
+		found   bool
+		wantErr error
+	}{
+		{"ok", true, nil},
+		{"failed", true, errFailed},
+		{"unknown", false, nil},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			constructor, ok := r.Get(tt.name)
+			if ok != tt.found {
+				t.Fatalf("TypedRegistry.Get() ok = %v, want %v", ok, tt.found)
+			}
+			if !ok {
+				if constructor != nil {
+					t.Errorf("TypedRegistry.Get() should return zero value if not found")
+				}
+				return
+			}
+			if err := constructor(); err != tt.wantErr {
+				t.Errorf("constructor() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if got := r.Len(); got != 2 {
+		t.Errorf("TypedRegistry.Len() = %v, want 2", got)
+	}
+	keys := r.Keys()
+	sort.Strings(keys)
+	if len(keys) != 2 || keys[0] != "failed" || keys[1] != "ok" {
+		t.Errorf("TypedRegistry.Keys() = %v, want [failed ok]", keys)
+	}
+	if got := len(r.Values()); got != 2 {
+		t.Errorf("TypedRegistry.Values() got %v values, want 2", got)
+	}
+
+	r.Unregister("failed")
+	if _, ok := r.Get("failed"); ok {
+		t.Errorf("TypedRegistry.Get() should return false after Unregister")
+	}
+	if got := r.Len(); got != 1 {
+		t.Errorf("TypedRegistry.Len() = %v, want 1", got)
+	}
+}
+
+func TestTypedRegistry_NilInterface(t *testing.T) {
+	r := NewTyped[error](&Config{OverrideAllowed: true})
+	r.Register("nil", nil) //nolint
+
+	got, ok := r.Get("nil")
+	if !ok || got != nil {
+		t.Errorf("TypedRegistry.Get() = %v, %v, want nil, true", got, ok)
+	}
+}
@@ -15,6 +15,9 @@
 package retry
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"time"
 
 	"k8s.io/apimachinery/pkg/util/wait"
@@ -83,6 +86,125 @@ func RetryIgnored(backoff Backoff, condition func() error, ignored func(error) b
 	return retry(backoff, condition, ignored, nil)
 }
 
+// RetryNotify does the same thing with Retry() except that it will keep trying
+// on any error, and calls notify after each failed attempt with the attempt
+// number starting from 1 and the error returned by condition.
+func RetryNotify(backoff Backoff, condition func() error, notify func(attempt int, err error)) error {
+	attempt := 0
+	return retry(backoff, condition, nil, func(err error) bool {
+		attempt++
+		if notify != nil {
+			notify(attempt, err)
+		}
+		return true
+	})
+}
+
+// RetryConstant executes the provided condition func up to steps times, and
+// waits for interval between attempts while the condition func returns an
+// error. e.g. RetryConstant(2*time.Second, 10, condition) tries every 2
+// seconds up to 10 times.
+//
+// If the retrying timeout, the last error of condition will be returned
+func RetryConstant(interval time.Duration, steps int, condition func() error) error {
+	backoff := Backoff{
+		Duration: interval,
+		Factor:   1.0,
+		Steps:    steps,
+	}
+	return retry(backoff, condition, nil, func(error) bool { return true })
+}
+
+// RetryResult executes the provided condition func repeatedly, retrying with
+// exponential backoff while the condition func returns an error, and returns
+// the value of the successful attempt.
+//
+// If the retrying timeout, the zero value of T and the last error of condition
+// will be returned.
+func RetryResult[T any](backoff Backoff, condition func() (T, error)) (T, error) {
+	var result T
+	err := retry(backoff, func() error {
+		ret, err := condition()
+		if err != nil {
+			return err
+		}
+		result = ret
+		return nil
+	}, nil, func(error) bool { return true })
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return result, nil
+}
+
+// RetryWithContext executes the provided condition func repeatedly with the
+// context, retrying with exponential backoff while the condition func returns
+// an error.
+//
+// It aborts as soon as the context is done and returns ctx.Err(). If condition
+// has failed, the returned error wraps both ctx.Err() and the last error of
+// condition, so that errors.Is and errors.As match either of them.
+//
+// If the retrying timeout, the last error of condition will be returned.
+func RetryWithContext(ctx context.Context, backoff Backoff, condition func(context.Context) error) error {
+	var lastErr error
+	for backoff.Steps > 0 {
+		if ctx.Err() != nil {
+			break
+		}
+		err := condition(ctx)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if backoff.Steps == 1 {
+			return lastErr
+		}
+
+		timer := time.NewTimer(backoff.Step())
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		if lastErr != nil {
+			return &contextError{ctxErr: err, lastErr: lastErr}
+		}
+		return err
+	}
+	return lastErr
+}
+
+// contextError is returned by RetryWithContext when the context is done after
+// condition failed.
+type contextError struct {
+	ctxErr  error
+	lastErr error
+}
+
+func (e *contextError) Error() string {
+	return fmt.Sprintf("%v, last error: %v", e.ctxErr, e.lastErr)
+}
+
+// Unwrap returns the context error
+func (e *contextError) Unwrap() error {
+	return e.ctxErr
+}
+
+// Is reports whether the context error or the last error matches target
+func (e *contextError) Is(target error) bool {
+	return errors.Is(e.ctxErr, target) || errors.Is(e.lastErr, target)
+}
+
+// As finds the first error in the context error and the last error that
+// matches target
+func (e *contextError) As(target interface{}) bool {
+	return errors.As(e.ctxErr, target) || errors.As(e.lastErr, target)
+}
+
 // retry executes the provided condition func repeatedly, retrying with exponential
 // backoff if the condition func returns an error.
 //
@@ -0,0 +1,237 @@
+// Copyright 2023 jim.zoumo@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRetryNotify(t *testing.T) {
+	errFailed := errors.New("failed")
+	backoff := Backoff{
+		Steps:    3,
+		Duration: time.Millisecond,
+		Factor:   1.0,
+	}
+
+	tests := []struct {
+		name         string
+		failures     int
+		wantAttempts []int
+		wantErr      error
+	}{
+		{"success", 0, nil, nil},
+		{"successAfterRetry", 2, []int{1, 2}, nil},
+		{"timeout", 5, []int{1, 2, 3}, errFailed},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			var attempts []int
+			err := RetryNotify(backoff, func() error {
+				calls++
+				if calls <= tt.failures {
+					return errFailed
+				}
+				return nil
+			}, func(attempt int, err error) {
+				if err != errFailed {
+					t.Errorf("notify() got error %v, want %v", err, errFailed)
+				}
+				attempts = append(attempts, attempt)
+			})
+			if err != tt.wantErr {
+				t.Errorf("RetryNotify() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(attempts, tt.wantAttempts) {
+				t.Errorf("RetryNotify() notified attempts %v, want %v", attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestRetryConstant(t *testing.T) {
+	errFailed := errors.New("failed")
+	tests := []struct {
+		name      string
+		steps     int
+		failures  int
+		wantCalls int
+		wantErr   error
+	}{
+		{"success", 5, 0, 1, nil},
+		{"successAfterRetry", 5, 3, 4, nil},
+		{"timeout", 5, 10, 5, errFailed},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			var last time.Time
+			err := RetryConstant(10*time.Millisecond, tt.steps, func() error {
+				now := time.Now()
+				if calls > 0 && now.Sub(last) < 10*time.Millisecond {
+					t.Errorf("RetryConstant() retried after %v, want at least 10ms", now.Sub(last))
+				}
+				last = now
+				calls++
+				if calls <= tt.failures {
+					return errFailed
+				}
+				return nil
+			})
+			if err != tt.wantErr {
+				t.Errorf("RetryConstant() error = %v, want %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("RetryConstant() called condition %v times, want %v", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRetryResult(t *testing.T) {
+	errNotReady := errors.New("not ready")
+	backoff := Backoff{
+		Steps:    5,
+		Duration: time.Millisecond,
+		Factor:   1.0,
+	}
+
+	tests := []struct {
+		name      string
+		threshold int
+		want      int
+		wantErr   error
+	}{
+		{"immediately", 1, 10, nil},
+		{"afterRetry", 3, 30, nil},
+		{"timeout", 10, 0, errNotReady},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			counter := 0
+			got, err := RetryResult(backoff, func() (int, error) {
+				counter++
+				if counter < tt.threshold {
+					return -1, errNotReady
+				}
+				return counter * 10, nil
+			})
+			if err != tt.wantErr {
+				t.Errorf("RetryResult() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("RetryResult() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryWithContext(t *testing.T) {
+	errFailed := errors.New("failed")
+	backoff := Backoff{
+		Steps:    3,
+		Duration: time.Millisecond,
+		Factor:   1.0,
+	}
+
+	tests := []struct {
+		name      string
+		failures  int
+		wantCalls int
+		wantErr   error
+	}{
+		{"success", 0, 1, nil},
+		{"successAfterRetry", 2, 3, nil},
+		{"timeout", 5, 3, errFailed},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := RetryWithContext(context.Background(), backoff, func(ctx context.Context) error {
+				calls++
+				if calls <= tt.failures {
+					return errFailed
+				}
+				return nil
+			})
+			if err != tt.wantErr {
+				t.Errorf("RetryWithContext() error = %v, want %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("RetryWithContext() called condition %v times, want %v", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRetryWithContext_Cancel(t *testing.T) {
+	backoff := Backoff{
+		Steps:    100,
+		Duration: time.Hour,
+		Factor:   1.0,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+
+	errFailed := errors.New("failed")
+	start := time.Now()
+	err := RetryWithContext(ctx, backoff, func(ctx context.Context) error {
+		calls++
+		return fmt.Errorf("wrapped: %w", errFailed)
+	})
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("RetryWithContext() returned after %v, want prompt return", elapsed)
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("RetryWithContext() error = %v, want %v", err, context.Canceled)
+	}
+	if !errors.Is(err, errFailed) {
+		t.Errorf("RetryWithContext() error = %v, want it wraps the last error %v", err, errFailed)
+	}
+	if calls != 1 {
+		t.Errorf("RetryWithContext() called condition %v times, want 1", calls)
+	}
+}
+
+func TestRetryWithContext_Done(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	calls := 0
+	err := RetryWithContext(ctx, DefaultRetry, func(ctx context.Context) error {
+		calls++
+		return nil
+	})
+	if err != context.Canceled {
+		t.Errorf("RetryWithContext() error = %v, want %v", err, context.Canceled)
+	}
+	if calls != 0 {
+		t.Errorf("RetryWithContext() called condition %v times, want 0", calls)
+	}
+}
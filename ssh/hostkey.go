@@ -0,0 +1,56 @@
+// Copyright 2023 jim.zoumo@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// KnownHostsCallback returns a HostKeyCallback which verifies the host key
+// against the OpenSSH known_hosts file at path.
+func KnownHostsCallback(path string) (ssh.HostKeyCallback, error) {
+	return knownhosts.New(path)
+}
+
+// FixedHostKey returns a HostKeyCallback which only accepts the given host
+// key. The key is either in the authorized_keys format (e.g. the content of
+// /etc/ssh/ssh_host_ed25519_key.pub) or a PEM encoded PKIX public key.
+func FixedHostKey(pemOrAuthorizedKey []byte) (ssh.HostKeyCallback, error) {
+	key, err := parsePublicKey(pemOrAuthorizedKey)
+	if err != nil {
+		return nil, err
+	}
+	return ssh.FixedHostKey(key), nil
+}
+
+func parsePublicKey(data []byte) (ssh.PublicKey, error) {
+	if block, _ := pem.Decode(data); block != nil {
+		pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("ssh: failed to parse PEM public key: %w", err)
+		}
+		return ssh.NewPublicKey(pub)
+	}
+	key, _, _, _, err := ssh.ParseAuthorizedKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("ssh: failed to parse authorized key: %w", err)
+	}
+	return key, nil
+}
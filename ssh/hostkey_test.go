@@ -0,0 +1,138 @@
+// Copyright 2023 jim.zoumo@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/zoumo/golib/ssh/internal/sshtest"
+)
+
+func newHostKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func pemPublicKey(t *testing.T, key ssh.PublicKey) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(key.(ssh.CryptoPublicKey).CryptoPublicKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func dialWithCallback(t *testing.T, server *sshtest.Server, callback ssh.HostKeyCallback) error {
+	t.Helper()
+	cfg := server.ClientConfig()
+	cfg.HostKeyCallback = callback
+	host, port := server.Host()
+	client, err := DialTCP(host, port, cfg)
+	if err != nil {
+		return err
+	}
+	return client.Close()
+}
+
+func TestKnownHostsCallback(t *testing.T) {
+	server, err := sshtest.NewServer()
+	if err != nil {
+		t.Fatalf("failed to start ssh server: %v", err)
+	}
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		key     ssh.PublicKey
+		wantErr bool
+	}{
+		{"match", server.HostKey, false},
+		{"mismatch", newHostKey(t), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "known_hosts")
+			line := knownhosts.Line([]string{knownhosts.Normalize(server.Addr)}, tt.key)
+			if err := os.WriteFile(path, []byte(line+"\n"), 0600); err != nil {
+				t.Fatal(err)
+			}
+			callback, err := KnownHostsCallback(path)
+			if err != nil {
+				t.Fatalf("KnownHostsCallback() error = %v", err)
+			}
+			if err := dialWithCallback(t, server, callback); (err != nil) != tt.wantErr {
+				t.Errorf("Dial() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := KnownHostsCallback(filepath.Join(t.TempDir(), "not-exist")); err == nil {
+		t.Error("KnownHostsCallback() with a missing file expects an error")
+	}
+}
+
+func TestFixedHostKey(t *testing.T) {
+	server, err := sshtest.NewServer()
+	if err != nil {
+		t.Fatalf("failed to start ssh server: %v", err)
+	}
+	defer server.Close()
+	other := newHostKey(t)
+
+	tests := []struct {
+		name        string
+		key         []byte
+		wantErr     bool
+		wantDialErr bool
+	}{
+		{"authorized key match", ssh.MarshalAuthorizedKey(server.HostKey), false, false},
+		{"authorized key mismatch", ssh.MarshalAuthorizedKey(other), false, true},
+		{"pem match", pemPublicKey(t, server.HostKey), false, false},
+		{"pem mismatch", pemPublicKey(t, other), false, true},
+		{"invalid", []byte("invalid"), true, false},
+		{"invalid pem", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: []byte("invalid")}), true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			callback, err := FixedHostKey(tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FixedHostKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if err := dialWithCallback(t, server, callback); (err != nil) != tt.wantDialErr {
+				t.Errorf("Dial() error = %v, wantErr %v", err, tt.wantDialErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,304 @@
+// Copyright 2023 jim.zoumo@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sshtest provides a local SSH server for testing. It runs the
+// commands of exec requests by "sh -c" on the local host.
+package sshtest
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"io"
+	"net"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// PtyRequest is the payload of a pty-req request
+type PtyRequest struct {
+	Term     string
+	Columns  uint32
+	Rows     uint32
+	Width    uint32
+	Height   uint32
+	Modelist string
+}
+
+// Modes parses the encoded terminal modes
+func (p PtyRequest) Modes() ssh.TerminalModes {
+	modes := ssh.TerminalModes{}
+	list := []byte(p.Modelist)
+	for len(list) >= 5 && list[0] != 0 {
+		op := list[0]
+		val := uint32(list[1])<<24 | uint32(list[2])<<16 | uint32(list[3])<<8 | uint32(list[4])
+		modes[op] = val
+		list = list[5:]
+	}
+	return modes
+}
+
+// Server is a local SSH server
+type Server struct {
+	// Addr is the address the server listens on
+	Addr string
+	// HostKey is the public key of the server
+	HostKey ssh.PublicKey
+
+	// ExecDelay delays every exec request if it is greater than 0
+	ExecDelay time.Duration
+	// IgnoreGlobalRequests makes the server never reply global requests
+	IgnoreGlobalRequests bool
+
+	listener net.Listener
+	config   *ssh.ServerConfig
+
+	mu             sync.Mutex
+	globalRequests []string
+	ptyRequests    []PtyRequest
+	sessions       int
+	maxSessions    int
+	conns          []net.Conn
+	wg             sync.WaitGroup
+}
+
+// NewServer starts a new local SSH server accepting any client
+func NewServer() (*Server, error) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		return nil, err
+	}
+	config := &ssh.ServerConfig{
+		NoClientAuth: true,
+	}
+	config.AddHostKey(signer)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		Addr:     ln.Addr().String(),
+		HostKey:  signer.PublicKey(),
+		listener: ln,
+		config:   config,
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Host returns the host and port of the server
+func (s *Server) Host() (host, port string) {
+	host, port, _ = net.SplitHostPort(s.Addr)
+	return host, port
+}
+
+// ClientConfig returns a client config to connect the server
+func (s *Server) ClientConfig() *ssh.ClientConfig {
+	return &ssh.ClientConfig{
+		User:            "test",
+		HostKeyCallback: ssh.FixedHostKey(s.HostKey),
+		Timeout:         5 * time.Second,
+	}
+}
+
+// Dial returns a client connected to the server
+func (s *Server) Dial() (*ssh.Client, error) {
+	return ssh.Dial("tcp", s.Addr, s.ClientConfig())
+}
+
+// GlobalRequests returns the types of global requests received
+func (s *Server) GlobalRequests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.globalRequests...)
+}
+
+// PtyRequests returns the pty-req requests received
+func (s *Server) PtyRequests() []PtyRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]PtyRequest(nil), s.ptyRequests...)
+}
+
+// MaxSessions returns the max number of concurrent sessions
+func (s *Server) MaxSessions() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.maxSessions
+}
+
+// CloseConns closes all the client connections without closing the server
+func (s *Server) CloseConns() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+}
+
+// Close stops the server and closes all the connections
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	s.CloseConns()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns = append(s.conns, conn)
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handleConn(conn)
+		}()
+	}
+}
+
+func (s *Server) handleConn(conn net.Conn) {
+	defer conn.Close()
+	_, chans, reqs, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		return
+	}
+	go func() {
+		for req := range reqs {
+			s.mu.Lock()
+			s.globalRequests = append(s.globalRequests, req.Type)
+			s.mu.Unlock()
+			if req.WantReply && !s.IgnoreGlobalRequests {
+				req.Reply(true, nil) //nolint
+			}
+		}
+	}()
+	for newCh := range chans {
+		if newCh.ChannelType() != "session" {
+			newCh.Reject(ssh.UnknownChannelType, "unknown channel type") //nolint
+			continue
+		}
+		ch, chReqs, err := newCh.Accept()
+		if err != nil {
+			continue
+		}
+		go s.handleSession(ch, chReqs)
+	}
+}
+
+func (s *Server) handleSession(ch ssh.Channel, reqs <-chan *ssh.Request) {
+	// the session is no longer counted when the client sees it closed
+	defer ch.Close()
+	s.mu.Lock()
+	s.sessions++
+	if s.sessions > s.maxSessions {
+		s.maxSessions = s.sessions
+	}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.sessions--
+		s.mu.Unlock()
+	}()
+
+	for req := range reqs {
+		switch req.Type {
+		case "pty-req":
+			pty := PtyRequest{}
+			if err := ssh.Unmarshal(req.Payload, &pty); err != nil {
+				req.Reply(false, nil) //nolint
+				continue
+			}
+			s.mu.Lock()
+			s.ptyRequests = append(s.ptyRequests, pty)
+			s.mu.Unlock()
+			req.Reply(true, nil) //nolint
+		case "exec", "shell":
+			var cmd string
+			if req.Type == "exec" {
+				payload := struct{ Command string }{}
+				if err := ssh.Unmarshal(req.Payload, &payload); err != nil {
+					req.Reply(false, nil) //nolint
+					return
+				}
+				cmd = payload.Command
+			}
+			req.Reply(true, nil) //nolint
+			go ssh.DiscardRequests(reqs)
+			s.run(ch, cmd)
+			return
+		default:
+			req.Reply(req.Type == "env" || req.Type == "window-change", nil) //nolint
+		}
+	}
+}
+
+func (s *Server) run(ch ssh.Channel, command string) {
+	if s.ExecDelay > 0 {
+		time.Sleep(s.ExecDelay)
+	}
+	var cmd *exec.Cmd
+	if command == "" {
+		cmd = exec.Command("sh")
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	cmd.Stdout = ch
+	cmd.Stderr = ch.Stderr()
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		sendExitStatus(ch, 255)
+		return
+	}
+	if err := cmd.Start(); err != nil {
+		sendExitStatus(ch, 255)
+		return
+	}
+	// do not wait for copying stdin, the peer may never close it
+	go func() {
+		io.Copy(stdin, ch) //nolint
+		stdin.Close()
+	}()
+
+	status := 0
+	if err := cmd.Wait(); err != nil {
+		status = 255
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			if ws, ok := exitErr.Sys().(syscall.WaitStatus); ok {
+				status = ws.ExitStatus()
+			}
+		}
+	}
+	sendExitStatus(ch, status)
+}
+
+func sendExitStatus(ch ssh.Channel, status int) {
+	payload := struct{ Status uint32 }{uint32(status)}
+	ch.SendRequest("exit-status", false, ssh.Marshal(&payload)) //nolint
+}
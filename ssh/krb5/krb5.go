@@ -37,6 +37,12 @@ const (
 	ContextFlagREADY = 128
 )
 
+// replaced in tests
+var (
+	getServiceTicket  = (*client.Client).GetServiceTicket
+	newKRB5TokenAPREQ = spnego.NewKRB5TokenAPREQ
+)
+
 func NewGSSAPIClientWithCCache(krb5confPath, ccachePath string) (ssh.GSSAPIClient, error) {
 	cfg, err := config.Load(krb5confPath)
 	if err != nil {
@@ -112,12 +118,15 @@ func (k *GSSAPIClient) InitSecContext(target string, token []byte, isGSSDelegCre
 	if len(token) == 0 {
 		newTarget := strings.ReplaceAll(target, "@", "/")
 
-		tkt, sKey, err := k.client.GetServiceTicket(newTarget)
+		tkt, sKey, err := getServiceTicket(k.client, newTarget)
 		if err != nil {
 			return nil, false, errors.Wrap(err, "failed to get service ticket")
 		}
 
-		krb5Token, err := spnego.NewKRB5TokenAPREQ(k.client, tkt, sKey, GSSAPIFlags, APOptions) //nolint
+		krb5Token, err := newKRB5TokenAPREQ(k.client, tkt, sKey, GSSAPIFlags, APOptions)
+		if err != nil {
+			return nil, false, errors.Wrap(err, "failed to create krb5 AP_REQ token")
+		}
 
 		creds := k.client.Credentials
 		auth, err := types.NewAuthenticator(creds.Domain(), creds.CName())
@@ -0,0 +1,54 @@
+// Copyright 2023 jim.zoumo@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package krb5
+
+import (
+	"testing"
+
+	"github.com/jcmturner/gokrb5/v8/client"
+	"github.com/jcmturner/gokrb5/v8/messages"
+	"github.com/jcmturner/gokrb5/v8/spnego"
+	"github.com/jcmturner/gokrb5/v8/types"
+	"github.com/pkg/errors"
+)
+
+func TestGSSAPIClient_InitSecContext_tokenError(t *testing.T) {
+	oldGetServiceTicket, oldNewKRB5TokenAPREQ := getServiceTicket, newKRB5TokenAPREQ
+	defer func() {
+		getServiceTicket, newKRB5TokenAPREQ = oldGetServiceTicket, oldNewKRB5TokenAPREQ
+	}()
+
+	var gotTarget string
+	getServiceTicket = func(_ *client.Client, spn string) (messages.Ticket, types.EncryptionKey, error) {
+		gotTarget = spn
+		return messages.Ticket{}, types.EncryptionKey{}, nil
+	}
+	tokenErr := errors.New("token error")
+	newKRB5TokenAPREQ = func(*client.Client, messages.Ticket, types.EncryptionKey, []int, []int) (spnego.KRB5Token, error) {
+		return spnego.KRB5Token{}, tokenErr
+	}
+
+	k := &GSSAPIClient{client: &client.Client{}}
+	token, continueNeeded, err := k.InitSecContext("host@example.com", nil, false)
+	if errors.Cause(err) != tokenErr {
+		t.Fatalf("InitSecContext() error = %v, want %v", err, tokenErr)
+	}
+	if token != nil || continueNeeded {
+		t.Errorf("InitSecContext() = %v, %v, want nil, false", token, continueNeeded)
+	}
+	if gotTarget != "host/example.com" {
+		t.Errorf("InitSecContext() requested ticket for %q, want %q", gotTarget, "host/example.com")
+	}
+}
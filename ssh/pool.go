@@ -0,0 +1,128 @@
+// Copyright 2023 jim.zoumo@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"context"
+	"sync"
+
+	"github.com/spf13/afero"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/zoumo/golib/lock/maxinflight"
+	"github.com/zoumo/golib/ssh/scp"
+)
+
+// Pool shares one Client between many operations and limits the number of
+// concurrent sessions opened on it, SSH servers usually cap them
+// (MaxSessions in sshd_config).
+type Pool struct {
+	client   *Client
+	sessions maxinflight.TokenBucket
+}
+
+// NewPool returns a Pool which opens at most maxSessions concurrent sessions
+// on client. Zero maxSessions means no limit.
+func NewPool(client *Client, maxSessions uint32) *Pool {
+	sessions := maxinflight.InfinityTokenBucket
+	if maxSessions > 0 {
+		sessions = maxinflight.New(maxSessions)
+	}
+	return &Pool{
+		client:   client,
+		sessions: sessions,
+	}
+}
+
+// Client returns the underlying client, sessions opened on it directly are
+// not limited by the pool.
+func (p *Pool) Client() *Client {
+	return p.client
+}
+
+// Session is a session handed out by Pool, Close must be called to give
+// it back.
+type Session struct {
+	*ssh.Session
+
+	once    sync.Once
+	release func()
+}
+
+// Close closes the session and gives it back to the pool
+func (s *Session) Close() error {
+	err := s.Session.Close()
+	s.once.Do(s.release)
+	return err
+}
+
+// NewSession blocks until the number of concurrent sessions is under the
+// limit or the context is done, then opens a new session.
+func (p *Pool) NewSession(ctx context.Context) (*Session, error) {
+	if err := p.sessions.AcquireContext(ctx); err != nil {
+		return nil, err
+	}
+	session, err := p.client.NewSession()
+	if err != nil {
+		p.sessions.Release()
+		return nil, err
+	}
+	return &Session{
+		Session: session,
+		release: p.sessions.Release,
+	}, nil
+}
+
+// Exec runs cmd in a pooled session and returns its standard output.
+func (p *Pool) Exec(ctx context.Context, cmd string) ([]byte, error) {
+	session, err := p.NewSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+	return session.Output(cmd)
+}
+
+// ExecCombined runs cmd in a pooled session and returns its combined
+// standard output and standard error.
+func (p *Pool) ExecCombined(ctx context.Context, cmd string) ([]byte, error) {
+	session, err := p.NewSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+	return session.CombinedOutput(cmd)
+}
+
+// Upload copies local to remote, every session it opens (stat, mkdir,
+// transfer and symlinks) takes one session of the pool in turn.
+func (p *Pool) Upload(ctx context.Context, local, remote string) error {
+	return p.scp().Upload(ctx, local, remote)
+}
+
+// Download copies remote to local, every session it opens takes one
+// session of the pool in turn.
+func (p *Pool) Download(ctx context.Context, remote, local string) error {
+	return p.scp().Download(ctx, remote, local)
+}
+
+func (p *Pool) scp() *scp.SCP {
+	return scp.New(p.client.Client, afero.NewOsFs(), nil, scp.WithSessionGuard(p.sessions))
+}
+
+// Close closes the underlying client
+func (p *Pool) Close() error {
+	return p.client.Close()
+}
@@ -0,0 +1,149 @@
+// Copyright 2023 jim.zoumo@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	pkgerrors "github.com/pkg/errors"
+)
+
+func TestPool_maxSessions(t *testing.T) {
+	server, client := newTestClient(t)
+	pool := NewPool(client, 3)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			out, err := pool.Exec(context.Background(), "sleep 0.02; echo hello")
+			if err == nil && string(out) != "hello\n" {
+				err = errors.New("unexpected output: " + string(out))
+			}
+			errs <- err
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := pool.ExecCombined(context.Background(), "sleep 0.02")
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("pool operation error = %v", err)
+		}
+	}
+	if got := server.MaxSessions(); got > 3 || got == 0 {
+		t.Errorf("server max concurrent sessions = %v, want 1..3", got)
+	}
+}
+
+func TestPool_UploadDownload(t *testing.T) {
+	_, client := newTestClient(t)
+	pool := NewPool(client, 2)
+
+	dir := t.TempDir()
+	local := filepath.Join(dir, "local")
+	if err := os.WriteFile(local, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// the remote dir does not exist, Upload opens extra sessions
+			// to stat and create it
+			remote := filepath.Join(dir, fmt.Sprintf("remote-%d", i), "file")
+			if err := pool.Upload(context.Background(), local, remote); err != nil {
+				errs <- err
+				return
+			}
+			errs <- pool.Download(context.Background(), remote, remote+".download")
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("pool operation error = %v", err)
+		}
+	}
+	for i := 0; i < 5; i++ {
+		got, err := os.ReadFile(filepath.Join(dir, fmt.Sprintf("remote-%d", i), "file.download"))
+		if err != nil || string(got) != "hello" {
+			t.Errorf("downloaded file %d = %q, %v, want %q", i, got, err, "hello")
+		}
+	}
+
+	// all sessions of Upload and Download are taken from the pool
+	session, err := pool.NewSession(context.Background())
+	if err != nil {
+		t.Fatalf("NewSession() error = %v", err)
+	}
+	defer session.Close()
+	session2, err := pool.NewSession(context.Background())
+	if err != nil {
+		t.Fatalf("NewSession() error = %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := pool.Upload(ctx, local, filepath.Join(dir, "remote")); pkgerrors.Cause(err) != context.DeadlineExceeded {
+		t.Errorf("Upload() on a full pool error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	session2.Close()
+	if err := pool.Upload(context.Background(), local, filepath.Join(dir, "remote")); err != nil {
+		t.Errorf("Upload() error = %v", err)
+	}
+}
+
+func TestPool_NewSession(t *testing.T) {
+	_, client := newTestClient(t)
+	pool := NewPool(client, 1)
+
+	session, err := pool.NewSession(context.Background())
+	if err != nil {
+		t.Fatalf("NewSession() error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := pool.NewSession(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("NewSession() over the limit error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	session.Close()
+	// closing twice must not give back the session twice
+	session.Close()
+	if _, err := pool.NewSession(context.Background()); err != nil {
+		t.Fatalf("NewSession() after Close error = %v", err)
+	}
+	if _, err := pool.NewSession(ctx); err == nil {
+		t.Fatal("NewSession() over the limit after double Close succeeded")
+	}
+}
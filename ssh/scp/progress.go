@@ -0,0 +1,58 @@
+// Copyright 2023 jim.zoumo@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scp
+
+import "io"
+
+// ProgressFunc is called with the number of bytes transferred and the total
+// size of the file at path while copying it.
+type ProgressFunc func(path string, transferred, total int64)
+
+// progressReader reports the progress to the ProgressFunc when reading
+type progressReader struct {
+	reader      io.Reader
+	path        string
+	total       int64
+	transferred int64
+	progress    ProgressFunc
+	reported    bool
+}
+
+func newProgressReader(reader io.Reader, path string, total int64, progress ProgressFunc) io.Reader {
+	if progress == nil || reader == nil {
+		return reader
+	}
+	return &progressReader{
+		reader:   reader,
+		path:     path,
+		total:    total,
+		progress: progress,
+	}
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		r.transferred += int64(n)
+		r.reported = true
+		r.progress(r.path, r.transferred, r.total)
+	}
+	if err == io.EOF && !r.reported {
+		// report empty file once
+		r.reported = true
+		r.progress(r.path, r.transferred, r.total)
+	}
+	return n, err
+}
@@ -26,20 +26,20 @@ import (
 )
 
 func newReader(ioReader io.Reader, timeout time.Duration) *reader {
-	const processors = 3
+	const bufferSize = 3
 	return &reader{
 		reader:     ioReader,
-		outputChan: make(chan []byte, processors),
-		closedChan: make(chan bool, processors),
-		errorChan:  make(chan error, processors),
+		outputChan: make(chan []byte, bufferSize),
+		closedChan: make(chan bool),
+		errorChan:  make(chan error, bufferSize),
 		timeout:    timeout,
 	}
 }
 
 type reader struct {
-	processors int
 	reader     io.Reader
 	outputChan chan []byte
+	// closedChan is closed to notify all the waiting read() calls
 	closedChan chan bool
 	errorChan  chan error
 	closed     uint32
@@ -47,15 +47,6 @@ type reader struct {
 	buf        bytes.Buffer
 }
 
-func (r *reader) sendCloseNotification() {
-	for i := 0; i < r.processors; i++ {
-		select {
-		case r.closedChan <- true:
-		case <-time.After(time.Millisecond):
-		}
-	}
-}
-
 func (r *reader) readStatus() error {
 	data, err := r.read()
 	if err != nil {
@@ -95,10 +86,9 @@ func (r *reader) isClosed() bool {
 
 func (r *reader) close() {
 	if atomic.CompareAndSwapUint32(&r.closed, 0, 1) {
-		r.sendCloseNotification()
-		close(r.errorChan)
+		// outputChan and errorChan are not closed, readInBackground
+		// may be sending on them.
 		close(r.closedChan)
-		close(r.outputChan)
 	}
 }
 
@@ -107,12 +97,27 @@ func (r *reader) read() ([]byte, error) {
 		return nil, fmt.Errorf("closed")
 	}
 
+	// data read before an error takes precedence
+	select {
+	case data := <-r.outputChan:
+		return data, nil
+	default:
+	}
+
+	// zero timeout means no timeout, the nil channel blocks forever
+	var timeoutC <-chan time.Time
+	if r.timeout > 0 {
+		timer := time.NewTimer(r.timeout)
+		defer timer.Stop()
+		timeoutC = timer.C
+	}
+
 	select {
 	case data := <-r.outputChan:
 		return data, nil
 	case err := <-r.errorChan:
 		return nil, err
-	case <-time.Tick(r.timeout):
+	case <-timeoutC:
 		return nil, fmt.Errorf("exceeded timeout %s", r.timeout)
 	case <-r.closedChan:
 		return nil, fmt.Errorf("closed")
@@ -146,6 +151,8 @@ func (r *reader) closeWithError(err error) {
 	if r.isClosed() {
 		return
 	}
-	r.errorChan <- err
-	r.sendCloseNotification()
+	select {
+	case r.errorChan <- err:
+	default:
+	}
 }
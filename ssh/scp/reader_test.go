@@ -0,0 +1,128 @@
+// Copyright 2023 jim.zoumo@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scp
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func Test_reader_closeBlockedBackground(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+
+	r := newReader(pr, 0)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.readInBackground()
+	}()
+
+	// nobody reads, readInBackground blocks on sending after the output
+	// channel is full
+	go func() {
+		for i := 0; i < 10; i++ {
+			if _, err := pw.Write([]byte("data")); err != nil {
+				return
+			}
+		}
+	}()
+	time.Sleep(20 * time.Millisecond)
+	r.close()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("readInBackground() does not exit after close")
+	}
+}
+
+func Test_reader_readTimeout(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+
+	r := newReader(pr, 0)
+	go r.readInBackground()
+	defer r.close()
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		pw.Write([]byte{StatusOK}) //nolint
+	}()
+	// zero timeout waits for the data instead of failing immediately
+	if err := r.readStatus(); err != nil {
+		t.Errorf("readStatus() with zero timeout error = %v", err)
+	}
+}
+
+func Test_reader_close(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+
+	r := newReader(pr, 0)
+	go r.readInBackground()
+
+	// more blocked readers than processors
+	const readers = 5
+	errC := make(chan error, readers)
+	for i := 0; i < readers; i++ {
+		go func() {
+			_, err := r.read()
+			errC <- err
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	r.close()
+	r.close()
+
+	for i := 0; i < readers; i++ {
+		select {
+		case err := <-errC:
+			if err == nil || err.Error() != "closed" {
+				t.Errorf("read() error = %v, want closed", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("read() is not unblocked after close")
+		}
+	}
+
+	if _, err := r.read(); err == nil || err.Error() != "closed" {
+		t.Errorf("read() after close error = %v, want closed", err)
+	}
+}
+
+func Test_reader_closeWithError(t *testing.T) {
+	pr, pw := io.Pipe()
+	r := newReader(pr, 0)
+	go r.readInBackground()
+	defer r.close()
+
+	errBroken := errors.New("broken")
+	go func() {
+		pw.Write([]byte("data")) //nolint
+		pw.CloseWithError(errBroken)
+	}()
+
+	// data read before the error is not lost
+	data, err := r.read()
+	if err != nil || string(data) != "data" {
+		t.Fatalf("read() = %q, %v, want data", data, err)
+	}
+	if _, err := r.read(); err != errBroken {
+		t.Errorf("read() error = %v, want %v", err, errBroken)
+	}
+}
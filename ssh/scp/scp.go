@@ -22,6 +22,8 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
@@ -29,29 +31,91 @@ import (
 	"golang.org/x/crypto/ssh"
 
 	"github.com/zoumo/golib/fileinfo"
+	"github.com/zoumo/golib/lock/maxinflight"
 )
 
 type stateFn func(string) (os.FileInfo, error)
 
 type SCP struct {
-	client *ssh.Client
-	fs     afero.Fs
-	logger logr.Logger
+	client   *ssh.Client
+	fs       afero.Fs
+	logger   logr.Logger
+	progress ProgressFunc
+	// readTimeout is the timeout of reading each response from peer
+	readTimeout time.Duration
+	// preserveSymlinks recreates symlinks on the remote in Upload instead
+	// of following them
+	preserveSymlinks bool
+	// sessionGuard limits the concurrent sessions if it is not nil
+	sessionGuard maxinflight.TokenBucket
 }
 
-func New(client *ssh.Client, fs afero.Fs, logger logr.Logger) *SCP {
+// Option configures the SCP
+type Option func(*SCP)
+
+// WithProgress sets the ProgressFunc which is called as bytes of each
+// regular file are copied in Upload and Download. The path is the source
+// path of the file.
+func WithProgress(progress ProgressFunc) Option {
+	return func(s *SCP) {
+		s.progress = progress
+	}
+}
+
+// WithReadTimeout sets the timeout of waiting for each response from the
+// remote scp. Zero means no timeout.
+func WithReadTimeout(timeout time.Duration) Option {
+	return func(s *SCP) {
+		s.readTimeout = timeout
+	}
+}
+
+// FollowSymlinks sets whether Upload follows symlinks, defaults to true.
+//
+// If follow is true, the symlinks to regular files are copied as their targets
+// and the directories behind symlinks are ignored.
+//
+// If follow is false, the symlinks are recreated on the remote as symlinks by
+// "ln -s" after the files are uploaded. The link targets are kept as they are,
+// they are not uploaded, so the targets must exist on the remote for the
+// links to be valid.
+func FollowSymlinks(follow bool) Option {
+	return func(s *SCP) {
+		s.preserveSymlinks = !follow
+	}
+}
+
+// WithSessionGuard makes every session opened by SCP hold a token of guard
+// until it is closed and the remote has acknowledged it, e.g. to share the
+// session limit of a client. SCP opens at most one session at a time for
+// each call.
+func WithSessionGuard(guard maxinflight.TokenBucket) Option {
+	return func(s *SCP) {
+		s.sessionGuard = guard
+	}
+}
+
+func New(client *ssh.Client, fs afero.Fs, logger logr.Logger, opts ...Option) *SCP {
 	if logger == nil {
 		logger = logr.Discard()
 	}
-	return &SCP{
+	s := &SCP{
 		client: client,
 		fs:     fs,
 		logger: logger,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 func (s *SCP) Stat(dst string) (os.FileInfo, error) {
-	info, _, err := s.Open(dst)
+	return s.stat(context.Background(), dst)
+}
+
+func (s *SCP) stat(ctx context.Context, dst string) (os.FileInfo, error) {
+	info, _, err := s.open(ctx, dst)
 	if err != nil {
 		return nil, err
 	}
@@ -59,10 +123,14 @@ func (s *SCP) Stat(dst string) (os.FileInfo, error) {
 }
 
 func (s *SCP) Open(dst string) (os.FileInfo, io.Reader, error) {
+	return s.open(context.Background(), dst)
+}
+
+func (s *SCP) open(ctx context.Context, dst string) (os.FileInfo, io.Reader, error) {
 	if err := validateSCPPath(dst); err != nil {
 		return nil, nil, err
 	}
-	session, err := newSession(s.client, scpRead, 0, s.logger)
+	session, err := s.newSession(ctx, scpRead)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -71,6 +139,41 @@ func (s *SCP) Open(dst string) (os.FileInfo, io.Reader, error) {
 	return session.Open(dst)
 }
 
+// readdirFormat is the find -printf format of Readdir, the records are
+// separated by NUL so that any file name can be parsed.
+const readdirFormat = `%y %m %s %T@ %f\0`
+
+// Readdir returns the file infos of the direct children of the remote
+// directory dst.
+// It lists the directory by GNU find on the remote host, the content of
+// files is not transferred.
+func (s *SCP) Readdir(dst string) ([]os.FileInfo, error) {
+	dst = cleanPath(dst)
+	if err := validateSCPPath(dst); err != nil {
+		return nil, err
+	}
+	quoted := shellQuote(dst)
+	cmd := fmt.Sprintf("if [ ! -d %s ]; then echo %s is not a directory >&2; exit 1; fi; find %s -mindepth 1 -maxdepth 1 -printf '%s'",
+		quoted, quoted, quoted, readdirFormat)
+	out, err := s.run(context.Background(), cmd)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read remote dir failed, receive msg: %v", strings.TrimSpace(string(out)))
+	}
+
+	infos := []os.FileInfo{}
+	for _, record := range strings.Split(string(out), "\x00") {
+		if record == "" {
+			continue
+		}
+		info, err := parseFindRecord(record)
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
 func (s *SCP) beforeCopy(source, target string, sourceStat, targetStat stateFn, notExistHandler func(target string) error) error {
 	if err := validateSCPPath(source); err != nil {
 		return err
@@ -112,7 +215,7 @@ func (s *SCP) Download(ctx context.Context, remote, local string) error {
 	local = cleanPath(local)
 	remote = cleanPath(remote)
 
-	err := s.beforeCopy(remote, local, s.Stat, s.fs.Stat, func(target string) error {
+	err := s.beforeCopy(remote, local, s.statFn(ctx), s.fs.Stat, func(target string) error {
 		// mkdir for local path's dir
 		if err := s.fs.MkdirAll(path.Dir(target), DefaultDirMode); err != nil {
 			return err
@@ -124,7 +227,7 @@ func (s *SCP) Download(ctx context.Context, remote, local string) error {
 		return err
 	}
 
-	session, err := newSession(s.client, scpRead, 0, s.logger)
+	session, err := s.newSession(ctx, scpRead)
 	if err != nil {
 		return err
 	}
@@ -155,6 +258,7 @@ func (s *SCP) Download(ctx context.Context, remote, local string) error {
 			return true, nil
 		}
 
+		reader = newProgressReader(reader, fpath, finfo.Size(), s.progress)
 		if err := s.writeFile(fullpath, finfo, reader); err != nil {
 			return false, err
 		}
@@ -187,12 +291,13 @@ func (s *SCP) Upload(ctx context.Context, local, remote string) error {
 	local = cleanPath(local)
 	remote = cleanPath(remote)
 
-	err := s.beforeCopy(local, remote, s.fs.Stat, s.Stat, func(target string) error {
+	err := s.beforeCopy(local, remote, s.fs.Stat, s.statFn(ctx), func(target string) error {
 		// create remote dir
-		session, err := s.client.NewSession()
+		session, release, err := s.openSession(ctx)
 		if err != nil {
 			return err
 		}
+		defer release()
 		defer session.Close()
 		msg, err := session.CombinedOutput(fmt.Sprintf("mkdir -p %s", path.Dir(target)))
 		if err != nil {
@@ -205,7 +310,7 @@ func (s *SCP) Upload(ctx context.Context, local, remote string) error {
 		return err
 	}
 
-	session, err := newSession(s.client, scpWrite, 0, s.logger)
+	session, err := s.newSession(ctx, scpWrite)
 	if err != nil {
 		return err
 	}
@@ -217,10 +322,25 @@ func (s *SCP) Upload(ctx context.Context, local, remote string) error {
 	}
 	defer closer.Close()
 
+	// symlinks maps the remote path to the target of symlinks to preserve
+	symlinks := map[string]string{}
+
 	err = afero.Walk(s.fs, local, func(fpath string, finfo os.FileInfo, perr error) error {
 		if perr != nil {
 			return perr
 		}
+		if fileinfo.IsSymlink(finfo) && s.preserveSymlinks {
+			target, err := s.readlink(fpath)
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(local, fpath)
+			if err != nil {
+				return err
+			}
+			symlinks[cleanPath(path.Join(remote, rel))] = target
+			return nil
+		}
 		if fileinfo.IsSymlink(finfo) {
 			// get real file info follow symlink to get true size
 			realInfo, err := s.fs.Stat(fpath)
@@ -250,14 +370,142 @@ func (s *SCP) Upload(ctx context.Context, local, remote string) error {
 				return err
 			}
 			defer f.Close()
-			content = f
+			content = newProgressReader(f, fpath, finfo.Size(), s.progress)
 		}
 
 		s.logger.V(3).Info("scp upload", "from", fpath, "to", fullpath, "isDir", finfo.IsDir())
 		return upload(ctx, fullpath, finfo, content)
 	})
+	if err != nil || len(symlinks) == 0 {
+		return err
+	}
+
+	// finish uploading before creating symlinks in the uploaded dirs, and
+	// close the session so that only one session is open at a time
+	closer.Close()
+	session.Close()
+	for fullpath, target := range symlinks {
+		s.logger.V(3).Info("scp create symlink", "path", fullpath, "target", target)
+		msg, err := s.run(ctx, fmt.Sprintf("ln -sfn -- %s %s", shellQuote(target), shellQuote(fullpath)))
+		if err != nil {
+			return errors.Wrapf(err, "create remote symlink failed, receive msg: %v", strings.TrimSpace(string(msg)))
+		}
+	}
+	return nil
+}
+
+func (s *SCP) readlink(name string) (string, error) {
+	reader, ok := s.fs.(afero.LinkReader)
+	if !ok {
+		return "", errors.Errorf("failed to read symlink %v: the file system does not support symlinks", name)
+	}
+	return reader.ReadlinkIfPossible(name)
+}
+
+// removeCmd removes a file by rm -f or an empty directory by rmdir, which
+// are supported by BusyBox and older coreutils as well. It fails if the
+// path does not exist like os.Remove.
+const removeCmd = `if [ -d %[1]s ] && [ ! -L %[1]s ]; then rmdir -- %[1]s; ` +
+	`elif [ -e %[1]s ] || [ -L %[1]s ]; then rm -f -- %[1]s; ` +
+	`else echo %[1]s: No such file or directory >&2; exit 1; fi`
+
+// removeAllCmd removes the path and any children it contains
+const removeAllCmd = `rm -rf -- %[1]s`
+
+// Remove removes the remote file or empty directory.
+func (s *SCP) Remove(ctx context.Context, remote string) error {
+	return s.remove(ctx, remote, removeCmd)
+}
+
+// RemoveAll removes the remote path and any children it contains.
+// It returns nil if the path does not exist.
+func (s *SCP) RemoveAll(ctx context.Context, remote string) error {
+	return s.remove(ctx, remote, removeAllCmd)
+}
+
+func (s *SCP) remove(ctx context.Context, remote, rmFormat string) error {
+	remote = cleanPath(remote)
+	if remote == "" || remote == "." {
+		return errors.New("can not remove empty file path")
+	}
+	if err := validateSCPPath(remote); err != nil {
+		return err
+	}
+	cmd := fmt.Sprintf(rmFormat, shellQuote(remote))
+	s.logger.V(3).Info("scp remove", "path", remote, "cmd", cmd)
+	msg, err := s.run(ctx, cmd)
+	if err != nil {
+		return errors.Wrapf(err, "remove remote path failed, receive msg: %v", strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// run runs the command in a new session and returns its combined output.
+// The session is closed if the context is done.
+func (s *SCP) run(ctx context.Context, cmd string) ([]byte, error) {
+	session, release, err := s.openSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	defer session.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			session.Close()
+		case <-done:
+		}
+	}()
+
+	msg, err := session.CombinedOutput(cmd)
+	if ctx.Err() != nil {
+		return msg, ctx.Err()
+	}
+	return msg, err
+}
+
+func (s *SCP) statFn(ctx context.Context) stateFn {
+	return func(dst string) (os.FileInfo, error) {
+		return s.stat(ctx, dst)
+	}
+}
+
+// openSession opens a new ssh session, it waits for a token of the session
+// guard if it is set. The returned release func gives back the token after
+// the session is closed, it is safe to be called more than once.
+func (s *SCP) openSession(ctx context.Context) (*ssh.Session, func(), error) {
+	if s.sessionGuard == nil {
+		session, err := s.client.NewSession()
+		return session, func() {}, err
+	}
+
+	if err := s.sessionGuard.AcquireContext(ctx); err != nil {
+		return nil, nil, err
+	}
+	session, err := s.client.NewSession()
+	if err != nil {
+		s.sessionGuard.Release()
+		return nil, nil, err
+	}
+	once := sync.Once{}
+	return session, func() { once.Do(s.sessionGuard.Release) }, nil
+}
+
+// newSession opens a new scp session
+func (s *SCP) newSession(ctx context.Context, mode sessionMode) (*session, error) {
+	sshSession, release, err := s.openSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return newSession(sshSession, release, mode, s.readTimeout, s.logger), nil
+}
 
-	return err
+// shellQuote quotes the string for POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
 }
 
 func cleanPath(p string) string {
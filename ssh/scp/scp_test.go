@@ -0,0 +1,437 @@
+// Copyright 2023 jim.zoumo@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scp
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/zoumo/golib/lock/maxinflight"
+	"github.com/zoumo/golib/ssh/internal/sshtest"
+)
+
+// newTestClient starts a local ssh server running commands on this host,
+// so the remote paths are local paths.
+func newTestClient(t *testing.T) (*sshtest.Server, *ssh.Client) {
+	t.Helper()
+	if _, err := exec.LookPath("scp"); err != nil {
+		t.Skip("scp is not installed")
+	}
+	server, err := sshtest.NewServer()
+	if err != nil {
+		t.Fatalf("failed to start ssh server: %v", err)
+	}
+	t.Cleanup(func() { server.Close() })
+	client, err := server.Dial()
+	if err != nil {
+		t.Fatalf("failed to dial ssh server: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return server, client
+}
+
+type progressRecorder struct {
+	mu      sync.Mutex
+	records map[string][]int64
+	totals  map[string]int64
+}
+
+func newProgressRecorder() *progressRecorder {
+	return &progressRecorder{
+		records: map[string][]int64{},
+		totals:  map[string]int64{},
+	}
+}
+
+func (r *progressRecorder) progress(path string, transferred, total int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records[path] = append(r.records[path], transferred)
+	r.totals[path] = total
+}
+
+func (r *progressRecorder) check(t *testing.T, files map[string]int64) {
+	t.Helper()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.records) != len(files) {
+		t.Errorf("progress reported for %v files, want %v", len(r.records), len(files))
+	}
+	for path, size := range files {
+		records := r.records[path]
+		if len(records) == 0 {
+			t.Errorf("no progress reported for %v", path)
+			continue
+		}
+		for i := 1; i < len(records); i++ {
+			if records[i] <= records[i-1] {
+				t.Errorf("progress of %v is not increasing: %v", path, records)
+				break
+			}
+		}
+		if last := records[len(records)-1]; last != size {
+			t.Errorf("progress of %v ends with %v, want %v", path, last, size)
+		}
+		if r.totals[path] != size {
+			t.Errorf("total of %v = %v, want %v", path, r.totals[path], size)
+		}
+	}
+}
+
+func writeTestFiles(t *testing.T, dir string, files map[string][]byte) {
+	t.Helper()
+	for name, content := range files {
+		fpath := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(fpath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fpath, content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSCP_WithProgress(t *testing.T) {
+	_, client := newTestClient(t)
+
+	local := t.TempDir()
+	remote := t.TempDir()
+	files := map[string][]byte{
+		"large":       bytes.Repeat([]byte("0123456789"), 100*1024),
+		"empty":       {},
+		"dir/small":   []byte("hello"),
+		"dir/sub/big": bytes.Repeat([]byte("a"), 64*1024),
+	}
+	writeTestFiles(t, local, files)
+
+	recorder := newProgressRecorder()
+	s := New(client, afero.NewOsFs(), nil, WithProgress(recorder.progress))
+	if err := s.Upload(context.Background(), local, filepath.Join(remote, "upload")); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	want := map[string]int64{}
+	for name, content := range files {
+		want[filepath.Join(local, name)] = int64(len(content))
+		got, err := os.ReadFile(filepath.Join(remote, "upload", name))
+		if err != nil || !bytes.Equal(got, content) {
+			t.Errorf("uploaded file %v is not the same, error = %v", name, err)
+		}
+	}
+	recorder.check(t, want)
+
+	recorder = newProgressRecorder()
+	s = New(client, afero.NewOsFs(), nil, WithProgress(recorder.progress))
+	download := filepath.Join(t.TempDir(), "download")
+	if err := s.Download(context.Background(), filepath.Join(remote, "upload"), download); err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+	want = map[string]int64{}
+	for name, content := range files {
+		want[filepath.Join(remote, "upload", name)] = int64(len(content))
+		got, err := os.ReadFile(filepath.Join(download, name))
+		if err != nil || !bytes.Equal(got, content) {
+			t.Errorf("downloaded file %v is not the same, error = %v", name, err)
+		}
+	}
+	recorder.check(t, want)
+}
+
+func TestSCP_Remove(t *testing.T) {
+	_, client := newTestClient(t)
+	s := New(client, afero.NewOsFs(), nil)
+	ctx := context.Background()
+
+	remote := t.TempDir()
+	writeTestFiles(t, remote, map[string][]byte{
+		"file":              []byte("file"),
+		"it's a file":       []byte("quoted"),
+		"dir/file":          []byte("file"),
+		"dir/sub/file":      []byte("file"),
+		"nonempty/sub/file": []byte("file"),
+	})
+	if err := os.Mkdir(filepath.Join(remote, "empty"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(remote, "nonempty"), filepath.Join(remote, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(remote, "readonly"), []byte("file"), 0400); err != nil {
+		t.Fatal(err)
+	}
+
+	exists := func(name string) bool {
+		_, err := os.Lstat(filepath.Join(remote, name))
+		return err == nil
+	}
+
+	tests := []struct {
+		name      string
+		removeAll bool
+		wantErr   bool
+	}{
+		{"file", false, false},
+		{"it's a file", false, false},
+		{"empty", false, false},
+		{"readonly", false, false},
+		{"link", false, false},
+		{"nonempty", false, true},
+		{"notexist", false, true},
+		{"dir", true, false},
+		{"notexist", true, false},
+	}
+	for _, tt := range tests {
+		remove := s.Remove
+		if tt.removeAll {
+			remove = s.RemoveAll
+		}
+		err := remove(ctx, filepath.Join(remote, tt.name))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("remove %v (removeAll=%v) error = %v, wantErr %v", tt.name, tt.removeAll, err, tt.wantErr)
+		}
+		if !tt.wantErr && exists(tt.name) {
+			t.Errorf("remove %v (removeAll=%v) does not remove it", tt.name, tt.removeAll)
+		}
+	}
+	if !exists("nonempty/sub/file") {
+		t.Errorf("Remove() should not remove non-empty directory")
+	}
+
+	for _, p := range []string{"/", "//", "", "."} {
+		if err := s.RemoveAll(ctx, p); err == nil {
+			t.Errorf("RemoveAll(%q) want error", p)
+		}
+	}
+}
+
+func TestSCP_WithReadTimeout(t *testing.T) {
+	server, client := newTestClient(t)
+
+	remote := t.TempDir()
+	writeTestFiles(t, remote, map[string][]byte{"file": []byte("hello")})
+
+	// fast peer
+	s := New(client, afero.NewOsFs(), nil, WithReadTimeout(5*time.Second))
+	info, err := s.Stat(filepath.Join(remote, "file"))
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	if info.Size() != 5 {
+		t.Errorf("Stat() size = %v, want 5", info.Size())
+	}
+
+	// no timeout
+	s = New(client, afero.NewOsFs(), nil)
+	if _, err := s.Stat(filepath.Join(remote, "file")); err != nil {
+		t.Fatalf("Stat() without timeout error = %v", err)
+	}
+
+	// slow peer
+	server.ExecDelay = 500 * time.Millisecond
+	s = New(client, afero.NewOsFs(), nil, WithReadTimeout(50*time.Millisecond))
+	_, err = s.Stat(filepath.Join(remote, "file"))
+	if err == nil || !strings.Contains(err.Error(), "exceeded timeout") {
+		t.Errorf("Stat() error = %v, want timeout error", err)
+	}
+}
+
+func TestSCP_FollowSymlinks(t *testing.T) {
+	_, client := newTestClient(t)
+
+	local := t.TempDir()
+	writeTestFiles(t, local, map[string][]byte{
+		"file":     []byte("hello"),
+		"sub/file": []byte("world"),
+	})
+	for link, target := range map[string]string{
+		"link":     "file",
+		"dirlink":  "sub",
+		"sub/link": "../file",
+	} {
+		if err := os.Symlink(target, filepath.Join(local, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("follow", func(t *testing.T) {
+		remote := filepath.Join(t.TempDir(), "upload")
+		s := New(client, afero.NewOsFs(), nil, FollowSymlinks(true))
+		if err := s.Upload(context.Background(), local, remote); err != nil {
+			t.Fatalf("Upload() error = %v", err)
+		}
+		for link, want := range map[string]string{"link": "hello", "sub/link": "hello"} {
+			info, err := os.Lstat(filepath.Join(remote, link))
+			if err != nil {
+				t.Fatalf("Lstat() error = %v", err)
+			}
+			if !info.Mode().IsRegular() {
+				t.Errorf("%v should be copied as a regular file, got mode %v", link, info.Mode())
+			}
+			if got, _ := os.ReadFile(filepath.Join(remote, link)); string(got) != want {
+				t.Errorf("%v content = %q, want %q", link, got, want)
+			}
+		}
+		// dir behind symlink is ignored
+		if _, err := os.Lstat(filepath.Join(remote, "dirlink")); !os.IsNotExist(err) {
+			t.Errorf("dir behind symlink should be ignored, got error %v", err)
+		}
+	})
+
+	t.Run("preserve", func(t *testing.T) {
+		remote := filepath.Join(t.TempDir(), "upload")
+		s := New(client, afero.NewOsFs(), nil, FollowSymlinks(false))
+		if err := s.Upload(context.Background(), local, remote); err != nil {
+			t.Fatalf("Upload() error = %v", err)
+		}
+		for link, want := range map[string]string{"link": "file", "dirlink": "sub", "sub/link": "../file"} {
+			got, err := os.Readlink(filepath.Join(remote, link))
+			if err != nil {
+				t.Errorf("%v should be a symlink, error = %v", link, err)
+				continue
+			}
+			if got != want {
+				t.Errorf("%v target = %v, want %v", link, got, want)
+			}
+		}
+		if got, _ := os.ReadFile(filepath.Join(remote, "sub/file")); string(got) != "world" {
+			t.Errorf("sub/file content = %q, want %q", got, "world")
+		}
+	})
+}
+
+func TestSCP_WithSessionGuard(t *testing.T) {
+	_, client := newTestClient(t)
+
+	local := t.TempDir()
+	writeTestFiles(t, local, map[string][]byte{
+		"file": []byte("hello"),
+	})
+	if err := os.Symlink("file", filepath.Join(local, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	guard := &countingGuard{TokenBucket: maxinflight.New(1)}
+	s := New(client, afero.NewOsFs(), nil, FollowSymlinks(false), WithSessionGuard(guard))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	// the parent of remote does not exist, Upload opens sessions to stat,
+	// mkdir, transfer and create the symlink, they must not overlap with
+	// only one token
+	remote := filepath.Join(t.TempDir(), "parent", "upload")
+	if err := s.Upload(ctx, local, remote); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	if err := s.Download(ctx, remote, filepath.Join(t.TempDir(), "download")); err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+	if got, err := os.Readlink(filepath.Join(remote, "link")); err != nil || got != "file" {
+		t.Errorf("link target = %v, %v, want %v", got, err, "file")
+	}
+	if got := guard.InUse(); got != 0 {
+		t.Errorf("guard tokens in use = %v, want 0", got)
+	}
+	if got := guard.acquired; got < 4 {
+		t.Errorf("guard acquired %v times, want at least 4", got)
+	}
+}
+
+// countingGuard counts the acquired tokens
+type countingGuard struct {
+	maxinflight.TokenBucket
+	acquired int
+}
+
+func (g *countingGuard) AcquireContext(ctx context.Context) error {
+	err := g.TokenBucket.AcquireContext(ctx)
+	if err == nil {
+		g.acquired++
+	}
+	return err
+}
+
+func TestSCP_Readdir(t *testing.T) {
+	_, client := newTestClient(t)
+	s := New(client, afero.NewOsFs(), nil)
+
+	remote := t.TempDir()
+	writeTestFiles(t, remote, map[string][]byte{
+		"a":           []byte("a"),
+		"b":           []byte("bb"),
+		"dir1/c":      []byte("c"),
+		"dir1/sub/d":  []byte("d"),
+		"dir2/e":      []byte("e"),
+		"dir2/sub2/f": []byte("f"),
+	})
+	if err := os.Mkdir(filepath.Join(remote, "empty"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Unix(1600000000, 123456789)
+	if err := os.Chtimes(filepath.Join(remote, "b"), mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(filepath.Join(remote, "b"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	infos, err := s.Readdir(remote)
+	if err != nil {
+		t.Fatalf("Readdir() error = %v", err)
+	}
+	got := map[string]bool{}
+	for _, info := range infos {
+		got[info.Name()] = info.IsDir()
+	}
+	want := map[string]bool{
+		"a":     false,
+		"b":     false,
+		"dir1":  true,
+		"dir2":  true,
+		"empty": true,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Readdir() = %v, want %v", got, want)
+	}
+	for _, info := range infos {
+		if info.Name() != "b" {
+			continue
+		}
+		if info.Size() != 2 {
+			t.Errorf("Readdir() size of b = %v, want 2", info.Size())
+		}
+		if !info.ModTime().Equal(mtime) {
+			t.Errorf("Readdir() mtime of b = %v, want %v", info.ModTime(), mtime)
+		}
+		if info.Mode() != 0600 {
+			t.Errorf("Readdir() mode of b = %v, want %v", info.Mode(), os.FileMode(0600))
+		}
+	}
+
+	if _, err := s.Readdir(filepath.Join(remote, "a")); err == nil {
+		t.Errorf("Readdir() on a file want error")
+	}
+	if _, err := s.Readdir(filepath.Join(remote, "notexist")); err == nil {
+		t.Errorf("Readdir() on a non-existent path want error")
+	}
+}
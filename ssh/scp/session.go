@@ -21,6 +21,7 @@ import (
 	"os"
 	"path"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-logr/logr"
@@ -41,24 +42,25 @@ const (
 	scpRead
 )
 
-func newSession(client *ssh.Client, mode sessionMode, readTimeout time.Duration, logger logr.Logger) (*session, error) {
+func newSession(s *ssh.Session, release func(), mode sessionMode, readTimeout time.Duration, logger logr.Logger) *session {
 	if logger == nil {
 		logger = logr.Discard()
 	}
-	s, err := client.NewSession()
-	if err != nil {
-		return nil, err
-	}
 	return &session{
 		Session:     s,
+		release:     release,
 		readTimeout: readTimeout,
 		mode:        mode,
 		logger:      logger,
-	}, err
+	}
 }
 
 type session struct {
 	*ssh.Session
+	// release gives back the session to the session guard
+	release   func()
+	closeOnce sync.Once
+	started   bool
 
 	reader      *reader
 	writer      io.WriteCloser
@@ -69,8 +71,19 @@ type session struct {
 }
 
 func (s *session) Close() error {
-	s.reader.close()
-	return s.Session.Close()
+	var err error
+	s.closeOnce.Do(func() {
+		if s.reader != nil {
+			s.reader.close()
+		}
+		err = s.Session.Close()
+		if s.started {
+			// wait for the remote to close the session before giving it back
+			s.Session.Wait() //nolint:errcheck
+		}
+		s.release()
+	})
+	return err
 }
 
 func (s *session) scpCmd(location string) string {
@@ -109,6 +122,7 @@ func (s *session) init(location string) error {
 	if err != nil {
 		return err
 	}
+	s.started = true
 
 	go s.reader.readInBackground()
 	return nil
@@ -67,3 +67,58 @@ func ParseTimeResponse(response string) (*time.Time, error) {
 	ts := time.Unix(timestamp, nsec*1000)
 	return &ts, nil
 }
+
+// parseFindRecord parses a record printed by find -printf '%y %m %s %T@ %f'
+func parseFindRecord(record string) (os.FileInfo, error) {
+	elements := strings.SplitN(record, " ", 5)
+	if len(elements) != 5 {
+		return nil, fmt.Errorf("invalid find record: %q", record)
+	}
+	perm, err := strconv.ParseUint(elements[1], 8, 32)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid file mode: %v", elements[1])
+	}
+	size, err := strconv.ParseInt(elements[2], 10, 64)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid file size: %v", elements[2])
+	}
+	modified, err := parseUnixTime(elements[3])
+	if err != nil {
+		return nil, err
+	}
+	mode := os.FileMode(perm)
+	isDir := false
+	switch elements[0] {
+	case "d":
+		mode |= os.ModeDir
+		isDir = true
+	case "l":
+		mode |= os.ModeSymlink
+	}
+	return fileinfo.NewInfo(elements[4], size, mode, modified, isDir), nil
+}
+
+// parseUnixTime parses the seconds since epoch with an optional fraction,
+// e.g. 1690000000.1234567890
+func parseUnixTime(s string) (time.Time, error) {
+	secStr, fracStr := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		secStr, fracStr = s[:i], s[i+1:]
+	}
+	sec, err := strconv.ParseInt(secStr, 10, 64)
+	if err != nil {
+		return time.Time{}, errors.Wrapf(err, "invalid timestamp: %v", s)
+	}
+	var nsec int64
+	if fracStr != "" {
+		if len(fracStr) > 9 {
+			fracStr = fracStr[:9]
+		}
+		fracStr += strings.Repeat("0", 9-len(fracStr))
+		nsec, err = strconv.ParseInt(fracStr, 10, 64)
+		if err != nil {
+			return time.Time{}, errors.Wrapf(err, "invalid timestamp: %v", s)
+		}
+	}
+	return time.Unix(sec, nsec), nil
+}
@@ -26,19 +26,44 @@ import (
 	"golang.org/x/term"
 )
 
+// DefaultTerminalModes are the terminal modes requested for the PTY by default
+var DefaultTerminalModes = ssh.TerminalModes{
+	ssh.ECHO:          1,
+	ssh.TTY_OP_ISPEED: 14400,
+	ssh.TTY_OP_OSPEED: 14400,
+}
+
 type Shell struct {
 	client *ssh.Client
 
+	terminalModes ssh.TerminalModes
+
 	stdin  io.Reader
 	stdout io.Writer
 	stderr io.Writer
 }
 
+// Option configures the Shell
+type Option func(*Shell)
+
+// WithTerminalModes sets the terminal modes requested for the PTY,
+// defaults to DefaultTerminalModes.
+func WithTerminalModes(modes ssh.TerminalModes) Option {
+	return func(s *Shell) {
+		s.terminalModes = modes
+	}
+}
+
 // New returns a new Shell
-func New(c *ssh.Client) *Shell {
-	return &Shell{
-		client: c,
+func New(c *ssh.Client, opts ...Option) *Shell {
+	s := &Shell{
+		client:        c,
+		terminalModes: DefaultTerminalModes,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 func (s *Shell) Run(stdin io.Reader, stdout, stderr io.Writer) error {
@@ -65,20 +90,24 @@ func (s *Shell) runShell(session *ssh.Session) error {
 
 	termWidth, termHeight, err := term.GetSize(fd)
 	if err != nil {
-		return err
-	}
-
-	termType := os.Getenv("TERM")
-	if termType == "" {
-		termType = "xterm-256color"
+		return errors.Wrap(err, "failed to get terminal size")
 	}
 
-	err = session.RequestPty(termType, termHeight, termWidth, ssh.TerminalModes{})
+	err = s.requestPty(session, termHeight, termWidth)
 	if err != nil {
 		return err
 	}
 
-	go s.syncWindowChange(fd, session)
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		s.syncWindowChange(fd, session, termWidth, termHeight, done)
+	}()
+	defer func() {
+		close(done)
+		<-exited
+	}()
 
 	session.Stdin = s.stdin
 	session.Stdout = s.stdout
@@ -95,25 +124,39 @@ func (s *Shell) runShell(session *ssh.Session) error {
 	return nil
 }
 
-func (s *Shell) syncWindowChange(fd int, session *ssh.Session) {
+func (s *Shell) requestPty(session *ssh.Session, height, width int) error {
+	termType := os.Getenv("TERM")
+	if termType == "" {
+		termType = "xterm-256color"
+	}
+	return session.RequestPty(termType, height, width, s.terminalModes)
+}
+
+type windowChanger interface {
+	WindowChange(h, w int) error
+}
+
+// syncWindowChange sends window-change requests to the session when the
+// local terminal is resized, until done is closed or the session is closed.
+func (s *Shell) syncWindowChange(fd int, session windowChanger, width, height int, done <-chan struct{}) {
 	signalCh := make(chan os.Signal, 1)
 	signal.Notify(signalCh, syscall.SIGWINCH)
-	width, height, err := term.GetSize(fd)
-	if err != nil {
-		// TODO:
-		return
-	}
+	defer signal.Stop(signalCh)
 
-	for sig := range signalCh {
-		if sig == nil {
+	for {
+		select {
+		case <-done:
 			return
+		case <-signalCh:
+		}
+		curWidth, curHeight, err := term.GetSize(fd)
+		if err != nil {
+			continue
 		}
-		curWidth, curHeight, _ := term.GetSize(fd)
-
 		if curWidth == width && curHeight == height {
 			continue
 		}
-		err := session.WindowChange(curHeight, curWidth)
+		err = session.WindowChange(curHeight, curWidth)
 		if err != nil {
 			// closed
 			return
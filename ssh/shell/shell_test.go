@@ -0,0 +1,111 @@
+// Copyright 2023 jim.zoumo@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package shell
+
+import (
+	"os"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/zoumo/golib/ssh/internal/sshtest"
+)
+
+func newTestClient(t *testing.T) (*sshtest.Server, *ssh.Client) {
+	t.Helper()
+	server, err := sshtest.NewServer()
+	if err != nil {
+		t.Fatalf("failed to start ssh server: %v", err)
+	}
+	t.Cleanup(func() { server.Close() })
+	client, err := server.Dial()
+	if err != nil {
+		t.Fatalf("failed to dial ssh server: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return server, client
+}
+
+func TestShell_requestPty(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want ssh.TerminalModes
+	}{
+		{"default", nil, DefaultTerminalModes},
+		{
+			"custom",
+			[]Option{WithTerminalModes(ssh.TerminalModes{ssh.ECHO: 0, ssh.TTY_OP_ISPEED: 9600})},
+			ssh.TerminalModes{ssh.ECHO: 0, ssh.TTY_OP_ISPEED: 9600},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, client := newTestClient(t)
+			session, err := client.NewSession()
+			if err != nil {
+				t.Fatalf("NewSession() error = %v", err)
+			}
+			defer session.Close()
+
+			s := New(client, tt.opts...)
+			if err := s.requestPty(session, 40, 80); err != nil {
+				t.Fatalf("requestPty() error = %v", err)
+			}
+			reqs := server.PtyRequests()
+			if len(reqs) != 1 {
+				t.Fatalf("server received %v pty requests, want 1", len(reqs))
+			}
+			if reqs[0].Rows != 40 || reqs[0].Columns != 80 {
+				t.Errorf("pty size = %vx%v, want 40x80", reqs[0].Rows, reqs[0].Columns)
+			}
+			if got := reqs[0].Modes(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("pty modes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+type fakeWindowChanger struct{}
+
+func (fakeWindowChanger) WindowChange(h, w int) error { return nil }
+
+func TestShell_syncWindowChange(t *testing.T) {
+	s := New(nil)
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		s.syncWindowChange(int(os.Stdin.Fd()), fakeWindowChanger{}, 80, 40, done)
+	}()
+
+	// the resize can not be read from a non-terminal, it must be ignored
+	syscall.Kill(os.Getpid(), syscall.SIGWINCH) //nolint
+	select {
+	case <-exited:
+		t.Fatal("syncWindowChange() exited before done is closed")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(done)
+	select {
+	case <-exited:
+	case <-time.After(time.Second):
+		t.Fatal("syncWindowChange() did not exit after done is closed")
+	}
+}
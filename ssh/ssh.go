@@ -27,6 +27,11 @@ import (
 	"github.com/zoumo/golib/ssh/shell"
 )
 
+// SetClientConfigDefaults fills the unset fields of cfg.
+//
+// The default HostKeyCallback is ssh.InsecureIgnoreHostKey, which accepts any
+// host key and leaves the connection open to man-in-the-middle attacks. Set
+// it to KnownHostsCallback or FixedHostKey to verify the server.
 func SetClientConfigDefaults(cfg *ssh.ClientConfig) {
 	cfg.SetDefaults()
 	if cfg.HostKeyCallback == nil {
@@ -42,11 +47,35 @@ func SetClientConfigDefaults(cfg *ssh.ClientConfig) {
 	}
 }
 
-func DialTCP(host, port string, cfg *ssh.ClientConfig) (*Client, error) {
-	return Dial("tcp", host, port, cfg)
+// defaultKeepAliveMaxFailures is used if KeepAliveInterval is set without
+// KeepAliveMaxFailures
+const defaultKeepAliveMaxFailures = 3
+
+// Option configures the Client
+type Option func(*Client)
+
+// KeepAliveInterval makes the client send a keepalive@openssh.com request
+// every interval, it is disabled if interval is not greater than 0.
+// A request not replied within the interval is a failure.
+func KeepAliveInterval(interval time.Duration) Option {
+	return func(c *Client) {
+		c.keepAliveInterval = interval
+	}
+}
+
+// KeepAliveMaxFailures sets the number of consecutive keepalive failures
+// after which the client is closed, defaults to 3.
+func KeepAliveMaxFailures(n int) Option {
+	return func(c *Client) {
+		c.keepAliveMaxFailures = n
+	}
+}
+
+func DialTCP(host, port string, cfg *ssh.ClientConfig, opts ...Option) (*Client, error) {
+	return Dial("tcp", host, port, cfg, opts...)
 }
 
-func Dial(network, host, port string, cfg *ssh.ClientConfig) (*Client, error) {
+func Dial(network, host, port string, cfg *ssh.ClientConfig, opts ...Option) (*Client, error) {
 	SetClientConfigDefaults(cfg)
 
 	c, err := ssh.Dial(network, net.JoinHostPort(host, port), cfg)
@@ -54,14 +83,82 @@ func Dial(network, host, port string, cfg *ssh.ClientConfig) (*Client, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &Client{c}, err
+	return newClient(c, opts...), err
 }
 
 type Client struct {
 	*ssh.Client
+
+	keepAliveInterval    time.Duration
+	keepAliveMaxFailures int
+}
+
+func newClient(c *ssh.Client, opts ...Option) *Client {
+	client := &Client{
+		Client: c,
+	}
+	for _, opt := range opts {
+		opt(client)
+	}
+	if client.keepAliveInterval > 0 {
+		if client.keepAliveMaxFailures <= 0 {
+			client.keepAliveMaxFailures = defaultKeepAliveMaxFailures
+		}
+		go client.keepAlive()
+	}
+	return client
 }
 
-func (c *Client) Dial(network, host, port string, cfg *ssh.ClientConfig) (*Client, error) {
+// keepAlive sends keepalive requests until the client is closed, and closes
+// the client after keepAliveMaxFailures consecutive failures.
+func (c *Client) keepAlive() {
+	closed := make(chan struct{})
+	go func() {
+		c.Wait() //nolint
+		close(closed)
+	}()
+
+	ticker := time.NewTicker(c.keepAliveInterval)
+	defer ticker.Stop()
+
+	failures := 0
+	for {
+		select {
+		case <-closed:
+			return
+		case <-ticker.C:
+		}
+
+		replied := make(chan error, 1)
+		go func() {
+			// the reply is always false for OpenSSH, any reply means alive
+			_, _, err := c.SendRequest("keepalive@openssh.com", true, nil)
+			replied <- err
+		}()
+
+		timer := time.NewTimer(c.keepAliveInterval)
+		select {
+		case <-closed:
+			timer.Stop()
+			return
+		case err := <-replied:
+			timer.Stop()
+			if err == nil {
+				failures = 0
+				continue
+			}
+			failures++
+		case <-timer.C:
+			failures++
+		}
+		if failures >= c.keepAliveMaxFailures {
+			c.Close()
+			return
+		}
+	}
+}
+
+func (c *Client) Dial(network, host, port string, cfg *ssh.ClientConfig, opts ...Option) (*Client, error) {
 	SetClientConfigDefaults(cfg)
 
 	conn, err := c.Client.Dial(network, net.JoinHostPort(host, port))
@@ -73,7 +170,7 @@ func (c *Client) Dial(network, host, port string, cfg *ssh.ClientConfig) (*Clien
 		return nil, err
 	}
 	client := ssh.NewClient(ncc, chans, reqs)
-	return &Client{client}, nil
+	return newClient(client, opts...), nil
 }
 
 func (c *Client) Shell(stdin io.Reader, stdout, stderr io.Writer) error {
@@ -81,6 +178,27 @@ func (c *Client) Shell(stdin io.Reader, stdout, stderr io.Writer) error {
 	return shell.Run(stdin, stdout, stderr)
 }
 
+// Exec runs cmd in a new session without a PTY and returns its standard output.
+func (c *Client) Exec(cmd string) ([]byte, error) {
+	session, err := c.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+	return session.Output(cmd)
+}
+
+// ExecCombined runs cmd in a new session without a PTY and returns its
+// combined standard output and standard error.
+func (c *Client) ExecCombined(cmd string) ([]byte, error) {
+	session, err := c.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+	return session.CombinedOutput(cmd)
+}
+
 func (c *Client) Upload(ctx context.Context, local, remote string) error {
 	scp := scp.New(c.Client, afero.NewOsFs(), nil)
 	return scp.Upload(ctx, local, remote)
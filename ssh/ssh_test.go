@@ -0,0 +1,132 @@
+// Copyright 2023 jim.zoumo@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zoumo/golib/ssh/internal/sshtest"
+)
+
+func newTestClient(t *testing.T) (*sshtest.Server, *Client) {
+	t.Helper()
+	server, err := sshtest.NewServer()
+	if err != nil {
+		t.Fatalf("failed to start ssh server: %v", err)
+	}
+	t.Cleanup(func() { server.Close() })
+	host, port := server.Host()
+	client, err := DialTCP(host, port, server.ClientConfig())
+	if err != nil {
+		t.Fatalf("failed to dial ssh server: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return server, client
+}
+
+func TestClient_Exec(t *testing.T) {
+	tests := []struct {
+		name     string
+		cmd      string
+		combined bool
+		want     string
+		wantErr  bool
+	}{
+		{"stdout", "echo hello", false, "hello\n", false},
+		{"stdout ignores stderr", "echo hello; echo world >&2", false, "hello\n", false},
+		{"combined", "echo hello >&2", true, "hello\n", false},
+		{"exit status", "echo hello; exit 1", false, "hello\n", true},
+		{"combined exit status", "echo hello; exit 1", true, "hello\n", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, client := newTestClient(t)
+			exec := client.Exec
+			if tt.combined {
+				exec = client.ExecCombined
+			}
+			got, err := exec(tt.cmd)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Exec() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Exec() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDial_keepAlive(t *testing.T) {
+	server, err := sshtest.NewServer()
+	if err != nil {
+		t.Fatalf("failed to start ssh server: %v", err)
+	}
+	defer server.Close()
+	host, port := server.Host()
+
+	client, err := DialTCP(host, port, server.ClientConfig(), KeepAliveInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("DialTCP() error = %v", err)
+	}
+	defer client.Close()
+
+	deadline := time.Now().Add(time.Second)
+	for count := 0; count < 3; {
+		if time.Now().After(deadline) {
+			t.Fatalf("server received %v keepalive requests, want at least 3", count)
+		}
+		time.Sleep(10 * time.Millisecond)
+		count = 0
+		for _, req := range server.GlobalRequests() {
+			if req == "keepalive@openssh.com" {
+				count++
+			}
+		}
+	}
+	if _, err := client.Exec("true"); err != nil {
+		t.Errorf("client with keepalive Exec() error = %v", err)
+	}
+}
+
+func TestDial_keepAliveFailures(t *testing.T) {
+	server, err := sshtest.NewServer()
+	if err != nil {
+		t.Fatalf("failed to start ssh server: %v", err)
+	}
+	defer server.Close()
+	server.IgnoreGlobalRequests = true
+	host, port := server.Host()
+
+	client, err := DialTCP(host, port, server.ClientConfig(),
+		KeepAliveInterval(10*time.Millisecond),
+		KeepAliveMaxFailures(2),
+	)
+	if err != nil {
+		t.Fatalf("DialTCP() error = %v", err)
+	}
+	defer client.Close()
+
+	closed := make(chan struct{})
+	go func() {
+		client.Wait() //nolint
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("client is not closed after missing keepalive replies")
+	}
+}
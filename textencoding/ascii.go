@@ -0,0 +1,204 @@
+// Copyright 2023 jim.zoumo@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package textencoding
+
+import (
+	"bytes"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// asciiReplacements maps the characters which can not be decomposed to ASCII
+// by NFKD to their closest ASCII representation.
+var asciiReplacements = map[rune]string{
+	'Æ': "AE", 'æ': "ae",
+	'Œ': "OE", 'œ': "oe",
+	'Ø': "O", 'ø': "o",
+	'Đ': "D", 'đ': "d",
+	'Ð': "D", 'ð': "d",
+	'Ł': "L", 'ł': "l",
+	'Þ': "TH", 'þ': "th",
+	'ß': "ss", 'ı': "i",
+	'‘': "'", '’': "'", '‚': "'",
+	'“': "\"", '”': "\"", '„': "\"",
+	'«': "<<", '»': ">>",
+	'–': "-", '—': "-", '−': "-",
+	// CJK punctuation, the fullwidth forms are handled by NFKD
+	'、': ",", '。': ".",
+	'「': "\"", '」': "\"", '『': "\"", '』': "\"",
+	'【': "[", '】': "]", '《': "<<", '》': ">>",
+}
+
+// kanaRomaji maps hiragana to Hepburn romanization, katakana are mapped by
+// their hiragana counterparts. The small ya, yu, yo and tsu are handled by
+// ToASCII with the previous and the next kana.
+var kanaRomaji = map[rune]string{
+	'ぁ': "a", 'あ': "a", 'ぃ': "i", 'い': "i", 'ぅ': "u", 'う': "u", 'ぇ': "e", 'え': "e", 'ぉ': "o", 'お': "o",
+	'か': "ka", 'が': "ga", 'き': "ki", 'ぎ': "gi", 'く': "ku", 'ぐ': "gu", 'け': "ke", 'げ': "ge", 'こ': "ko", 'ご': "go",
+	'さ': "sa", 'ざ': "za", 'し': "shi", 'じ': "ji", 'す': "su", 'ず': "zu", 'せ': "se", 'ぜ': "ze", 'そ': "so", 'ぞ': "zo",
+	'た': "ta", 'だ': "da", 'ち': "chi", 'ぢ': "ji", 'つ': "tsu", 'づ': "zu", 'て': "te", 'で': "de", 'と': "to", 'ど': "do",
+	'な': "na", 'に': "ni", 'ぬ': "nu", 'ね': "ne", 'の': "no",
+	'は': "ha", 'ば': "ba", 'ぱ': "pa", 'ひ': "hi", 'び': "bi", 'ぴ': "pi", 'ふ': "fu", 'ぶ': "bu", 'ぷ': "pu",
+	'へ': "he", 'べ': "be", 'ぺ': "pe", 'ほ': "ho", 'ぼ': "bo", 'ぽ': "po",
+	'ま': "ma", 'み': "mi", 'む': "mu", 'め': "me", 'も': "mo",
+	'ゃ': "ya", 'や': "ya", 'ゅ': "yu", 'ゆ': "yu", 'ょ': "yo", 'よ': "yo",
+	'ら': "ra", 'り': "ri", 'る': "ru", 'れ': "re", 'ろ': "ro",
+	'ゎ': "wa", 'わ': "wa", 'ゐ': "i", 'ゑ': "e", 'を': "o", 'ん': "n",
+	'ゔ': "vu", 'ゕ': "ka", 'ゖ': "ke",
+}
+
+// hangul jamo in the simplified Revised Romanization, without the sound
+// change rules between syllables
+var (
+	hangulInitials = []string{"g", "kk", "n", "d", "tt", "r", "m", "b", "pp", "s", "ss", "", "j", "jj", "ch", "k", "t", "p", "h"}
+	hangulMedials  = []string{"a", "ae", "ya", "yae", "eo", "e", "yeo", "ye", "o", "wa", "wae", "oe", "yo", "u", "wo", "we", "wi", "yu", "eu", "ui", "i"}
+	hangulFinals   = []string{"", "k", "k", "k", "n", "n", "n", "t", "l", "l", "l", "l", "l", "l", "l", "l", "m", "p", "p", "t", "t", "ng", "t", "t", "k", "t", "p", "t"}
+)
+
+const (
+	hangulBase  = 0xAC00
+	hangulLast  = 0xD7A3
+	hangulFinal = 28
+	hangulBlock = 21 * hangulFinal
+
+	katakanaFirst  = 0x30A1
+	katakanaLast   = 0x30F6
+	katakanaOffset = 0x60
+	kanaSokuon     = 'っ'
+	kanaLongVowel  = 'ー'
+)
+
+// ToASCII transliterates s to ASCII. Accented characters are decomposed and
+// their combining marks are removed, compatibility characters such as
+// fullwidth forms and ligatures are replaced by their ASCII equivalents, and
+// some common letters and punctuation are mapped to the closest ASCII
+// representation.
+//
+// Kana are romanized by Hepburn and Hangul syllables by the Revised
+// Romanization of Korean, both syllable by syllable. CJK ideographs can not
+// be romanized without a dictionary, they and any other non-ASCII characters
+// which can not be transliterated are replaced by '?'.
+//
+// Pure ASCII strings are returned as is.
+func ToASCII(s string) string {
+	if isASCII(s) {
+		return s
+	}
+	b := make([]byte, 0, len(s))
+	// lastKana tells whether the last written characters are from a kana
+	lastKana := false
+	sokuon := false
+	for _, r := range norm.NFC.String(s) {
+		if romaji, ok := romanizeKana(r); ok {
+			switch k := toHiragana(r); {
+			case k == kanaSokuon:
+				// double the consonant of the next kana
+				sokuon = true
+				lastKana = true
+				continue
+			case k == kanaLongVowel:
+				if lastKana && len(b) > 0 && isVowel(b[len(b)-1]) {
+					b = append(b, b[len(b)-1])
+				} else {
+					b = append(b, '-')
+				}
+			case (k == 'ゃ' || k == 'ゅ' || k == 'ょ') && lastKana && len(b) > 0 && b[len(b)-1] == 'i':
+				// contracted sound, e.g. kya, sha, cha, ja
+				b = b[:len(b)-1]
+				if bytes.HasSuffix(b, []byte("sh")) || bytes.HasSuffix(b, []byte("ch")) || bytes.HasSuffix(b, []byte("j")) {
+					romaji = romaji[1:]
+				}
+				b = append(b, romaji...)
+			default:
+				if sokuon {
+					if strings.HasPrefix(romaji, "ch") {
+						b = append(b, 't')
+					} else if !isVowel(romaji[0]) && romaji[0] != 'n' {
+						b = append(b, romaji[0])
+					}
+				}
+				b = append(b, romaji...)
+			}
+			sokuon = false
+			lastKana = true
+			continue
+		}
+		sokuon = false
+		lastKana = false
+
+		if r >= hangulBase && r <= hangulLast {
+			idx := int(r - hangulBase)
+			b = append(b, hangulInitials[idx/hangulBlock]...)
+			b = append(b, hangulMedials[idx%hangulBlock/hangulFinal]...)
+			b = append(b, hangulFinals[idx%hangulFinal]...)
+			continue
+		}
+		if r < utf8.RuneSelf {
+			b = append(b, byte(r))
+			continue
+		}
+		for _, d := range norm.NFKD.String(string(r)) {
+			switch {
+			case d < utf8.RuneSelf:
+				b = append(b, byte(d))
+			case unicode.Is(unicode.Mn, d):
+				// drop combining marks
+			default:
+				if repl, ok := asciiReplacements[d]; ok {
+					b = append(b, repl...)
+				} else {
+					b = append(b, '?')
+				}
+			}
+		}
+	}
+	return string(b)
+}
+
+// romanizeKana returns the romaji of hiragana or katakana r
+func romanizeKana(r rune) (string, bool) {
+	switch r = toHiragana(r); r {
+	case kanaLongVowel:
+		return "-", true
+	case kanaSokuon:
+		return "", true
+	}
+	romaji, ok := kanaRomaji[r]
+	return romaji, ok
+}
+
+// toHiragana maps katakana r to its hiragana counterpart
+func toHiragana(r rune) rune {
+	if r >= katakanaFirst && r <= katakanaLast {
+		return r - katakanaOffset
+	}
+	return r
+}
+
+func isVowel(c byte) bool {
+	return c == 'a' || c == 'e' || c == 'i' || c == 'o' || c == 'u'
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
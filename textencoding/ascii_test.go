@@ -0,0 +1,60 @@
+// Copyright 2023 jim.zoumo@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package textencoding
+
+import (
+	"testing"
+)
+
+func TestToASCII(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want string
+	}{
+		{"empty", "", ""},
+		{"ascii", "Hello, World!\n\t~", "Hello, World!\n\t~"},
+		{"accented", "Crème Brûlée à la café", "Creme Brulee a la cafe"},
+		{"combiningMarks", "élève", "eleve"},
+		{"special", "Straße Æsir Øresund Łódź œuvre", "Strasse AEsir Oresund Lodz oeuvre"},
+		{"ligature", "ﬁle", "file"},
+		{"fullwidth", "ＡＢＣ１２３", "ABC123"},
+		{"punctuation", "“quoted” – ‘single’", "\"quoted\" - 'single'"},
+		{"cjkPunctuation", "「你好」，世界。", "\"??\",??."},
+		{"hiragana", "ひらがな", "hiragana"},
+		{"katakana", "カタカナ", "katakana"},
+		{"voicedKana", "ぎんざ", "ginza"},
+		{"contractedKana", "とうきょう しゃしん ちゃ じゅう", "toukyou shashin cha juu"},
+		{"sokuon", "きって マッチ", "kitte matchi"},
+		{"longVowel", "コーヒー", "koohii"},
+		{"hangul", "한국어 서울", "hangukeo seoul"},
+		{"ideographs", "東京", "??"},
+		{"mixed", "日本語abcカナ", "???abckana"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ToASCII(tt.s)
+			if got != tt.want {
+				t.Errorf("ToASCII() = %q, want %q", got, tt.want)
+			}
+			if !isASCII(got) {
+				t.Errorf("ToASCII() = %q contains non-ASCII characters", got)
+			}
+			if again := ToASCII(got); again != got {
+				t.Errorf("ToASCII() is not idempotent, got %q then %q", got, again)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2023 jim.zoumo@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package textencoding
+
+import (
+	"fmt"
+
+	"golang.org/x/text/transform"
+)
+
+// ChunkDecoder decodes a stream which is split into chunks to UTF-8 bytes.
+// The incomplete multi-byte character at the end of a chunk is buffered
+// and decoded with the next chunk.
+//
+// ChunkDecoder is not safe for concurrent use.
+type ChunkDecoder struct {
+	decoder transform.Transformer
+	err     error
+	// pending keeps the bytes which are not decoded yet
+	pending []byte
+}
+
+// NewChunkDecoder creates a ChunkDecoder decoding from the given encoding.
+// If the encoding is not supported, Decode and Flush return an error.
+func NewChunkDecoder(from string) *ChunkDecoder {
+	d := &ChunkDecoder{}
+	enc, ok := lookupEncoding(from)
+	if !ok {
+		d.err = fmt.Errorf("unsupported from encoding %v", from)
+		return d
+	}
+	d.decoder = enc.NewDecoder()
+	return d
+}
+
+// Decode decodes the chunk with the bytes buffered by last call, the
+// trailing bytes of an incomplete character are buffered for next call.
+func (d *ChunkDecoder) Decode(chunk []byte) ([]byte, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	d.pending = append(d.pending, chunk...)
+	return d.transform(false)
+}
+
+// Flush decodes all buffered bytes and resets the decoder, it should be
+// called at the end of the stream.
+func (d *ChunkDecoder) Flush() ([]byte, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	defer d.decoder.Reset()
+	return d.transform(true)
+}
+
+func (d *ChunkDecoder) transform(atEOF bool) ([]byte, error) {
+	ret := []byte{}
+	src := d.pending
+	dst := make([]byte, 2*len(src)+16)
+	for {
+		nDst, nSrc, err := d.decoder.Transform(dst, src, atEOF)
+		ret = append(ret, dst[:nDst]...)
+		src = src[nSrc:]
+		switch {
+		case err == transform.ErrShortDst:
+			if nDst == 0 && nSrc == 0 {
+				// no progress, dst is too small to hold even one character
+				dst = make([]byte, 2*len(dst))
+			}
+			continue
+		case err == transform.ErrShortSrc && !atEOF:
+			// keep the incomplete character for next chunk
+			d.pending = append([]byte(nil), src...)
+			return ret, nil
+		case err != nil:
+			d.pending = nil
+			return ret, err
+		}
+		d.pending = nil
+		return ret, nil
+	}
+}
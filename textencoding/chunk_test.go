@@ -0,0 +1,91 @@
+// Copyright 2023 jim.zoumo@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package textencoding
+
+import (
+	"testing"
+)
+
+func TestChunkDecoder(t *testing.T) {
+	// "中文abc中" in GBK
+	input := []byte{0xD6, 0xD0, 0xCE, 0xC4, 'a', 'b', 'c', 0xD6, 0xD0}
+	want := "中文abc中"
+
+	for i := 0; i <= len(input); i++ {
+		d := NewChunkDecoder("gbk")
+		got := []byte{}
+		for _, chunk := range [][]byte{input[:i], input[i:]} {
+			out, err := d.Decode(chunk)
+			if err != nil {
+				t.Fatalf("ChunkDecoder.Decode() error = %v", err)
+			}
+			got = append(got, out...)
+		}
+		out, err := d.Flush()
+		if err != nil {
+			t.Fatalf("ChunkDecoder.Flush() error = %v", err)
+		}
+		got = append(got, out...)
+		if string(got) != want {
+			t.Errorf("split at %d, ChunkDecoder got = %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestChunkDecoder_ByteByByte(t *testing.T) {
+	// "中文" in GB18030 and UTF-16LE
+	tests := []struct {
+		name  string
+		from  string
+		input []byte
+		want  string
+	}{
+		{"gb18030", "gb18030", []byte{0xD6, 0xD0, 0xCE, 0xC4}, "中文"},
+		{"utf-16le", "utf-16le (ignore bom)", []byte{0x2D, 0x4E, 0x87, 0x65}, "中文"},
+		{"utf-8", "utf-8", []byte("中文"), "中文"},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewChunkDecoder(tt.from)
+			got := []byte{}
+			for j := range tt.input {
+				out, err := d.Decode(tt.input[j : j+1])
+				if err != nil {
+					t.Fatalf("ChunkDecoder.Decode() error = %v", err)
+				}
+				got = append(got, out...)
+			}
+			out, err := d.Flush()
+			if err != nil {
+				t.Fatalf("ChunkDecoder.Flush() error = %v", err)
+			}
+			got = append(got, out...)
+			if string(got) != tt.want {
+				t.Errorf("ChunkDecoder got = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChunkDecoder_Unsupported(t *testing.T) {
+	d := NewChunkDecoder("unknown")
+	if _, err := d.Decode([]byte("abc")); err == nil {
+		t.Errorf("ChunkDecoder.Decode() want error for unsupported encoding")
+	}
+	if _, err := d.Flush(); err == nil {
+		t.Errorf("ChunkDecoder.Flush() want error for unsupported encoding")
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2023 jim.zoumo@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package textencoding
+
+// LineEnding is the sequence of bytes which terminates a line
+type LineEnding string
+
+const (
+	// LineEndingUnknown means no line ending is found
+	LineEndingUnknown LineEnding = ""
+	// LineEndingLF is used on Unix-like systems
+	LineEndingLF LineEnding = "\n"
+	// LineEndingCRLF is used on Windows
+	LineEndingCRLF LineEnding = "\r\n"
+	// LineEndingCR is used on classic Mac OS
+	LineEndingCR LineEnding = "\r"
+)
+
+// DetectLineEnding returns the most frequent line ending in s. It returns
+// LineEndingUnknown if s does not contain any line ending.
+func DetectLineEnding(s []byte) LineEnding {
+	var lf, crlf, cr int
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\n':
+			lf++
+		case '\r':
+			if i+1 < len(s) && s[i+1] == '\n' {
+				crlf++
+				i++
+			} else {
+				cr++
+			}
+		}
+	}
+
+	switch {
+	case lf == 0 && crlf == 0 && cr == 0:
+		return LineEndingUnknown
+	case lf >= crlf && lf >= cr:
+		return LineEndingLF
+	case crlf >= cr:
+		return LineEndingCRLF
+	default:
+		return LineEndingCR
+	}
+}
+
+// NormalizeLineEndings converts all line endings in s to the given style.
+//
+// A lone CR is only treated as a line ending if CR is the detected line
+// ending of s, otherwise it is kept as is, so that embedded CR in LF or
+// CRLF text is not mangled. If s has no line ending or the style is
+// unknown, a copy of s is returned.
+func NormalizeLineEndings(s []byte, style LineEnding) []byte {
+	src := DetectLineEnding(s)
+	if src == LineEndingUnknown || !style.isValid() {
+		ret := make([]byte, len(s))
+		copy(ret, s)
+		return ret
+	}
+
+	crIsEnding := src == LineEndingCR
+	ret := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\n':
+			ret = append(ret, style...)
+		case '\r':
+			if i+1 < len(s) && s[i+1] == '\n' {
+				ret = append(ret, style...)
+				i++
+			} else if crIsEnding {
+				ret = append(ret, style...)
+			} else {
+				ret = append(ret, s[i])
+			}
+		default:
+			ret = append(ret, s[i])
+		}
+	}
+	return ret
+}
+
+func (l LineEnding) isValid() bool {
+	return l == LineEndingLF || l == LineEndingCRLF || l == LineEndingCR
+}
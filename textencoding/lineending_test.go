@@ -0,0 +1,74 @@
+// Copyright 2023 jim.zoumo@gmail.com
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package textencoding
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDetectLineEnding(t *testing.T) {
+	tests := []struct {
+		name string
+		s    []byte
+		want LineEnding
+	}{
+		{"empty", []byte(""), LineEndingUnknown},
+		{"noNewline", []byte("abc"), LineEndingUnknown},
+		{"lf", []byte("a\nb\n"), LineEndingLF},
+		{"crlf", []byte("a\r\nb\r\n"), LineEndingCRLF},
+		{"cr", []byte("a\rb\r"), LineEndingCR},
+		{"mixed", []byte("a\r\nb\r\nc\n"), LineEndingCRLF},
+		{"embeddedCR", []byte("a\rb\nc\n"), LineEndingLF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectLineEnding(tt.s); got != tt.want {
+				t.Errorf("DetectLineEnding() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeLineEndings(t *testing.T) {
+	tests := []struct {
+		name  string
+		s     []byte
+		style LineEnding
+		want  []byte
+	}{
+		{"lf -> lf", []byte("a\nb\n"), LineEndingLF, []byte("a\nb\n")},
+		{"lf -> crlf", []byte("a\nb\n"), LineEndingCRLF, []byte("a\r\nb\r\n")},
+		{"lf -> cr", []byte("a\nb\n"), LineEndingCR, []byte("a\rb\r")},
+		{"crlf -> lf", []byte("a\r\nb\r\n"), LineEndingLF, []byte("a\nb\n")},
+		{"crlf -> crlf", []byte("a\r\nb\r\n"), LineEndingCRLF, []byte("a\r\nb\r\n")},
+		{"crlf -> cr", []byte("a\r\nb\r\n"), LineEndingCR, []byte("a\rb\r")},
+		{"cr -> lf", []byte("a\rb\r"), LineEndingLF, []byte("a\nb\n")},
+		{"cr -> crlf", []byte("a\rb\r"), LineEndingCRLF, []byte("a\r\nb\r\n")},
+		{"cr -> cr", []byte("a\rb\r"), LineEndingCR, []byte("a\rb\r")},
+		{"mixed -> lf", []byte("a\r\nb\nc"), LineEndingLF, []byte("a\nb\nc")},
+		{"embeddedCR", []byte("a\rb\r\nc\r\n"), LineEndingLF, []byte("a\rb\nc\n")},
+		{"noNewline", []byte("a\x00b"), LineEndingCRLF, []byte("a\x00b")},
+		{"empty", []byte{}, LineEndingCRLF, []byte{}},
+		{"unknownStyle", []byte("a\nb"), LineEndingUnknown, []byte("a\nb")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NormalizeLineEndings(tt.s, tt.style); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NormalizeLineEndings() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -16,9 +16,12 @@ package textencoding
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"strings"
+	"sync"
+	"unicode/utf8"
 
 	"golang.org/x/text/encoding"
 	"golang.org/x/text/encoding/charmap"
@@ -32,10 +35,38 @@ import (
 )
 
 var (
-	all   = map[string]encoding.Encoding{}
-	alias = map[string]encoding.Encoding{
-		"UTF8":   unicode.UTF8,
-		"GB2312": simplifiedchinese.HZGB2312,
+	// allMu guards all
+	allMu sync.RWMutex
+	// all maps normalized names and aliases to encodings
+	all = map[string]encoding.Encoding{}
+	// alias maps aliases to canonical encoding names
+	alias = map[string]string{
+		"GB2312":  "HZ-GB2312",
+		"CP936":   "GBK",
+		"SJIS":    "Shift JIS",
+		"LATIN1":  "ISO 8859-1",
+		"LATIN2":  "ISO 8859-2",
+		"LATIN3":  "ISO 8859-3",
+		"LATIN4":  "ISO 8859-4",
+		"LATIN5":  "ISO 8859-9",
+		"LATIN6":  "ISO 8859-10",
+		"LATIN7":  "ISO 8859-13",
+		"LATIN8":  "ISO 8859-14",
+		"LATIN9":  "ISO 8859-15",
+		"LATIN10": "ISO 8859-16",
+		"CP437":   "IBM Code Page 437",
+		"CP850":   "IBM Code Page 850",
+		"CP866":   "IBM Code Page 866",
+		"CP1250":  "Windows 1250",
+		"CP1251":  "Windows 1251",
+		"CP1252":  "Windows 1252",
+		"MAC":     "Macintosh",
+		"UTF16":   "UTF-16BE (Use BOM)",
+		"UTF16BE": "UTF-16BE (Ignore BOM)",
+		"UTF16LE": "UTF-16LE (Ignore BOM)",
+		"UTF32":   "UTF-32BE (Use BOM)",
+		"UTF32BE": "UTF-32BE (Ignore BOM)",
+		"UTF32LE": "UTF-32LE (Ignore BOM)",
 	}
 )
 
@@ -61,12 +92,48 @@ func init() {
 		if !ok {
 			continue
 		}
-		all[strings.ToUpper(en.String())] = e
+		all[normalizeName(en.String())] = e
 	}
 
-	for k, e := range alias {
-		all[strings.ToUpper(k)] = e
+	for k, name := range alias {
+		if err := RegisterAlias(k, name); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// normalizeName upper-cases the encoding name and strips the separators,
+// so that "utf-8", "utf8" and "UTF_8" are the same.
+func normalizeName(name string) string {
+	return strings.ToUpper(strings.Map(func(r rune) rune {
+		switch r {
+		case '-', '_', ' ', '.':
+			return -1
+		}
+		return r
+	}, name))
+}
+
+// lookupEncoding returns the encoding by name or alias
+func lookupEncoding(name string) (encoding.Encoding, bool) {
+	allMu.RLock()
+	defer allMu.RUnlock()
+	e, ok := all[normalizeName(name)]
+	return e, ok
+}
+
+// RegisterAlias registers an alias for the supported encoding canonical,
+// which can be an encoding name or another alias. Separators and cases are
+// ignored in both names.
+func RegisterAlias(alias, canonical string) error {
+	allMu.Lock()
+	defer allMu.Unlock()
+	e, ok := all[normalizeName(canonical)]
+	if !ok {
+		return fmt.Errorf("unsupported encoding %v", canonical)
 	}
+	all[normalizeName(alias)] = e
+	return nil
 }
 
 func extend(dest []encoding.Encoding, alls ...[]encoding.Encoding) []encoding.Encoding {
@@ -78,51 +145,213 @@ func extend(dest []encoding.Encoding, alls ...[]encoding.Encoding) []encoding.En
 
 // IsEncodingSupported checks if the encoding is supported
 func IsEncodingSupported(name string) bool {
-	_, ok := all[strings.ToUpper(name)]
+	_, ok := lookupEncoding(name)
 	return ok
 }
 
+// ErrInvalidSequence is returned in TransformError if the input contains a
+// byte sequence which is invalid in the source encoding.
+var ErrInvalidSequence = errors.New("invalid byte sequence")
+
+// TransformError reports the byte offset in the input where Transform fails.
+type TransformError struct {
+	// Offset is the byte offset of the first invalid or unsupported
+	// character in the input
+	Offset int
+	Err    error
+}
+
+func (e *TransformError) Error() string {
+	return fmt.Sprintf("transform failed at byte offset %d: %v", e.Offset, e.Err)
+}
+
+func (e *TransformError) Unwrap() error {
+	return e.Err
+}
+
+type transformOptions struct {
+	lenient bool
+}
+
+// TransformOption configures Transform
+type TransformOption func(*transformOptions)
+
+// Lenient makes Transform substitute the invalid byte sequences with the
+// Unicode replacement character instead of failing. The characters which
+// are not supported by the target encoding are substituted with the
+// replacement character of the target encoding, e.g. '\x1a' for GBK.
+func Lenient() TransformOption {
+	return func(o *transformOptions) {
+		o.lenient = true
+	}
+}
+
 // Encode encodes the utf-8 bytes into target encoding
-func Encode(s []byte, to string) ([]byte, error) {
-	return Transform(s, "UTF-8", to)
+func Encode(s []byte, to string, opts ...TransformOption) ([]byte, error) {
+	return Transform(s, "UTF-8", to, opts...)
 }
 
 // Decode decodes the bytes to UTF-8 bytes
-func Decode(s []byte, from string) ([]byte, error) {
-	return Transform(s, from, "UTF-8")
+func Decode(s []byte, from string, opts ...TransformOption) ([]byte, error) {
+	return Transform(s, from, "UTF-8", opts...)
 }
 
 // TransformString decodes the input string with srouce encoding and
-// then encodes it into target encoding
-func TransformString(s string, from, to string) (string, error) {
-	ret, err := Transform([]byte(s), from, to)
-	if err != nil {
-		return "", err
-	}
-	return string(ret), nil
+// then encodes it into target encoding.
+//
+// Like Transform, it fails on invalid input unless Lenient is set, and the
+// successfully transformed prefix is returned with the *TransformError
+// instead of an empty string.
+func TransformString(s string, from, to string, opts ...TransformOption) (string, error) {
+	ret, err := Transform([]byte(s), from, to, opts...)
+	return string(ret), err
 }
 
 // Transform decodes the input bytes with srouce encoding and
-// then encodes them into target encoding
-func Transform(s []byte, from, to string) ([]byte, error) {
-	from = strings.ToUpper(from)
-	to = strings.ToUpper(to)
+// then encodes them into target encoding.
+//
+// Decoding is strict by default: it fails on the first invalid byte
+// sequence in the input or the first character not supported by the target
+// encoding instead of substituting U+FFFD, set Lenient to substitute them.
+// On failure, a *TransformError reporting the byte offset is returned with
+// the successfully transformed prefix, callers must check the error before
+// using the output.
+func Transform(s []byte, from, to string, opts ...TransformOption) ([]byte, error) {
+	o := &transformOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
 
-	fromEncoding, ok := all[from]
+	fromEncoding, ok := lookupEncoding(from)
 	if !ok {
 		return nil, fmt.Errorf("unsupported from encoding %v", from)
 	}
 
-	toEncoding, ok := all[to]
+	toEncoding, ok := lookupEncoding(to)
 	if !ok {
 		return nil, fmt.Errorf("unsupported to encoding %v", to)
 	}
 
-	reader := transform.NewReader(bytes.NewBuffer(s), transform.Chain(fromEncoding.NewDecoder(), toEncoding.NewEncoder()))
+	t := newBytesTransformer(fromEncoding, toEncoding, o.lenient)
+	return t.transform(s)
+}
+
+// bytesTransformer transforms the input character by character to locate
+// the offset of the failure.
+type bytesTransformer struct {
+	decoder transform.Transformer
+	encoder transform.Transformer
+	lenient bool
+	// replacement is the U+FFFD encoded in source encoding, it is used to
+	// tell a real U+FFFD in input from an invalid sequence.
+	replacement []byte
+
+	out     bytes.Buffer
+	decoded []byte
+	encoded []byte
+}
+
+func newBytesTransformer(from, to encoding.Encoding, lenient bool) *bytesTransformer {
+	t := &bytesTransformer{
+		decoder: from.NewDecoder(),
+		encoder: to.NewEncoder(),
+		lenient: lenient,
+		decoded: make([]byte, 4*utf8.UTFMax),
+		encoded: make([]byte, 64),
+	}
+	if lenient {
+		t.encoder = encoding.ReplaceUnsupported(to.NewEncoder())
+	}
+	t.replacement, _ = from.NewEncoder().Bytes([]byte(string(utf8.RuneError)))
+	return t
+}
+
+func (t *bytesTransformer) transform(s []byte) ([]byte, error) {
+	pos := 0
+	size := 1
+	for pos < len(s) {
+		// feed the decoder byte by byte until it outputs a character
+		end := pos + size
+		atEOF := end >= len(s)
+		if atEOF {
+			end = len(s)
+		}
+		nDst, nSrc, err := t.decoder.Transform(t.decoded, s[pos:end], atEOF)
+		if err != nil && err != transform.ErrShortSrc {
+			return t.out.Bytes(), &TransformError{Offset: pos, Err: err}
+		}
+		if nSrc == 0 {
+			if atEOF {
+				return t.out.Bytes(), &TransformError{Offset: pos, Err: ErrInvalidSequence}
+			}
+			size++
+			continue
+		}
+
+		decoded := t.decoded[:nDst]
+		if !t.lenient && bytes.ContainsRune(decoded, utf8.RuneError) && !bytes.Equal(s[pos:pos+nSrc], t.replacement) {
+			return t.out.Bytes(), &TransformError{Offset: pos, Err: ErrInvalidSequence}
+		}
+		if err := t.encode(decoded, false); err != nil {
+			return t.out.Bytes(), &TransformError{Offset: pos, Err: err}
+		}
+		pos += nSrc
+		size = 1
+	}
+	// flush the state of encoder
+	if err := t.encode(nil, true); err != nil {
+		return t.out.Bytes(), &TransformError{Offset: pos, Err: err}
+	}
+	return t.out.Bytes(), nil
+}
+
+func (t *bytesTransformer) encode(src []byte, atEOF bool) error {
+	for {
+		nDst, nSrc, err := t.encoder.Transform(t.encoded, src, atEOF)
+		t.out.Write(t.encoded[:nDst])
+		src = src[nSrc:]
+		if err == transform.ErrShortDst {
+			if nDst == 0 && nSrc == 0 {
+				t.encoded = make([]byte, 2*len(t.encoded))
+			}
+			continue
+		}
+		return err
+	}
+}
+
+// NewTransformReader returns a reader which decodes the bytes read from r
+// with source encoding and then encodes them into target encoding.
+// The bytes are transformed incrementally without buffering the whole input.
+func NewTransformReader(r io.Reader, from, to string) (io.Reader, error) {
+	t, err := newTransformer(from, to)
+	if err != nil {
+		return nil, err
+	}
+	return transform.NewReader(r, t), nil
+}
 
-	ret, err := ioutil.ReadAll(reader)
+// NewTransformWriter returns a writer which decodes the bytes written to it
+// with source encoding and then encodes them into target encoding before
+// writing them to w. Close must be called to flush the pending bytes, it does
+// not close w.
+func NewTransformWriter(w io.Writer, from, to string) (io.WriteCloser, error) {
+	t, err := newTransformer(from, to)
 	if err != nil {
 		return nil, err
 	}
-	return ret, nil
+	return transform.NewWriter(w, t), nil
+}
+
+func newTransformer(from, to string) (transform.Transformer, error) {
+	fromEncoding, ok := lookupEncoding(from)
+	if !ok {
+		return nil, fmt.Errorf("unsupported from encoding %v", from)
+	}
+
+	toEncoding, ok := lookupEncoding(to)
+	if !ok {
+		return nil, fmt.Errorf("unsupported to encoding %v", to)
+	}
+	return transform.Chain(fromEncoding.NewDecoder(), toEncoding.NewEncoder()), nil
 }
@@ -15,7 +15,11 @@
 package textencoding
 
 import (
+	"bytes"
+	"errors"
+	"io"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -168,3 +172,233 @@ func TestDecode(t *testing.T) {
 		})
 	}
 }
+
+// countingReader records the max number of bytes read at once
+type countingReader struct {
+	r       io.Reader
+	reads   int
+	maxRead int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.reads++
+	if n > c.maxRead {
+		c.maxRead = n
+	}
+	return n, err
+}
+
+func TestNewTransformReader(t *testing.T) {
+	// 4MB gbk encoded "中文"
+	gbk := bytes.Repeat([]byte{0xD6, 0xD0, 0xCE, 0xC4}, 1<<20)
+	want := []byte(strings.Repeat("中文", 1<<20))
+
+	src := &countingReader{r: bytes.NewReader(gbk)}
+	reader, err := NewTransformReader(src, "gbk", "utf8")
+	if err != nil {
+		t.Fatalf("NewTransformReader() error = %v", err)
+	}
+
+	got := bytes.Buffer{}
+	buf := make([]byte, 1000)
+	for {
+		n, err := reader.Read(buf)
+		got.Write(buf[:n])
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Read() error = %v", err)
+		}
+	}
+	if !bytes.Equal(got.Bytes(), want) {
+		t.Errorf("NewTransformReader() transformed %v bytes, want %v bytes", got.Len(), len(want))
+	}
+	if src.reads < 2 || src.maxRead >= len(gbk) {
+		t.Errorf("NewTransformReader() should read the source incrementally, got %v reads, max read %v bytes", src.reads, src.maxRead)
+	}
+
+	if _, err := NewTransformReader(bytes.NewReader(gbk), "unknown", "utf8"); err == nil {
+		t.Errorf("NewTransformReader() want error for unsupported encoding")
+	}
+}
+
+func TestNewTransformWriter(t *testing.T) {
+	gbk := bytes.Repeat([]byte{0xD6, 0xD0, 0xCE, 0xC4}, 1<<20)
+	want := []byte(strings.Repeat("中文", 1<<20))
+
+	got := bytes.Buffer{}
+	writer, err := NewTransformWriter(&got, "gbk", "utf8")
+	if err != nil {
+		t.Fatalf("NewTransformWriter() error = %v", err)
+	}
+	// write in odd sized chunks to split multi-byte characters
+	for chunk := gbk; len(chunk) > 0; {
+		n := 999
+		if n > len(chunk) {
+			n = len(chunk)
+		}
+		if _, err := writer.Write(chunk[:n]); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		chunk = chunk[n:]
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if !bytes.Equal(got.Bytes(), want) {
+		t.Errorf("NewTransformWriter() transformed %v bytes, want %v bytes", got.Len(), len(want))
+	}
+
+	if _, err := NewTransformWriter(&got, "utf8", "unknown"); err == nil {
+		t.Errorf("NewTransformWriter() want error for unsupported encoding")
+	}
+}
+
+func TestEncodingAliases(t *testing.T) {
+	tests := []struct {
+		name      string
+		canonical string
+	}{
+		{"utf-8", "UTF-8"},
+		{"utf8", "UTF-8"},
+		{"UTF_8", "UTF-8"},
+		{"gb18030", "GB18030"},
+		{"gbk", "GBK"},
+		{"cp936", "GBK"},
+		{"latin1", "ISO 8859-1"},
+		{"Latin-1", "ISO 8859-1"},
+		{"iso-8859-1", "ISO 8859-1"},
+		{"ISO_8859_15", "ISO 8859-15"},
+		{"ShiftJIS", "Shift JIS"},
+		{"shift_jis", "Shift JIS"},
+		{"sjis", "Shift JIS"},
+		{"windows-1252", "Windows 1252"},
+		{"cp1252", "Windows 1252"},
+		{"euc-jp", "EUC-JP"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := lookupEncoding(tt.name)
+			if !ok {
+				t.Fatalf("lookupEncoding(%q) is not found", tt.name)
+			}
+			want, _ := lookupEncoding(tt.canonical)
+			if got != want {
+				t.Errorf("lookupEncoding(%q) = %v, want %v", tt.name, got, want)
+			}
+		})
+	}
+
+	if IsEncodingSupported("utf-9") {
+		t.Errorf("IsEncodingSupported() want false for unknown encoding")
+	}
+}
+
+func TestRegisterAlias(t *testing.T) {
+	if err := RegisterAlias("chinese", "gb-18030"); err != nil {
+		t.Fatalf("RegisterAlias() error = %v", err)
+	}
+	got, err := Transform([]byte{0xD6, 0xD0, 0xCE, 0xC4}, "Chinese", "utf-8")
+	if err != nil {
+		t.Fatalf("Transform() error = %v", err)
+	}
+	if string(got) != "中文" {
+		t.Errorf("Transform() = %q, want %q", got, "中文")
+	}
+
+	if err := RegisterAlias("unknown-alias", "unknown"); err == nil {
+		t.Errorf("RegisterAlias() want error for unsupported encoding")
+	}
+	if IsEncodingSupported("unknown-alias") {
+		t.Errorf("IsEncodingSupported() want false for failed alias")
+	}
+}
+
+func TestTransform_Invalid(t *testing.T) {
+	tests := []struct {
+		name        string
+		s           []byte
+		from        string
+		to          string
+		want        []byte
+		wantOffset  int
+		wantLenient []byte
+	}{
+		{
+			"malformed gbk",
+			[]byte{'a', 0xD6, 0xD0, 0xFF, 0xCE, 0xC4},
+			"gbk",
+			"utf8",
+			[]byte("a中"),
+			3,
+			[]byte("a中�文"),
+		},
+		{
+			"truncated gbk",
+			[]byte{0xD6, 0xD0, 0xCE},
+			"gbk",
+			"utf8",
+			[]byte("中"),
+			2,
+			[]byte("中�"),
+		},
+		{
+			"invalid utf8",
+			[]byte{'a', 'b', 0xFF, 'c'},
+			"utf8",
+			"utf16le",
+			[]byte{'a', 0, 'b', 0},
+			2,
+			[]byte{'a', 0, 'b', 0, 0xFD, 0xFF, 'c', 0},
+		},
+		{
+			"unsupported by target",
+			[]byte("中文😀"),
+			"utf8",
+			"gbk",
+			[]byte{0xD6, 0xD0, 0xCE, 0xC4},
+			6,
+			[]byte{0xD6, 0xD0, 0xCE, 0xC4, 0x1A},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Transform(tt.s, tt.from, tt.to)
+			var terr *TransformError
+			if !errors.As(err, &terr) {
+				t.Fatalf("Transform() error = %v, want *TransformError", err)
+			}
+			if terr.Offset != tt.wantOffset {
+				t.Errorf("Transform() error offset = %v, want %v", terr.Offset, tt.wantOffset)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("Transform() = %q, want prefix %q", got, tt.want)
+			}
+			if got, err := TransformString(string(tt.s), tt.from, tt.to); err == nil || got != string(tt.want) {
+				t.Errorf("TransformString() = %q, %v, want prefix %q with error", got, err, tt.want)
+			}
+
+			got, err = Transform(tt.s, tt.from, tt.to, Lenient())
+			if err != nil {
+				t.Fatalf("Transform() with Lenient error = %v", err)
+			}
+			if !bytes.Equal(got, tt.wantLenient) {
+				t.Errorf("Transform() with Lenient = %q, want %q", got, tt.wantLenient)
+			}
+		})
+	}
+}
+
+func TestTransform_ReplacementCharacter(t *testing.T) {
+	// a real U+FFFD in input is not an invalid sequence
+	got, err := Transform([]byte("a�b"), "utf8", "utf16le")
+	if err != nil {
+		t.Fatalf("Transform() error = %v", err)
+	}
+	want := []byte{'a', 0, 0xFD, 0xFF, 'b', 0}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Transform() = %q, want %q", got, want)
+	}
+}